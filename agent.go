@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// agentSockEnv points ddg at a daemon socket forwarded from another machine,
+// the way SSH_AUTH_SOCK points ssh at a forwarded agent. With it set, 'ddg
+// gen' needs no token of its own: the trusted machine generates and keeps
+// the alias in its history.
+const agentSockEnv = "DDG_AGENT_SOCK"
+
+// genViaAgent is 'ddg gen' with DDG_AGENT_SOCK set. If the trusted daemon
+// has keys, DDG_CLIENT_TOKEN must hold one with the generate scope.
+func genViaAgent(e historyEntry, showQR bool, clear string) {
+	if len(e.Fields) > 0 || e.Review != nil || e.Notes != "" || len(e.Tags) > 0 {
+		exitErr(fmt.Errorf("--field, --note, --remind and --namespace aren't forwarded through %s; set them on the trusted machine", agentSockEnv))
+	}
+	sock := os.Getenv(agentSockEnv)
+	c, err := newDaemonClient(os.Getenv("DDG_CLIENT_TOKEN"))
+	if err != nil {
+		exitErr(err)
+	}
+	path := "/generate"
+	if e.Label != "" {
+		path += "?label=" + url.QueryEscape(e.Label)
+	}
+	var res genResult
+	err = c.do(http.MethodPost, path, &res)
+	audit("agent.generate", err, map[string]string{"socket": sock, "address": res.Address, "label": e.Label})
+	if err != nil {
+		exitErr(fmt.Errorf("%s=%s: %w", agentSockEnv, sock, err))
+	}
+	res.Created = time.Now()
+	showForwarded(res, "the forwarding machine", showQR, clear)
+}
+
+// doAgent handles 'ddg agent ssh [ssh options...] <host>': log in to host
+// with this machine's daemon socket forwarded and DDG_AGENT_SOCK set in the
+// remote shell, so ddg there generates through this machine.
+func doAgent(args []string) {
+	if len(args) < 2 || args[0] != "ssh" {
+		exitErr(fmt.Errorf("usage: ddg agent ssh [ssh options...] <host>"))
+	}
+	st, err := readDaemonState()
+	if err != nil || st.Socket == "" {
+		exitErr(fmt.Errorf("start the daemon on this machine first: ddg serve"))
+	}
+	id, err := randomHex(6)
+	if err != nil {
+		exitErr(err)
+	}
+	remote := "/tmp/ddg-agent-" + id + ".sock"
+
+	sshArgs := []string{"-t",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "StreamLocalBindUnlink=yes",
+		"-R", remote + ":" + st.Socket}
+	sshArgs = append(sshArgs, args[1:]...)
+	// ssh creates the remote socket 0600 (StreamLocalBindMask), so other
+	// users on that machine can't generate through it.
+	sshArgs = append(sshArgs, agentSockEnv+"="+remote+` "${SHELL:-sh}" -l; rm -f `+remote)
+	audit("agent.forward", nil, map[string]string{"socket": st.Socket})
+	cmd := exec.Command("ssh", sshArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			os.Exit(ee.ExitCode())
+		}
+		exitErr(err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// alertPerDayKey turns on traffic alerts: when the mail watcher counts this
+// many messages to one alias in a day, it's likely on spam lists, and the
+// user hears about it (once a day per alias) through a desktop
+// notification and every notification sink.
+const alertPerDayKey = "alert_per_day"
+
+// dashboardURL is where aliases are deactivated.
+const dashboardURL = "https://duckduckgo.com/email/"
+
+// alertTraffic raises an alert for each alias in fresh (the ones that just
+// got mail) that has reached the daily limit, and marks it alerted for
+// today in act.
+func alertTraffic(cfg conf, act *mailActivity, fresh map[string]int, report func(string)) {
+	limit, _ := strconv.Atoi(cfg.AlertPerDay)
+	if limit <= 0 {
+		return
+	}
+	today := time.Now().Format("2006-01-02")
+	var over []string
+	for alias := range fresh {
+		a := act.Aliases[alias]
+		if a != nil && a.Days[today] >= limit && a.Alerted != today {
+			over = append(over, alias)
+		}
+	}
+	if len(over) == 0 {
+		return
+	}
+	sort.Strings(over)
+	entries, _ := readHistory()
+	sinks, err := configuredSinks(cfg)
+	if err != nil {
+		report("alerts: " + err.Error())
+	}
+	for _, alias := range over {
+		a := act.Aliases[alias]
+		a.Alerted = today
+		name := alias
+		if e, ok := findHistory(entries, alias); ok && e.Label != "" {
+			name += " (" + e.Label + ")"
+		}
+		msg := fmt.Sprintf("%s got %d messages today (%s = %d); it may have leaked to spam lists. Deactivate it at %s",
+			name, a.Days[today], alertPerDayKey, limit, dashboardURL)
+		report(msg)
+		sent := notify("Duck alias flooded", msg)
+		for sinkName, sink := range sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			err := sink.send(ctx, "Duck alias flooded", msg)
+			cancel()
+			audit("notify."+sinkName, err, map[string]string{"address": alias})
+			if err != nil {
+				report(fmt.Sprintf("%s alert failed: %v", sinkName, err))
+			} else {
+				sent = true
+			}
+		}
+		var failed error
+		if !sent {
+			failed = fmt.Errorf("the alert reached no notification or sink")
+		}
+		audit("alert.traffic", failed, map[string]string{"address": alias, "today": strconv.Itoa(a.Days[today]), "limit": strconv.Itoa(limit)})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// aliasPrefix marks the config keys for command aliases, one per alias:
+// "alias.g = gen --label 'quick signup' --json". Like git aliases, they are
+// expanded before dispatch, with the rest of the command line appended,
+// and can't replace a built-in command.
+const aliasPrefix = "alias."
+
+// isBuiltinCommand reports whether cmd is ddg's own, and so can't be taken
+// by a plugin or an alias. Anything starting with "gen" or "set" is taken
+// too, matching the dispatch in main.
+func isBuiltinCommand(cmd string) bool {
+	return builtinCommands[cmd] || strings.HasPrefix(cmd, "gen") || strings.HasPrefix(cmd, "set")
+}
+
+// expandAlias replaces args[0] with what it's an alias for, following
+// aliases of aliases, and reports whether it did.
+func expandAlias(aliases map[string]string, args []string) ([]string, bool, error) {
+	if len(args) == 0 {
+		return args, false, nil
+	}
+	seen := map[string]bool{}
+	for {
+		name := strings.ToLower(args[0])
+		def, ok := aliases[name]
+		if !ok || isBuiltinCommand(name) {
+			return args, len(seen) > 0, nil
+		}
+		if seen[name] {
+			return nil, false, fmt.Errorf("%s%s: alias loop", aliasPrefix, name)
+		}
+		seen[name] = true
+		words, err := splitAlias(def)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s%s: %w", aliasPrefix, name, err)
+		}
+		if len(words) == 0 {
+			return nil, false, fmt.Errorf("%s%s is empty", aliasPrefix, name)
+		}
+		args = append(words, args[1:]...)
+	}
+}
+
+// splitAlias splits an alias definition into words the way a shell would,
+// honouring quotes and backslashes. A definition quoted as a whole, as in
+// alias.g = "gen --json", is split inside the quotes.
+func splitAlias(def string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for _, r := range def {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	if len(words) == 1 && strings.ContainsAny(words[0], " \t") {
+		return splitAlias(words[0])
+	}
+	return words, nil
+}
+
+// commandAliases is the settings line for aliases: "g = gen --json; ...".
+func commandAliases(aliases map[string]string) string {
+	var parts []string
+	for _, name := range sortedKeys(aliases) {
+		parts = append(parts, name+" = "+aliases[name])
+	}
+	return emptyToDash(strings.Join(parts, "; "))
+}
+
+// applyAlias expands the command in os.Args if it's an alias. Global flags
+// in the alias apply as if given on the command line, and are parsed just
+// as far, but ones actually given there win.
+func applyAlias(cfg conf, args []string) []string {
+	expanded, ok, err := expandAlias(cfg.Aliases, args)
+	if err != nil {
+		exitErr(err)
+	}
+	if !ok {
+		return args
+	}
+	output, tmpl, profile := outputFlag, templateFlag, profileFlag
+	expanded = parseGlobalFlags(expanded)
+	if profileFlag != profile {
+		exitErr(fmt.Errorf("%s%s: --profile can't be set in an alias", aliasPrefix, strings.ToLower(args[0])))
+	}
+	if output != "" {
+		outputFlag = output
+	}
+	if tmpl != "" {
+		templateFlag = tmpl
+	}
+	if err := setOutput(outputFlag, templateFlag); err != nil {
+		exitErr(err)
+	}
+	return expanded
+}
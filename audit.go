@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const auditFileName = "audit.jsonl"
+
+// auditEvent is one line of the append-only audit log. Unlike history, which
+// describes aliases, the audit log describes actions: who changed what, when,
+// and whether it worked.
+type auditEvent struct {
+	Time    time.Time         `json:"time"`
+	Action  string            `json:"action"`
+	Outcome string            `json:"outcome"`
+	Error   string            `json:"error,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func auditPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, auditFileName), nil
+}
+
+// audit appends an event for action. A nil err records success. Failing to
+// write the audit log never fails the action itself, but is reported.
+func audit(action string, err error, details map[string]string) {
+	ev := auditEvent{Time: time.Now().UTC(), Action: action, Outcome: "ok", Details: details}
+	if err != nil {
+		ev.Outcome = "error"
+		ev.Error = err.Error()
+	}
+	if werr := appendAudit(ev); werr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not write audit log: %v\n", werr)
+	}
+}
+
+func appendAudit(ev auditEvent) (err error) {
+	path, err := auditPath()
+	if err != nil {
+		return err
+	}
+	span := storageSpan("ddg.audit.append", path)
+	span.SetAttributes(ddg.Attr{Key: "ddg.action", Value: ev.Action})
+	defer func() { span.End(err) }()
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	// O_APPEND only: existing lines are never rewritten.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+const (
+	auditKeyFileName = "audit.key"
+	auditKeyAccount  = "audit-signing"
+)
+
+// auditSignature is written next to a signed export as <file>.sig.
+type auditSignature struct {
+	Algorithm string    `json:"algorithm"`
+	KeyID     string    `json:"key_id"`
+	Events    int       `json:"events"`
+	Created   time.Time `json:"created"`
+	Signature string    `json:"signature"`
+}
+
+func doAudit(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg audit export [--sign] [--out <file>] | verify <file> [--sig <file.sig>]"))
+	}
+	switch args[0] {
+	case "export":
+		auditExport(args[1:])
+	case "verify":
+		auditVerify(args[1:])
+	default:
+		exitErr(fmt.Errorf("usage: unknown audit command %s", args[0]))
+	}
+}
+
+func auditExport(args []string) {
+	sign, outPath := false, ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--sign" {
+			sign = true
+		} else if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if sign && outPath == "" {
+		exitErr(fmt.Errorf("--sign needs --out <file>; the signature is written to <file>.sig"))
+	}
+
+	path, err := auditPath()
+	if err != nil {
+		exitErr(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		exitErr(err)
+	}
+	// Recorded after reading, so an export never contains its own record.
+	if outPath == "" {
+		_, err = os.Stdout.Write(data)
+		audit("audit.export", err, map[string]string{"file": "stdout"})
+		if err != nil {
+			exitErr(err)
+		}
+		return
+	}
+	if err := os.WriteFile(outPath, data, 0600); err != nil {
+		audit("audit.export", err, map[string]string{"file": outPath})
+		exitErr(err)
+	}
+	if !sign {
+		audit("audit.export", nil, map[string]string{"file": outPath})
+		out.Done(fmt.Sprintf("Audit log exported to %s.", outPath), map[string]string{"file": outPath})
+		return
+	}
+
+	key, err := auditSigningKey(true)
+	if err != nil {
+		exitErr(err)
+	}
+	sig := auditSignature{
+		Algorithm: "hmac-sha256",
+		KeyID:     auditKeyID(key),
+		Events:    bytes.Count(data, []byte("\n")),
+		Created:   time.Now().UTC(),
+		Signature: hex.EncodeToString(auditMAC(key, data)),
+	}
+	b, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		exitErr(err)
+	}
+	sigPath := outPath + ".sig"
+	err = os.WriteFile(sigPath, append(b, '\n'), 0600)
+	audit("audit.export", err, map[string]string{"file": outPath, "signed": "yes", "key_id": sig.KeyID})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Audit log (%d events) exported to %s, signature in %s.", sig.Events, outPath, sigPath),
+		map[string]string{"file": outPath, "signature": sigPath, "key_id": sig.KeyID})
+}
+
+func auditVerify(args []string) {
+	file, sigPath := "", ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--sig" && i+1 < len(args) {
+			sigPath = args[i+1]
+			i++
+		} else if file == "" && !strings.HasPrefix(args[i], "--") {
+			file = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if file == "" {
+		exitErr(fmt.Errorf("usage: ddg audit verify <file> [--sig <file.sig>]"))
+	}
+	if sigPath == "" {
+		sigPath = file + ".sig"
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		exitErr(err)
+	}
+	b, err := os.ReadFile(sigPath)
+	if err != nil {
+		exitErr(err)
+	}
+	var sig auditSignature
+	if err := json.Unmarshal(b, &sig); err != nil {
+		exitErr(fmt.Errorf("%s: %w", sigPath, err))
+	}
+	if sig.Algorithm != "hmac-sha256" {
+		exitErr(fmt.Errorf("%s: unsupported algorithm %q", sigPath, sig.Algorithm))
+	}
+	key, err := auditSigningKey(false)
+	if err != nil {
+		exitErr(err)
+	}
+	if id := auditKeyID(key); id != sig.KeyID {
+		exitErr(fmt.Errorf("signed with key %s, but this machine's key is %s", sig.KeyID, id))
+	}
+	want, err := hex.DecodeString(sig.Signature)
+	if err != nil || !hmac.Equal(want, auditMAC(key, data)) {
+		fmt.Fprintln(os.Stderr, red(sym("⚠️")+" Signature does NOT match: "+file+" was modified after it was signed."))
+		os.Exit(1)
+	}
+	out.Done(fmt.Sprintf("Signature valid: %d events, signed %s.", sig.Events, sig.Created.Local().Format("2006-01-02 15:04")),
+		map[string]interface{}{"valid": true, "events": sig.Events, "created": sig.Created, "key_id": sig.KeyID})
+}
+
+func auditMAC(key, data []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// auditKeyID identifies a signing key without revealing it.
+func auditKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// auditSigningKey returns the key audit exports are signed with, creating
+// it if create is set. It lives in the OS keychain when the API key does,
+// and in the data directory otherwise.
+func auditSigningKey(create bool) ([]byte, error) {
+	cfg, _ := readConfig()
+	if keystoreName(cfg) == keystoreKeychain {
+		ks := keychainStore{account: auditKeyAccount}
+		if v, err := ks.Get(); err == nil && v != "" {
+			return hex.DecodeString(v)
+		}
+		if !create {
+			return nil, fmt.Errorf("no audit signing key in the keychain")
+		}
+		v, err := randomHex(32)
+		if err != nil {
+			return nil, err
+		}
+		if err := ks.Set(v); err != nil {
+			return nil, fmt.Errorf("writing audit signing key to keychain: %w", err)
+		}
+		return hex.DecodeString(v)
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, auditKeyFileName)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(b)))
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !create {
+		return nil, fmt.Errorf("no audit signing key on this machine (%s)", path)
+	}
+	v, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(v+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(v)
+}
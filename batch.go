@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const (
+	// maxBatch caps 'ddg gen -n'; bigger runs belong in 'ddg provision',
+	// which takes a label for each.
+	maxBatch = 100
+	// batchWorkers is how many requests 'ddg gen -n' has in flight.
+	batchWorkers = 4
+)
+
+// genBatch generates count aliases, each carrying e's label and metadata,
+// printing each address as it arrives. A failed request is reported and
+// the rest go on, unless the failure means every other one would fail too
+// (a rejected key, a reached budget). Nothing is copied to the clipboard.
+// Progress is journaled by position in the batch, as 'ddg provision' does
+// by label, so --resume after a failure only generates what's missing.
+func genBatch(cfg conf, e historyEntry, count int, resume, restart bool) {
+	j, err := openJournal("gen", batchJournalID(e, count), resume, restart)
+	if err != nil {
+		exitErr(err)
+	}
+	defer j.Close()
+	if j.Len() > 0 {
+		out.Info("Resuming: %d of %d aliases already generated.", j.Len(), count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	todo := make(chan int)
+	var (
+		mu       sync.Mutex // guards output and everything below
+		made     []genResult
+		failed   int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for w := 0; w < batchWorkers && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			for n := range todo {
+				entry, err := generateAliasContext(ctx, cfg, e)
+				mu.Lock()
+				if err == nil {
+					err = j.Record(strconv.Itoa(n), entry.Address)
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						failed++
+						fmt.Fprintf(os.Stderr, "Error on alias %d of %d: %v\n", n, count, redactSecrets(err.Error()))
+						if firstErr == nil {
+							firstErr = err
+						}
+						if ddg.Unauthorized(err) || errors.Is(err, errOverBudget) {
+							cancel()
+						}
+					}
+				} else {
+					res := genResult{Address: entry.Address, Label: entry.Label, Created: entry.Created, Tags: entry.Tags,
+						Notes: entry.Notes, Fields: entry.Fields, Review: entry.Review, Duplicate: entry.Duplicate}
+					made = append(made, res)
+					// JSON is always an array here, even of one, so it's
+					// written at the end.
+					if out.format != outputJSON {
+						out.Item(res, batchLine(res))
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for n := 1; n <= count; n++ {
+		if _, ok := j.Done(strconv.Itoa(n)); ok {
+			continue
+		}
+		select {
+		case todo <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(todo)
+	wg.Wait()
+	if out.format == outputJSON {
+		printList(made, batchLine)
+	}
+
+	if firstErr != nil {
+		exitErr(fmt.Errorf("%d of %d aliases generated (rerun with --resume for the rest); the first failure: %w", j.Len(), count, firstErr))
+	}
+	_ = j.Finish()
+	if ns := namespaceOf(e.Tags); ns != "" {
+		out.Info(sym("✅")+" Generated %d aliases in namespace %s; 'ddg burn --namespace %s' burns them all.", len(made), ns, ns)
+		return
+	}
+	out.Info(sym("✅")+" Generated %d aliases.", len(made))
+}
+
+// batchJournalID tells batches apart in the journal: rerunning the same
+// command resumes it, a different label or namespace is a batch of its own.
+func batchJournalID(e historyEntry, count int) string {
+	id := "-n " + strconv.Itoa(count)
+	if e.Label != "" {
+		id += " --label " + e.Label
+	}
+	if ns := namespaceOf(e.Tags); ns != "" {
+		id += " --namespace " + ns
+	}
+	return id
+}
+
+func batchLine(r genResult) string {
+	return cyan(hyperlink("mailto:"+r.Address, r.Address))
+}
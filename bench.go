@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// benchPhases are what 'ddg bench' times, in the order a 'ddg gen' pays
+// for them. dns, connect and tls use fresh lookups and connections every
+// round; api goes through the shared client, as gen does.
+var benchPhases = []string{"config", "keystore", "dns", "connect", "tls", "api"}
+
+// doBench times each step of generating an alias separately, so a slow gen
+// can be pinned on disk, keychain, network or DuckDuckGo. By default api is
+// a real generation, recorded in history as "ddg bench"; --dry-run-network
+// uses an endpoint that generates nothing instead.
+func doBench(args []string) {
+	n, dry := 5, false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--n" && i+1 < len(args) {
+			v, err := strconv.Atoi(args[i+1])
+			if err != nil || v < 1 {
+				exitErr(fmt.Errorf("--n: want a positive number, got %q", args[i+1]))
+			}
+			n = v
+			i++
+		} else if args[i] == "--dry-run-network" {
+			dry = true
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	if !dry {
+		requireWritable("generate aliases for the benchmark")
+		if !confirm(confirmation{
+			Question: fmt.Sprintf("This generates %d real aliases (use --dry-run-network to avoid that). Go ahead?", n),
+		}) {
+			audit("bench", errCancelled, nil)
+			fmt.Println(sym("❌") + " Benchmark cancelled.")
+			return
+		}
+	}
+
+	u, err := url.Parse(ddg.DefaultBaseURL)
+	if err != nil {
+		exitErr(err)
+	}
+	host := u.Hostname()
+	stats := map[string]*latencyStats{}
+	for _, p := range benchPhases {
+		stats[p] = &latencyStats{}
+	}
+	timeIt := func(phase string, f func() error) error {
+		start := time.Now()
+		err := f()
+		stats[phase].observe(time.Since(start), err != nil)
+		return err
+	}
+
+	var firstErr error
+	for i := 0; i < n; i++ {
+		errs := []error{
+			timeIt("config", func() error { _, err := readConfig(); return err }),
+			timeIt("keystore", func() error { c := cfg; return loadToken(&c) }),
+		}
+		var addrs []string
+		errs = append(errs, timeIt("dns", func() (err error) {
+			addrs, err = net.DefaultResolver.LookupHost(context.Background(), host)
+			return err
+		}))
+		var conn net.Conn
+		if len(addrs) > 0 {
+			errs = append(errs, timeIt("connect", func() (err error) {
+				if err := checkEgress(host); err != nil {
+					return err
+				}
+				conn, err = net.DialTimeout("tcp", net.JoinHostPort(addrs[0], "443"), 10*time.Second)
+				return err
+			}))
+		}
+		if conn != nil {
+			errs = append(errs, timeIt("tls", func() error {
+				tc := tls.Client(conn, &tls.Config{ServerName: host})
+				tc.SetDeadline(time.Now().Add(10 * time.Second))
+				return tc.Handshake()
+			}))
+			conn.Close()
+		}
+		errs = append(errs, timeIt("api", func() error {
+			if dry {
+				return apiFor(cfg.APIKey).CheckToken(context.Background())
+			}
+			_, err := generateAlias(cfg, historyEntry{Label: "ddg bench"})
+			return err
+		}))
+		for _, err := range errs {
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	res := map[string]latencySummary{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d rounds against %s", n, host)
+	if dry {
+		b.WriteString(" (dry run: api generates nothing)")
+	}
+	fmt.Fprintf(&b, "\n%-10s %9s %9s %7s\n", "phase", "p50 ms", "p95 ms", "errors")
+	for _, p := range benchPhases {
+		s := stats[p].summary()
+		res[p] = s
+		fmt.Fprintf(&b, "%-10s %9.1f %9.1f %7d\n", p, s.P50ms, s.P95ms, s.Errors)
+	}
+	if firstErr != nil {
+		fmt.Fprintf(&b, "first error: %v", firstErr)
+	}
+	audit("bench", nil, map[string]string{"rounds": strconv.Itoa(n), "dry_run": strconv.FormatBool(dry)})
+	out.Result(res, strings.TrimRight(b.String(), "\n"))
+}
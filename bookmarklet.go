@@ -0,0 +1,151 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// scopeBookmarklet only opens /bookmarklet. Keys with nothing else don't
+	// switch a single-user daemon into requiring keys from every client.
+	scopeBookmarklet = "bookmarklet"
+	bookmarkletKey   = "bookmarklet"
+)
+
+// bookmarkletJS runs on the current page: it asks the daemon for an alias
+// labelled with the page's site and types it into the focused field, or
+// shows it in a prompt to copy when no field is focused.
+const bookmarkletJS = `(function(){` +
+	`var f=document.activeElement;` +
+	`fetch('http://%s/bookmarklet',{method:'POST',headers:{'Authorization':'Bearer %s','Content-Type':'application/json'},body:JSON.stringify({url:location.href})})` +
+	`.then(function(r){return r.json()})` +
+	`.then(function(d){if(d.error){alert('ddg: '+d.error);return}` +
+	`if(f&&f!==document.body&&'value' in f){f.focus();f.value=d.address;f.dispatchEvent(new Event('input',{bubbles:true}));f.dispatchEvent(new Event('change',{bubbles:true}))}` +
+	`else{prompt('Your new Duck alias',d.address)}})` +
+	`.catch(function(e){alert('ddg: is the daemon running? ('+e+')')})` +
+	`})();`
+
+// doServeBookmarklet issues (or reissues) the bookmarklet's key and prints
+// the bookmarklet to drag into the bookmarks bar.
+func doServeBookmarklet(args []string) {
+	addr := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--addr" && i+1 < len(args) {
+			addr = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if addr == "" {
+		addr = defaultServeAddr
+		if st, err := readDaemonState(); err == nil && st.Addr != "" {
+			addr = st.Addr
+		}
+	}
+	if !isLoopback(addr) {
+		exitErr(fmt.Errorf("the bookmarklet talks to the daemon over plain HTTP; use a loopback --addr"))
+	}
+	requireWritable("issue the bookmarklet key")
+
+	keys, err := readKeys()
+	if err != nil {
+		exitErr(err)
+	}
+	kept := keys[:0]
+	for _, k := range keys {
+		if k.Name != bookmarkletKey {
+			kept = append(kept, k)
+		}
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		exitErr(err)
+	}
+	k := serveKey{Name: bookmarkletKey, Scopes: []string{scopeBookmarklet}, Hash: hashSecret(secret)}
+	err = writeKeys(append(kept, k))
+	audit("serve.keys.add", err, map[string]string{"key": k.Name, "scopes": scopeBookmarklet})
+	if err != nil {
+		exitErr(err)
+	}
+
+	js := "javascript:" + url.PathEscape(fmt.Sprintf(bookmarkletJS, addr, secret))
+	out.Result(map[string]string{"bookmarklet": js, "addr": addr}, fmt.Sprintf(
+		"Add a bookmark with this as its URL, then click it on any signup page (the\n"+
+			"daemon must be running, restart it to pick up the new key):\n\n%s\n\n"+
+			"Running this again replaces the key and invalidates older bookmarklets.", js))
+}
+
+// handleBookmarklet generates an alias labelled with the site the
+// bookmarklet was clicked on. It answers CORS preflights itself because the
+// request comes from whatever page is open.
+func (s *server) handleBookmarklet(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	h.Set("Access-Control-Allow-Methods", "POST")
+	if r.Method == http.MethodOptions {
+		// Chrome asks before letting a public page reach localhost.
+		h.Set("Access-Control-Allow-Private-Network", "true")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	who, status, err := s.authenticate(r, scopeBookmarklet)
+	if err != nil {
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	if who.Name != "local" {
+		writeJSONError(w, http.StatusForbidden, "the bookmarklet only works in single-user mode")
+		return
+	}
+	var body struct {
+		URL string `json:"url"`
+	}
+	_ = json.NewDecoder(io.LimitReader(r.Body, 8192)).Decode(&body)
+	page, err := url.Parse(body.URL)
+	if err != nil || page.Hostname() == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing page url")
+		return
+	}
+	label := cleanURLField(strings.TrimPrefix(strings.ToLower(page.Hostname()), "www."))
+	// Keep the page but not its query string, which may carry tokens.
+	page.RawQuery, page.Fragment, page.User = "", "", nil
+
+	addr, err, _ := s.flights.do(who.Name+"\x00"+label, func() (string, error) {
+		e, err := generateAlias(s.localConfig(who), historyEntry{Label: label, Notes: "via bookmarklet on " + page.String()})
+		return e.Address, err
+	})
+	if err != nil {
+		status := http.StatusBadGateway
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
+			status = http.StatusUnauthorized
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"address": addr, "label": label})
+}
+
+// requiresKeys reports whether clients must present a key: once any key
+// beyond the bookmarklet's has been issued.
+func (s *server) requiresKeys() bool {
+	for _, k := range s.currentKeys() {
+		for _, sc := range k.Scopes {
+			if sc != scopeBookmarklet {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxPerDay  = "max_per_day"
+	maxPerWeek = "max_per_week"
+)
+
+// errOverBudget means a self-imposed generation limit has been reached.
+var errOverBudget = errors.New("generation budget reached")
+
+// overrideBudget is set by --override on commands that generate, for the
+// rare day the user really does need more aliases than they planned for.
+var overrideBudget bool
+
+// budgetMu serializes budgeted generations within this process; the lock
+// on the audit log does the same across processes.
+var budgetMu sync.Mutex
+
+// lockBudget holds generations back while one is checked against the budget,
+// generated and counted, so that gen -n workers, provision --parallel and
+// daemon requests can't all pass the check before any of them is counted.
+// Without a budget there is nothing to count, and nothing is held. It
+// returns the func that releases the lock.
+func lockBudget(cfg conf) func() {
+	perDay, _ := strconv.Atoi(cfg.MaxPerDay)
+	perWeek, _ := strconv.Atoi(cfg.MaxPerWeek)
+	if perDay <= 0 && perWeek <= 0 {
+		return func() {}
+	}
+	budgetMu.Lock()
+	unlockFile := func() {}
+	if path, err := auditPath(); err == nil {
+		if unlock, err := lockFile(path); err == nil {
+			unlockFile = unlock
+		}
+	}
+	return func() {
+		unlockFile()
+		budgetMu.Unlock()
+	}
+}
+
+// checkBudget refuses a generation once max_per_day or max_per_week is
+// reached. Days start at local midnight and weeks on Monday. Counts come
+// from the audit log, so aliases that failed or were never saved to history
+// are counted exactly as DuckDuckGo counted them.
+func checkBudget(cfg conf) error {
+	perDay, _ := strconv.Atoi(cfg.MaxPerDay)
+	perWeek, _ := strconv.Atoi(cfg.MaxPerWeek)
+	if perDay <= 0 && perWeek <= 0 {
+		return nil
+	}
+	events, err := readAudit()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	week := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	today, thisWeek := 0, 0
+	for _, ev := range events {
+		if ev.Action != "generate" || ev.Outcome != "ok" {
+			continue
+		}
+		if !ev.Time.Before(week) {
+			thisWeek++
+		}
+		if !ev.Time.Before(day) {
+			today++
+		}
+	}
+
+	var over error
+	switch {
+	case perDay > 0 && today >= perDay:
+		over = fmt.Errorf("%w: %d aliases generated today (%s = %d). Rerun with --override if you really need another, or raise the limit with 'ddg settings --max-per-day <n>'",
+			errOverBudget, today, maxPerDay, perDay)
+	case perWeek > 0 && thisWeek >= perWeek:
+		over = fmt.Errorf("%w: %d aliases generated this week (%s = %d). Rerun with --override if you really need another, or raise the limit with 'ddg settings --max-per-week <n>'",
+			errOverBudget, thisWeek, maxPerWeek, perWeek)
+	}
+	if over != nil && overrideBudget {
+		audit("generate.override", nil, map[string]string{"today": strconv.Itoa(today), "week": strconv.Itoa(thisWeek)})
+		return nil
+	}
+	return over
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A bundle is everything an offline machine needs to install ddg, laid out
+// like the prefix it unpacks into, so installing is one cp -R. It's
+// reproducible: the same binary and --prefix always give the same bytes.
+// Timestamps come from SOURCE_DATE_EPOCH, else the commit the binary was
+// built from, else 1970.
+const (
+	bundleChecksums  = "SHA256SUMS"
+	bundleInstall    = "INSTALL"
+	launchdLabel     = "com.duckduckgone.ddg"
+	sourceDateEpoch  = "SOURCE_DATE_EPOCH"
+	defaultPrefix    = "/usr/local"
+	bundleDirMode    = 0755
+	bundleFileMode   = 0644
+	bundleBinaryMode = 0755
+)
+
+type bundleFile struct {
+	name string
+	mode int64
+	data []byte
+}
+
+func doBundle(args []string) {
+	outPath, prefix := "", defaultPrefix
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else if args[i] == "--prefix" && i+1 < len(args) {
+			prefix = strings.TrimSuffix(args[i+1], "/")
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if !path.IsAbs(prefix) {
+		exitErr(fmt.Errorf("usage: --prefix must be an absolute path"))
+	}
+	top := bundleName(runtime.GOOS, runtime.GOARCH)
+	if outPath == "" {
+		outPath = top + ".tar.gz"
+	}
+
+	files, err := bundleFiles(prefix, bundleTime())
+	if err != nil {
+		exitErr(err)
+	}
+	f, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		exitErr(err)
+	}
+	sum := sha256.New()
+	err = writeBundle(io.MultiWriter(f, sum), top, files, bundleTime())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(outPath)
+		exitErr(err)
+	}
+	digest := fmt.Sprintf("%x", sum.Sum(nil))
+	out.Done(fmt.Sprintf("Bundle written to %s (sha256 %s).", outPath, digest),
+		map[string]interface{}{"file": outPath, "sha256": digest, "prefix": prefix, "files": len(files)})
+}
+
+// bundleName is the bundle's top directory, and its file name before the
+// .tar.gz: ddg-1.0.0-linux-amd64.
+func bundleName(goos, goarch string) string {
+	return fmt.Sprintf("ddg-%s-%s-%s", version, goos, goarch)
+}
+
+// bundleTime is the one timestamp used throughout a bundle.
+func bundleTime() time.Time {
+	if s := os.Getenv(sourceDateEpoch); s != "" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(n, 0).UTC()
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.time" {
+				if t, err := time.Parse(time.RFC3339, s.Value); err == nil {
+					return t.UTC()
+				}
+			}
+		}
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// bundleFiles renders the bundle's contents, ending with the checksum
+// manifest over everything before it.
+func bundleFiles(prefix string, when time.Time) ([]bundleFile, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	bin, err := os.ReadFile(exe)
+	if err != nil {
+		return nil, fmt.Errorf("reading this binary: %w", err)
+	}
+	binName := "ddg"
+	if runtime.GOOS == "windows" {
+		binName = "ddg.exe"
+	}
+	files := []bundleFile{{name: "bin/" + binName, mode: bundleBinaryMode, data: bin}}
+	add := func(name string, render func(io.Writer) error) error {
+		var b bytes.Buffer
+		if err := render(&b); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		files = append(files, bundleFile{name: name, mode: bundleFileMode, data: b.Bytes()})
+		return nil
+	}
+
+	if err := add("share/man/man1/ddg.1", func(w io.Writer) error { return writeManPage(w, when) }); err != nil {
+		return nil, err
+	}
+	if err := add("share/ddg/completion/ddg.ts", writeFigSpec); err != nil {
+		return nil, err
+	}
+	if err := add("share/ddg/completion/ddg.yaml", writeCarapaceSpec); err != nil {
+		return nil, err
+	}
+	// A minimal build has no daemon to run.
+	if hasBuiltin("serve") {
+		switch runtime.GOOS {
+		case "linux":
+			err = add("lib/systemd/user/ddg.service", func(w io.Writer) error { return writeSystemdUnit(w, prefix) })
+		case "darwin":
+			err = add("share/ddg/launchd/"+launchdLabel+".plist", func(w io.Writer) error { return writeLaunchdPlist(w, prefix) })
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := add(bundleInstall, func(w io.Writer) error { return writeBundleInstall(w, prefix) }); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	var sums bytes.Buffer
+	for _, f := range files {
+		fmt.Fprintf(&sums, "%x  %s\n", sha256.Sum256(f.data), f.name)
+	}
+	return append(files, bundleFile{name: bundleChecksums, mode: bundleFileMode, data: sums.Bytes()}), nil
+}
+
+// writeBundle writes files under top as a .tar.gz with every header field
+// that could differ between runs pinned.
+func writeBundle(w io.Writer, top string, files []bundleFile, when time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	hdr := func(name string, typ byte, mode, size int64) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: typ, Mode: mode, Size: size, ModTime: when, Format: tar.FormatUSTAR}
+	}
+	if err := tw.WriteHeader(hdr(top+"/", tar.TypeDir, bundleDirMode, 0)); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	var err error
+	for _, f := range files {
+		// Parent directories first, each once.
+		var dirs []string
+		for d := path.Dir(f.name); d != "." && !seen[d]; d = path.Dir(d) {
+			seen[d] = true
+			dirs = append([]string{d}, dirs...)
+		}
+		for _, d := range dirs {
+			if err = tw.WriteHeader(hdr(path.Join(top, d)+"/", tar.TypeDir, bundleDirMode, 0)); err != nil {
+				return err
+			}
+		}
+		if err = tw.WriteHeader(hdr(path.Join(top, f.name), tar.TypeReg, f.mode, int64(len(f.data)))); err != nil {
+			return err
+		}
+		if _, err = tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func hasBuiltin(name string) bool {
+	for _, c := range commandTree {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeSystemdUnit(w io.Writer, prefix string) error {
+	_, err := fmt.Fprintf(w, `[Unit]
+Description=DuckDuckGone alias daemon
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=%s/bin/ddg serve
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`, prefix)
+	return err
+}
+
+func writeLaunchdPlist(w io.Writer, prefix string) error {
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s/bin/ddg</string>
+		<string>serve</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`, launchdLabel, prefix)
+	return err
+}
+
+func writeBundleInstall(w io.Writer, prefix string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ddg %s for %s/%s\n\n", version, runtime.GOOS, runtime.GOARCH)
+	b.WriteString("Check the files, then copy them into place:\n\n")
+	check, dirs := "sha256sum -c", "bin share"
+	if runtime.GOOS == "darwin" {
+		check = "shasum -a 256 -c"
+	}
+	if runtime.GOOS == "linux" && hasBuiltin("serve") {
+		dirs += " lib"
+	}
+	fmt.Fprintf(&b, "  %s %s\n  cp -R %s %s/\n", check, bundleChecksums, dirs, prefix)
+	b.WriteString("\nCompletion: share/ddg/completion/ddg.ts is a Fig/Warp spec; ddg.yaml goes in\n~/.config/carapace/specs/ for carapace.\n")
+	if hasBuiltin("serve") {
+		switch runtime.GOOS {
+		case "linux":
+			b.WriteString("\nDaemon: systemctl --user enable --now ddg\n")
+		case "darwin":
+			fmt.Fprintf(&b, "\nDaemon: cp share/ddg/launchd/%[1]s.plist ~/Library/LaunchAgents/ &&\n  launchctl load ~/Library/LaunchAgents/%[1]s.plist\n", launchdLabel)
+		case "windows":
+			b.WriteString("\nDaemon: ddg install-windows-service\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeManPage renders ddg(1) from the completion tree, which is kept in
+// step with the dispatch and the help text.
+func writeManPage(w io.Writer, when time.Time) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH DDG 1 %q %q \"User Commands\"\n", when.Format("2006-01-02"), "ddg "+version)
+	b.WriteString(".SH NAME\nddg \\- DuckDuckGo email alias generator\n")
+	b.WriteString(".SH SYNOPSIS\n.B ddg\n[\\fIglobal flags\\fR] [\\fIcommand\\fR] [\\fIflags\\fR]\n")
+	b.WriteString(".SH DESCRIPTION\nddg generates Duck Address aliases with the DuckDuckGo Email Protection API,\ncopies them to the clipboard and keeps a local history of what each was for.\nRun without a command it generates one, if ddggen is set.\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, c := range commandTree {
+		manCommand(&b, "", c)
+	}
+	b.WriteString(".SH GLOBAL FLAGS\n")
+	for _, f := range globalFlags {
+		manFlag(&b, f)
+	}
+	b.WriteString(".SH FILES\n.TP\n.I " + roffEscape("~/"+confFileName) + "\nSettings and the API key, unless it's in a keystore.\n")
+	b.WriteString(".TP\n.I " + roffEscape("~/"+dataDirName+"/") + "\nHistory, state, the audit log and crash reports; profiles under\n.IR " + roffEscape(profilesDirName) + "/ .\n")
+	b.WriteString(".SH SEE ALSO\nRun\n.B ddg help\nfor examples and every setting.\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func manCommand(b *strings.Builder, parent string, c cmdSpec) {
+	name := strings.TrimSpace(parent + " " + c.Name)
+	b.WriteString(".TP\n\\fB" + roffEscape(strings.Join(append([]string{name}, c.Aliases...), ", ")) + "\\fR")
+	if c.Arg != "" {
+		b.WriteString(" \\fI" + roffEscape(c.Arg) + "\\fR")
+	}
+	b.WriteString("\n" + roffEscape(c.Desc) + "\n")
+	if len(c.Flags) > 0 {
+		b.WriteString(".RS\n")
+		for _, f := range c.Flags {
+			manFlag(b, f)
+		}
+		b.WriteString(".RE\n")
+	}
+	for _, s := range c.Subs {
+		manCommand(b, name, s)
+	}
+}
+
+func manFlag(b *strings.Builder, f flagSpec) {
+	b.WriteString(".TP\n\\fB" + roffEscape(f.Name))
+	if f.Short != "" {
+		b.WriteString(", " + roffEscape(f.Short))
+	}
+	b.WriteString("\\fR")
+	if f.Arg != "" {
+		b.WriteString(" \\fI" + roffEscape(f.Arg) + "\\fR")
+	}
+	b.WriteString("\n" + roffEscape(f.Desc) + "\n")
+}
+
+// roffEscape keeps s from being read as roff: backslashes, hyphens (which
+// would otherwise be hyphenation points) and a leading control character.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
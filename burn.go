@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Fields 'ddg burn' records on the alias it burns.
+const (
+	fieldBurned     = "burned"      // YYYY-MM-DD
+	fieldBurnReason = "burn_reason" // free text
+)
+
+// doBurn marks an alias burned, recording when and why. Duck only lets
+// aliases be deactivated from its dashboard, so with --open it walks
+// through that: the address goes to the clipboard for the dashboard's
+// search box, the dashboard opens, and the alias is marked once the user
+// says it's done. With --namespace it burns a whole namespace instead.
+func doBurn(args []string) {
+	key, reason, ns, open := "", "", "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--open" {
+			open = true
+		} else if args[i] == "--reason" && i+1 < len(args) {
+			reason = strings.TrimSpace(args[i+1])
+			i++
+		} else if args[i] == "--namespace" && i+1 < len(args) {
+			ns = args[i+1]
+			i++
+		} else if strings.HasPrefix(args[i], "-") || key != "" {
+			unknownArg(args[i])
+		} else {
+			key = args[i]
+		}
+	}
+	if ns != "" {
+		if key != "" || open {
+			exitErr(fmt.Errorf("usage: --namespace burns every alias in it, and can't be combined with an alias or --open"))
+		}
+		burnNamespace(ns, reason)
+		return
+	}
+	if key == "" {
+		exitErr(fmt.Errorf("usage: ddg burn <number|address|label> [--reason <text>] [--open], or ddg burn --namespace <name> [--reason <text>]"))
+	}
+	requireWritable("burn an alias")
+	rows, err := numberedHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	r, ok := findListed(rows, key)
+	if !ok {
+		exitErr(fmt.Errorf("no alias %q in history; 'ddg list' numbers them, newest first", key))
+	}
+	if hasTag(r.Tags, burnedTag) {
+		exitErr(fmt.Errorf("%s was already burned %s", r.Address, orDefault(r.Fields[fieldBurned], "before")))
+	}
+	address := r.Address
+	res := map[string]string{"address": address}
+
+	if open {
+		cfg, err := readConfig()
+		if err != nil && !os.IsNotExist(err) {
+			exitErr(err)
+		}
+		if backend, err := copyToClipboard(cfg, address); err == nil {
+			res["clipboard"] = backend
+			out.Info("Copied %s via %s; paste it into the dashboard's search box.", address, backend)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: could not copy: %v\n", err)
+		}
+		if err := openBrowser(dashboardURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not open %s: %v\n", dashboardURL, err)
+		} else {
+			res["url"] = dashboardURL
+		}
+		out.Info("Deactivate %s at %s, then come back here.", address, dashboardURL)
+		if stdinIsTerminal() && !yesAssumed(riskLow) {
+			answer := strings.ToLower(ask(prompt{Label: "Deactivated it? (yes/no)", Validate: validYesNo}))
+			if answer != "yes" {
+				audit("history.burn", errCancelled, map[string]string{"address": address})
+				fmt.Println(sym("❌") + " Not marked burned.")
+				return
+			}
+		}
+	}
+	if reason == "" && stdinIsTerminal() {
+		reason = ask(prompt{Label: "Why burn it? (optional)"})
+	}
+
+	today, err := markBurned([]string{address}, reason)
+	audit("history.burn", err, map[string]string{"address": address, "reason": reason})
+	if err != nil {
+		exitErr(err)
+	}
+	res[fieldBurned] = today
+	if reason != "" {
+		res["reason"] = reason
+	}
+	msg := address + " marked burned."
+	if !open {
+		msg += " Deactivate it at " + dashboardURL + ", or use 'ddg burn --open' next time."
+	}
+	out.Done(msg, res)
+}
+
+// markBurned tags addresses burned in history, recording today's date and
+// reason, and returns the date.
+func markBurned(addresses []string, reason string) (string, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return "", err
+	}
+	seen := historyAddresses(entries)
+	burn := map[string]bool{}
+	for _, a := range addresses {
+		burn[strings.ToLower(a)] = true
+	}
+	today := time.Now().Format("2006-01-02")
+	for i := range entries {
+		if !burn[strings.ToLower(entries[i].Address)] {
+			continue
+		}
+		entries[i].Tags = unionTags(entries[i].Tags, []string{burnedTag})
+		if entries[i].Fields == nil {
+			entries[i].Fields = map[string]string{}
+		}
+		entries[i].Fields[fieldBurned] = today
+		if reason != "" {
+			entries[i].Fields[fieldBurnReason] = reason
+		}
+	}
+	return today, writeHistory(entries, seen)
+}
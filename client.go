@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	daemonStateFileName = "daemon.json"
+	socketFileName      = "ddg.sock"
+)
+
+// daemonState is written by a running 'ddg serve' so clients can find it
+// without any configuration.
+type daemonState struct {
+	Addr   string `json:"addr"`
+	Socket string `json:"socket,omitempty"`
+	PID    int    `json:"pid"`
+}
+
+func daemonStatePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, daemonStateFileName), nil
+}
+
+func socketPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, socketFileName), nil
+}
+
+func writeDaemonState(st daemonState) error {
+	path, err := daemonStatePath()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+func readDaemonState() (daemonState, error) {
+	var st daemonState
+	path, err := daemonStatePath()
+	if err != nil {
+		return st, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(b, &st)
+	return st, err
+}
+
+func removeDaemonState() {
+	if path, err := daemonStatePath(); err == nil {
+		_ = os.Remove(path)
+	}
+}
+
+// daemonClient talks to a running daemon, preferring its Unix socket.
+type daemonClient struct {
+	http  *http.Client
+	base  string
+	token string
+}
+
+func newDaemonClient(token string) (*daemonClient, error) {
+	c := &daemonClient{
+		http:  &http.Client{Timeout: 10 * time.Second},
+		base:  "http://" + defaultServeAddr,
+		token: token,
+	}
+	if sock := os.Getenv(agentSockEnv); sock != "" {
+		c.dialSocket(sock)
+		return c, nil
+	}
+	st, err := readDaemonState()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if st.Socket != "" {
+		if _, err := os.Stat(st.Socket); err == nil {
+			c.dialSocket(st.Socket)
+			return c, nil
+		}
+	}
+	if st.Addr != "" {
+		c.base = "http://" + st.Addr
+	}
+	return c, nil
+}
+
+func (c *daemonClient) dialSocket(sock string) {
+	c.http.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}
+	c.base = "http://ddg"
+}
+
+// do sends a request and decodes a JSON response into v, turning the
+// daemon's {"error": ...} bodies into Go errors.
+func (c *daemonClient) do(method, path string, v interface{}) error {
+	req, err := http.NewRequest(method, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			return fmt.Errorf("no running daemon found (start one with 'ddg serve')")
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return &httpError{StatusCode: resp.StatusCode, Err: errors.New(e.Error)}
+		}
+		return &httpError{StatusCode: resp.StatusCode, Err: fmt.Errorf("HTTP %d", resp.StatusCode)}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+func doClient(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg client <gen|list|status> [--label <label>] [--token <key>]"))
+	}
+	sub := strings.ToLower(args[0])
+	token := os.Getenv("DDG_CLIENT_TOKEN")
+	label := ""
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--token" && i+1 < len(args) {
+			token = args[i+1]
+			i++
+		} else if args[i] == "--label" && i+1 < len(args) {
+			label = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+
+	c, err := newDaemonClient(token)
+	if err != nil {
+		exitErr(err)
+	}
+	switch {
+	case strings.HasPrefix(sub, "gen"):
+		path := "/generate"
+		if label != "" {
+			path += "?label=" + url.QueryEscape(label)
+		}
+		var res struct {
+			Address string `json:"address"`
+			Label   string `json:"label,omitempty"`
+		}
+		if err := c.do(http.MethodPost, path, &res); err != nil {
+			exitErr(err)
+		}
+		out.Result(res, res.Address)
+	case sub == "list":
+		var entries []historyEntry
+		if err := c.do(http.MethodGet, "/history", &entries); err != nil {
+			exitErr(err)
+		}
+		printList(entries, func(e historyEntry) string { return e.Address + "\t" + e.Label })
+	case sub == "status":
+		var res map[string]string
+		if err := c.do(http.MethodGet, "/health", &res); err != nil {
+			exitErr(err)
+		}
+		out.Result(res, fmt.Sprintf("daemon %s (version %s) at %s", res["status"], res["version"], c.base))
+	default:
+		exitErr(fmt.Errorf("usage: unknown client command %s", sub))
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Values for the selection setting.
+const (
+	selClipboard = "clipboard"
+	selPrimary   = "primary"
+	selBoth      = "both"
+)
+
+// clipboardBackend is one way of getting text onto the clipboard. primary is
+// nil for backends without an X11-style primary selection.
+type clipboardBackend struct {
+	name      string
+	available func() bool
+	copy      func(text string) error
+	primary   func(text string) error
+}
+
+// clipboardBackends lists every mechanism ddg knows, in probe order.
+// OSC 52 is last because the terminal never tells us whether it worked.
+var clipboardBackends = []clipboardBackend{
+	{"pbcopy", func() bool { return runtime.GOOS == "darwin" && hasCommand("pbcopy") }, pipeTo("pbcopy"), nil},
+	{"wl-copy", func() bool { return os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand("wl-copy") }, pipeTo("wl-copy"), pipeTo("wl-copy", "--primary")},
+	{"xclip", func() bool { return os.Getenv("DISPLAY") != "" && hasCommand("xclip") }, pipeTo("xclip", "-selection", "clipboard"), pipeTo("xclip", "-selection", "primary")},
+	{"xsel", func() bool { return os.Getenv("DISPLAY") != "" && hasCommand("xsel") }, pipeTo("xsel", "--clipboard", "--input"), pipeTo("xsel", "--primary", "--input")},
+	{"clip.exe", func() bool { return hasCommand("clip.exe") }, pipeTo("clip.exe"), nil},
+	// Also reached from WSL. Slower to start than clip.exe, hence after it.
+	{"powershell", func() bool { return hasCommand("powershell.exe") }, pipeTo("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Set-Clipboard -Value ([Console]::In.ReadToEnd())"), nil},
+	{"osc52", func() bool { return isTerminal() || sshTTY() != "" }, copyOSC52, copyOSC52Primary},
+}
+
+// nativeClipboard reaches the clipboard without a helper program: through
+// user32 on Windows, and by speaking X11 itself on other desktops. It's
+// never picked by probing, only when clipboard_backends names it.
+var nativeClipboard = clipboardBackend{"native", nativeAvailable, copyNative, nativePrimary}
+
+// clipboardOwnerCommand is the hidden command that holds an X11 selection
+// for the native backend.
+const clipboardOwnerCommand = "__clipboard-owner"
+
+// copyToClipboard copies text and returns the backend that did it. With
+// clipboard_backends configured those are tried strictly in order; otherwise
+// the backend detected on an earlier run is used, probing again only if that
+// one is gone or fails. Over SSH, OSC 52 comes first and nothing is cached:
+// the desktop tools there would fill the remote machine's clipboard.
+func copyToClipboard(cfg conf, text string) (string, error) {
+	if names := splitList(cfg.ClipBackends); len(names) > 0 {
+		var errs []string
+		for _, name := range names {
+			b, ok := clipboardBackendByName(name)
+			if !ok {
+				errs = append(errs, name+": unknown backend")
+				continue
+			}
+			if !b.available() {
+				errs = append(errs, name+": not available")
+				continue
+			}
+			if err := b.copySelection(cfg.Selection, text); err != nil {
+				errs = append(errs, name+": "+err.Error())
+				continue
+			}
+			return b.name, nil
+		}
+		return "", fmt.Errorf("all clipboard backends failed (%s)", strings.Join(errs, "; "))
+	}
+
+	var errs []string
+	remote := sshTTY() != ""
+	cached := ""
+	if !remote {
+		cached = getState(stateClipboardBackend)
+	}
+	if b, ok := clipboardBackendByName(cached); ok && b.available() {
+		err := b.copySelection(cfg.Selection, text)
+		if err == nil {
+			return b.name, nil
+		}
+		errs = append(errs, b.name+": "+err.Error())
+	}
+
+	for _, b := range probeOrder() {
+		if b.name == cached || !b.available() {
+			continue
+		}
+		err := b.copySelection(cfg.Selection, text)
+		if err == nil {
+			if !remote {
+				_ = setState(stateClipboardBackend, b.name)
+			}
+			return b.name, nil
+		}
+		errs = append(errs, b.name+": "+err.Error())
+	}
+	if !remote {
+		_ = setState(stateClipboardBackend, "")
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("all clipboard backends failed (%s)", strings.Join(errs, "; "))
+	}
+	return "", fmt.Errorf("no clipboard tool found: %s", missingClipboardTool())
+}
+
+// probeOrder is clipboardBackends, with osc52 moved first over SSH.
+func probeOrder() []clipboardBackend {
+	if sshTTY() == "" {
+		return clipboardBackends
+	}
+	order := []clipboardBackend{}
+	for _, b := range clipboardBackends {
+		if b.name == "osc52" {
+			order = append([]clipboardBackend{b}, order...)
+		} else {
+			order = append(order, b)
+		}
+	}
+	return order
+}
+
+// sshTTY is the terminal of the SSH session ddg runs in, if any.
+func sshTTY() string {
+	return os.Getenv("SSH_TTY")
+}
+
+// missingClipboardTool says what to install for this platform to have a
+// clipboard backend.
+func missingClipboardTool() string {
+	switch {
+	case runtime.GOOS == "darwin":
+		return "pbcopy is not on PATH"
+	case runtime.GOOS == "windows":
+		return "neither clip.exe nor powershell.exe is on PATH"
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		return "install wl-clipboard for wl-copy"
+	case os.Getenv("DISPLAY") != "":
+		return "install xclip or xsel"
+	}
+	return "no display to copy to (over SSH, a terminal with OSC 52 support does it)"
+}
+
+// copySelection copies text to the clipboard, the primary selection (what
+// middle-click pastes on X11/Wayland) or both. Backends without a primary
+// selection always use the clipboard.
+func (b clipboardBackend) copySelection(sel, text string) error {
+	if b.primary == nil || sel == "" || sel == selClipboard {
+		return b.copy(text)
+	}
+	if sel == selPrimary {
+		return b.primary(text)
+	}
+	if err := b.copy(text); err != nil {
+		return err
+	}
+	return b.primary(text)
+}
+
+// detectClipboard returns the first available backend without using it.
+func detectClipboard() (string, bool) {
+	for _, b := range probeOrder() {
+		if b.available() {
+			return b.name, true
+		}
+	}
+	return "", false
+}
+
+func clipboardBackendByName(name string) (clipboardBackend, bool) {
+	for _, b := range clipboardBackends {
+		if b.name == name {
+			return b, true
+		}
+	}
+	if name == nativeClipboard.name {
+		return nativeClipboard, true
+	}
+	return clipboardBackend{}, false
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// pipeTo returns a copy func that writes text to the stdin of name.
+func pipeTo(name string, args ...string) func(string) error {
+	return func(text string) error {
+		cmd := exec.Command(name, args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(stdin, text); err != nil {
+			return err
+		}
+		_ = stdin.Close()
+		return cmd.Wait()
+	}
+}
+
+// copyOSC52 asks the terminal itself to set the clipboard, which also works
+// over SSH. Inside tmux the sequence has to be wrapped to pass through. With
+// stdout piped, an SSH session's terminal is written to directly.
+func copyOSC52(text string) error {
+	return writeOSC52("c", text)
+}
+
+func copyOSC52Primary(text string) error {
+	return writeOSC52("p", text)
+}
+
+func writeOSC52(target, text string) error {
+	seq := "\033]52;" + target + ";" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		seq = "\033Ptmux;\033" + seq + "\033\\"
+	}
+	if isTerminal() {
+		_, err := io.WriteString(os.Stdout, seq)
+		return err
+	}
+	tty, err := os.OpenFile(sshTTY(), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	_, err = io.WriteString(tty, seq)
+	return err
+}
+
+func isTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clipClearKey wipes a generated address off the clipboard after this many
+// seconds, for people who treat aliases like passwords and don't want them
+// kept by clipboard managers. Empty or 0 leaves it there.
+const clipClearKey = "clipboard_clear_seconds"
+
+// clipboardClearCommand is the hidden command that waits in the background
+// and does the wiping.
+const clipboardClearCommand = "__clipboard-clear"
+
+// clipboardReader reads back what a backend copied, so the clipboard is
+// only wiped while it still holds the address. osc52 has none: terminals
+// don't answer reads, or shouldn't. primary is nil where there's no primary
+// selection to read.
+type clipboardReader struct {
+	clipboard, primary func() (string, error)
+}
+
+var clipboardReaders = map[string]clipboardReader{
+	"pbcopy":     {outputOf("pbpaste"), nil},
+	"wl-copy":    {outputOf("wl-paste", "--no-newline"), outputOf("wl-paste", "--no-newline", "--primary")},
+	"xclip":      {outputOf("xclip", "-o", "-selection", "clipboard"), outputOf("xclip", "-o", "-selection", "primary")},
+	"xsel":       {outputOf("xsel", "--clipboard", "--output"), outputOf("xsel", "--primary", "--output")},
+	"clip.exe":   {outputOf("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard -Raw"), nil},
+	"powershell": {outputOf("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard -Raw"), nil},
+	"native":     {func() (string, error) { return readNative("clipboard") }, func() (string, error) { return readNative("primary") }},
+}
+
+// outputOf returns a read func that runs name and returns its stdout.
+func outputOf(name string, args ...string) func() (string, error) {
+	return func() (string, error) {
+		b, err := exec.Command(name, args...).Output()
+		return string(b), err
+	}
+}
+
+// parseClearAfter reads a --clear-after value: "30s", "2m" or plain seconds.
+func parseClearAfter(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a duration like 30s or 2m (0 to keep it)", s)
+	}
+	return d.Round(time.Second), nil
+}
+
+// clearAfter is how long a generated address stays on the clipboard: flag,
+// when gen was given --clear-after, or else clipboard_clear_seconds.
+func clearAfter(cfg conf, flag string) time.Duration {
+	if flag == "" {
+		flag = cfg.ClipClear
+	}
+	d, _ := parseClearAfter(orDefault(flag, "0"))
+	return d
+}
+
+func clearSummary(v settingsView) string {
+	if v.ClipboardClear == "never" {
+		return v.ClipboardClear
+	}
+	return v.ClipboardClear + "s"
+}
+
+// copyGenerated copies a newly generated address, if cfg copies at all, and
+// has it wiped after clear. It returns the line saying so, for the human
+// output.
+func copyGenerated(cfg conf, res *genResult, clear time.Duration) string {
+	backend, err := copyToClipboard(cfg, res.Address)
+	if err != nil {
+		return ""
+	}
+	res.Clipboard = backend
+	if clear <= 0 {
+		return fmt.Sprintf("\n(copied to clipboard via %s)", backend)
+	}
+	if err := scheduleClipboardClear(backend, cfg.Selection, res.Address, clear); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the clipboard won't be cleared: %v\n", err)
+		return fmt.Sprintf("\n(copied to clipboard via %s)", backend)
+	}
+	res.ClearAfter = int(clear / time.Second)
+	return fmt.Sprintf("\n(copied to clipboard via %s; cleared in %ds)", backend, res.ClearAfter)
+}
+
+// scheduleClipboardClear starts 'ddg __clipboard-clear' in the background
+// to wipe text from the clipboard after d, unless something else has been
+// copied by then. The text goes over stdin, so it doesn't show up in ps.
+func scheduleClipboardClear(backend, sel, text string, d time.Duration) error {
+	if r := clipboardReaders[backend]; r.clipboard == nil {
+		return fmt.Errorf("%s can't read the clipboard back to check it still holds the address", backend)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self, clipboardClearCommand, backend, orDefault(sel, selClipboard), strconv.Itoa(int(d/time.Second)))
+	// Its own session, so closing the terminal doesn't cancel it.
+	detachProcess(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(stdin, text); err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	_ = stdin.Close()
+	return cmd.Process.Release()
+}
+
+// doClipboardClear handles 'ddg __clipboard-clear <backend> <selection>
+// <seconds>' with the copied text on stdin. Nobody sees its output.
+func doClipboardClear(args []string) {
+	if len(args) != 3 {
+		exitErr(fmt.Errorf("usage: ddg %s <backend> <selection> <seconds>", clipboardClearCommand))
+	}
+	b, ok := clipboardBackendByName(args[0])
+	r := clipboardReaders[args[0]]
+	secs, err := strconv.Atoi(args[2])
+	if !ok || r.clipboard == nil || err != nil {
+		exitErr(fmt.Errorf("usage: ddg %s <backend> <selection> <seconds>", clipboardClearCommand))
+	}
+	text, err := io.ReadAll(os.Stdin)
+	if err != nil || len(text) == 0 {
+		exitErr(fmt.Errorf("nothing to clear"))
+	}
+	time.Sleep(time.Duration(secs) * time.Second)
+
+	sels := []string{args[1]}
+	if args[1] == selBoth {
+		sels = []string{selClipboard, selPrimary}
+	}
+	for _, sel := range sels {
+		read := r.clipboard
+		if sel == selPrimary && b.primary != nil {
+			read = r.primary
+		}
+		got, err := read()
+		// Tools add a newline here and there.
+		if err != nil || strings.TrimRight(got, "\r\n") != string(text) {
+			continue
+		}
+		_ = b.copySelection(sel, "")
+	}
+}
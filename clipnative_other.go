@@ -0,0 +1,27 @@
+//go:build !linux && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Without cgo there is no way to reach the macOS pasteboard, and pbcopy is
+// always there anyway.
+
+func nativeAvailable() bool { return false }
+
+func copyNative(string) error {
+	return fmt.Errorf("no native clipboard on %s", runtime.GOOS)
+}
+
+var nativePrimary func(string) error
+
+func readNative(string) (string, error) {
+	return "", fmt.Errorf("no native clipboard on %s", runtime.GOOS)
+}
+
+func doClipboardOwner([]string) {
+	exitErr(fmt.Errorf("no native clipboard on %s", runtime.GOOS))
+}
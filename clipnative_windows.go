@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procOpenClipboard    = syscall.NewLazyDLL("user32.dll").NewProc("OpenClipboard")
+	procCloseClipboard   = syscall.NewLazyDLL("user32.dll").NewProc("CloseClipboard")
+	procEmptyClipboard   = syscall.NewLazyDLL("user32.dll").NewProc("EmptyClipboard")
+	procSetClipboardData = syscall.NewLazyDLL("user32.dll").NewProc("SetClipboardData")
+	procGlobalAlloc      = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalAlloc")
+	procGetClipboardData = syscall.NewLazyDLL("user32.dll").NewProc("GetClipboardData")
+	procGlobalFree       = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalFree")
+	procGlobalSize       = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalSize")
+	procGlobalLock       = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalLock")
+	procGlobalUnlock     = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalUnlock")
+	procRtlMoveMemory    = syscall.NewLazyDLL("kernel32.dll").NewProc("RtlMoveMemory")
+)
+
+func nativeAvailable() bool { return procSetClipboardData.Find() == nil }
+
+// copyNative puts text on the clipboard through user32, as CF_UNICODETEXT.
+func copyNative(text string) error {
+	const cfUnicodeText, gmemMoveable = 13, 0x0002
+	u, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	// Another program may hold the clipboard for a moment.
+	var r uintptr
+	for i := 0; i < 10; i++ {
+		if r, _, err = procOpenClipboard.Call(0); r != 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if r == 0 {
+		return fmt.Errorf("OpenClipboard: %w", err)
+	}
+	defer procCloseClipboard.Call()
+	if r, _, err := procEmptyClipboard.Call(); r == 0 {
+		return fmt.Errorf("EmptyClipboard: %w", err)
+	}
+	size := uintptr(len(u) * 2)
+	h, _, err := procGlobalAlloc.Call(gmemMoveable, size)
+	if h == 0 {
+		return fmt.Errorf("GlobalAlloc: %w", err)
+	}
+	p, _, err := procGlobalLock.Call(h)
+	if p == 0 {
+		procGlobalFree.Call(h)
+		return fmt.Errorf("GlobalLock: %w", err)
+	}
+	procRtlMoveMemory.Call(p, uintptr(unsafe.Pointer(&u[0])), size)
+	procGlobalUnlock.Call(h)
+	// On success the clipboard owns the memory.
+	if r, _, err := procSetClipboardData.Call(cfUnicodeText, h); r == 0 {
+		procGlobalFree.Call(h)
+		return fmt.Errorf("SetClipboardData: %w", err)
+	}
+	return nil
+}
+
+// readNative returns the clipboard's text; sel is ignored, as Windows has
+// only the one.
+func readNative(string) (string, error) {
+	const cfUnicodeText = 13
+	if r, _, err := procOpenClipboard.Call(0); r == 0 {
+		return "", fmt.Errorf("OpenClipboard: %w", err)
+	}
+	defer procCloseClipboard.Call()
+	h, _, err := procGetClipboardData.Call(cfUnicodeText)
+	if h == 0 {
+		return "", fmt.Errorf("GetClipboardData: %w", err)
+	}
+	size, _, _ := procGlobalSize.Call(h)
+	p, _, err := procGlobalLock.Call(h)
+	if p == 0 {
+		return "", fmt.Errorf("GlobalLock: %w", err)
+	}
+	defer procGlobalUnlock.Call(h)
+	u := make([]uint16, size/2)
+	if len(u) > 0 {
+		procRtlMoveMemory.Call(uintptr(unsafe.Pointer(&u[0])), p, uintptr(len(u)*2))
+	}
+	return syscall.UTF16ToString(u), nil
+}
+
+// Windows has no primary selection.
+var nativePrimary func(string) error
+
+func doClipboardOwner([]string) {
+	exitErr(fmt.Errorf("the Windows clipboard needs no owner process"))
+}
@@ -0,0 +1,473 @@
+//go:build linux || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// On X11 the clipboard is owned, not written: whoever set it has to stay
+// around to hand the text to every program that pastes. So copying starts
+// 'ddg __clipboard-owner' in the background, which speaks the X11 protocol
+// itself, takes the selection, and serves it until something else is
+// copied.
+
+func nativeAvailable() bool { return os.Getenv("DISPLAY") != "" }
+
+func copyNative(text string) error { return ownSelection("clipboard", text) }
+
+var nativePrimary = func(text string) error { return ownSelection("primary", text) }
+
+// maxSelection keeps the text within one X11 request.
+const maxSelection = 200 << 10
+
+// ownSelection starts the owner for sel and waits until it holds it.
+func ownSelection(sel, text string) error {
+	if len(text) > maxSelection {
+		return fmt.Errorf("%d bytes is more than the native clipboard takes", len(text))
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self, clipboardOwnerCommand, sel)
+	cmd.Stdin = strings.NewReader(text)
+	// Its own session, so closing the terminal doesn't empty the clipboard.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	if line = strings.TrimSpace(line); line != "ok" {
+		_ = cmd.Wait()
+		return errors.New(orDefault(line, "the clipboard owner exited"))
+	}
+	return cmd.Process.Release()
+}
+
+// doClipboardOwner takes the selection named by args[0] with the text on
+// stdin, says "ok" (or what went wrong) on stdout, and serves it until
+// another program takes the selection over.
+func doClipboardOwner(args []string) {
+	fail := func(err error) {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(args) != 1 || (args[0] != "clipboard" && args[0] != "primary") {
+		fail(fmt.Errorf("usage: ddg %s clipboard|primary", clipboardOwnerCommand))
+	}
+	text, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+	x, err := dialX11(os.Getenv("DISPLAY"))
+	if err != nil {
+		fail(fmt.Errorf("X11 display %q: %w", os.Getenv("DISPLAY"), err))
+	}
+	defer x.conn.Close()
+	owner, err := x.ownSelection(args[0])
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println("ok")
+	os.Stdout.Close()
+	owner.serve(text)
+}
+
+// Predefined atoms.
+const (
+	x11AtomPrimary = 1
+	x11AtomAtom    = 4
+	x11AtomString  = 31
+)
+
+// x11Conn is a connection to an X server, enough of the protocol to own a
+// selection.
+type x11Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	root   uint32
+	idBase uint32
+}
+
+// dialX11 connects to display (":0", "unix:1.0", "host:10") and
+// authenticates with its cookie from the Xauthority file, if there is one.
+func dialX11(display string) (*x11Conn, error) {
+	i := strings.LastIndexByte(display, ':')
+	if i < 0 {
+		return nil, errors.New("not host:display")
+	}
+	host, num := display[:i], display[i+1:]
+	num, _, _ = strings.Cut(num, ".")
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return nil, errors.New("not host:display")
+	}
+	var c net.Conn
+	if host == "" || host == "unix" {
+		path := "/tmp/.X11-unix/X" + num
+		if c, err = net.Dial("unix", path); err != nil {
+			// Some servers only listen in the abstract namespace.
+			if ac, aerr := net.Dial("unix", "@"+path); aerr == nil {
+				c, err = ac, nil
+			}
+		}
+	} else {
+		c, err = net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	x := &x11Conn{conn: c, r: bufio.NewReader(c)}
+	if err := x.setup(x11Cookie(num)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+// x11Cookie returns the MIT-MAGIC-COOKIE-1 for display number num from
+// $XAUTHORITY or ~/.Xauthority: one for this host, or for any.
+func x11Cookie(num string) []byte {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".Xauthority")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	hostname, _ := os.Hostname()
+	field := func() string {
+		if len(b) < 2 {
+			b = nil
+			return ""
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			b = nil
+			return ""
+		}
+		s := string(b[2 : 2+n])
+		b = b[2+n:]
+		return s
+	}
+	const familyLocal, familyWild = 256, 65535
+	for len(b) >= 2 {
+		family := binary.BigEndian.Uint16(b)
+		b = b[2:]
+		addr, dpy, name, data := field(), field(), field(), field()
+		if dpy != num || name != "MIT-MAGIC-COOKIE-1" {
+			continue
+		}
+		if family == familyWild || family != familyLocal || addr == hostname {
+			return []byte(data)
+		}
+	}
+	return nil
+}
+
+func x11Pad(n int) int { return (4 - n%4) % 4 }
+
+func (x *x11Conn) setup(cookie []byte) error {
+	name := "MIT-MAGIC-COOKIE-1"
+	if cookie == nil {
+		name = ""
+	}
+	req := []byte{'l', 0, 11, 0, 0, 0}
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(name)))
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(cookie)))
+	req = append(req, 0, 0)
+	req = append(req, name...)
+	req = append(req, make([]byte, x11Pad(len(name)))...)
+	req = append(req, cookie...)
+	req = append(req, make([]byte, x11Pad(len(cookie)))...)
+	if _, err := x.conn.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(x.r, head); err != nil {
+		return err
+	}
+	data := make([]byte, int(binary.LittleEndian.Uint16(head[6:]))*4)
+	if _, err := io.ReadFull(x.r, data); err != nil {
+		return err
+	}
+	switch head[0] {
+	case 1:
+	case 0:
+		reason := data
+		if int(head[1]) <= len(reason) {
+			reason = reason[:head[1]]
+		}
+		return fmt.Errorf("refused: %s", strings.TrimSpace(string(reason)))
+	default:
+		return errors.New("the server wants more authentication than a cookie")
+	}
+	if len(data) < 32 {
+		return errors.New("short setup reply")
+	}
+	x.idBase = binary.LittleEndian.Uint32(data[4:])
+	vendor := int(binary.LittleEndian.Uint16(data[16:]))
+	screens := 32 + vendor + x11Pad(vendor) + 8*int(data[21])
+	if data[20] == 0 || len(data) < screens+4 {
+		return errors.New("no screens")
+	}
+	x.root = binary.LittleEndian.Uint32(data[screens:])
+	return nil
+}
+
+// send writes one request; words are its 32-bit fields after the header.
+func (x *x11Conn) send(opcode, detail byte, words []uint32, tail []byte) error {
+	n := 1 + len(words) + (len(tail)+x11Pad(len(tail)))/4
+	req := []byte{opcode, detail}
+	req = binary.LittleEndian.AppendUint16(req, uint16(n))
+	for _, w := range words {
+		req = binary.LittleEndian.AppendUint32(req, w)
+	}
+	req = append(req, tail...)
+	req = append(req, make([]byte, x11Pad(len(tail)))...)
+	_, err := x.conn.Write(req)
+	return err
+}
+
+// next reads one reply, error or event.
+func (x *x11Conn) next() ([]byte, error) {
+	msg := make([]byte, 32)
+	if _, err := io.ReadFull(x.r, msg); err != nil {
+		return nil, err
+	}
+	if msg[0] == 1 {
+		extra := make([]byte, int(binary.LittleEndian.Uint32(msg[4:]))*4)
+		if _, err := io.ReadFull(x.r, extra); err != nil {
+			return nil, err
+		}
+		msg = append(msg, extra...)
+	}
+	return msg, nil
+}
+
+// reply waits for the reply to the last request, skipping events.
+func (x *x11Conn) reply() ([]byte, error) {
+	for {
+		msg, err := x.next()
+		if err != nil {
+			return nil, err
+		}
+		switch msg[0] {
+		case 0:
+			return nil, fmt.Errorf("X11 error %d", msg[1])
+		case 1:
+			return msg, nil
+		}
+	}
+}
+
+func (x *x11Conn) internAtom(name string) (uint32, error) {
+	tail := binary.LittleEndian.AppendUint16(nil, uint16(len(name)))
+	tail = append(tail, 0, 0)
+	tail = append(tail, name...)
+	// InternAtom's header word holds the name length, so it goes in tail.
+	if err := x.send(16, 0, nil, tail); err != nil {
+		return 0, err
+	}
+	msg, err := x.reply()
+	if err != nil {
+		return 0, fmt.Errorf("interning %s: %w", name, err)
+	}
+	return binary.LittleEndian.Uint32(msg[8:]), nil
+}
+
+// selectionOwner serves one selection from a window of its own.
+type selectionOwner struct {
+	x                          *x11Conn
+	window, selection          uint32
+	targets, utf8, text, plain uint32
+}
+
+// ownSelection makes a window and has it take the clipboard or primary
+// selection.
+func (x *x11Conn) ownSelection(sel string) (*selectionOwner, error) {
+	o := &selectionOwner{x: x, window: x.idBase | 1, selection: x11AtomPrimary}
+	atoms := []struct {
+		name string
+		to   *uint32
+	}{{"TARGETS", &o.targets}, {"UTF8_STRING", &o.utf8}, {"TEXT", &o.text}, {"text/plain;charset=utf-8", &o.plain}}
+	if sel == "clipboard" {
+		atoms = append(atoms, struct {
+			name string
+			to   *uint32
+		}{"CLIPBOARD", &o.selection})
+	}
+	for _, a := range atoms {
+		id, err := x.internAtom(a.name)
+		if err != nil {
+			return nil, err
+		}
+		*a.to = id
+	}
+	// An InputOnly 1x1 window at 0,0 that is never mapped.
+	const inputOnly = 2
+	if err := x.send(1, 0, []uint32{o.window, x.root, 0, 1 | 1<<16, inputOnly << 16, 0, 0}, nil); err != nil {
+		return nil, err
+	}
+	if err := x.send(22, 0, []uint32{o.window, o.selection, 0}, nil); err != nil {
+		return nil, err
+	}
+	if err := x.send(23, 0, []uint32{o.selection}, nil); err != nil {
+		return nil, err
+	}
+	msg, err := x.reply()
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(msg[8:]) != o.window {
+		return nil, errors.New("another program kept the selection")
+	}
+	return o, nil
+}
+
+// serve answers requests for the selection until it's taken over or the
+// connection goes away.
+func (o *selectionOwner) serve(text []byte) {
+	const selectionClear, selectionRequest, selectionNotify = 29, 30, 31
+	for {
+		msg, err := o.x.next()
+		if err != nil {
+			return
+		}
+		switch msg[0] & 0x7f {
+		case selectionClear:
+			return
+		case selectionRequest:
+			t := binary.LittleEndian.Uint32(msg[4:])
+			requestor := binary.LittleEndian.Uint32(msg[12:])
+			target := binary.LittleEndian.Uint32(msg[20:])
+			property := binary.LittleEndian.Uint32(msg[24:])
+			if property == 0 {
+				property = target // from clients older than ICCCM 2
+			}
+			if !o.answer(requestor, target, property, text) {
+				property = 0
+			}
+			ev := []byte{selectionNotify, 0, 0, 0}
+			for _, w := range []uint32{t, requestor, o.selection, target, property} {
+				ev = binary.LittleEndian.AppendUint32(ev, w)
+			}
+			ev = append(ev, make([]byte, 32-len(ev))...)
+			_ = o.x.send(25, 0, []uint32{requestor, 0}, ev)
+		}
+	}
+}
+
+// answer stores the selection in the requestor's property, as target asks,
+// and reports whether it could.
+func (o *selectionOwner) answer(requestor, target, property uint32, text []byte) bool {
+	changeProperty := func(typ uint32, format byte, data []byte, units int) bool {
+		const replace = 0
+		tail := append([]byte{format, 0, 0, 0}, binary.LittleEndian.AppendUint32(nil, uint32(units))...)
+		tail = append(tail, data...)
+		return o.x.send(18, replace, []uint32{requestor, property, typ}, tail) == nil
+	}
+	switch target {
+	case o.targets:
+		var list []byte
+		for _, a := range []uint32{o.targets, o.utf8, o.plain, o.text, x11AtomString} {
+			list = binary.LittleEndian.AppendUint32(list, a)
+		}
+		return changeProperty(x11AtomAtom, 32, list, len(list)/4)
+	case o.utf8, o.text, o.plain:
+		typ := target
+		if target == o.text {
+			typ = o.utf8
+		}
+		return changeProperty(typ, 8, text, len(text))
+	case x11AtomString:
+		return changeProperty(x11AtomString, 8, text, len(text))
+	}
+	return false
+}
+
+// readNative asks whoever owns sel ("clipboard" or "primary") for it as
+// UTF-8 text. An unowned selection reads as empty.
+func readNative(sel string) (string, error) {
+	x, err := dialX11(os.Getenv("DISPLAY"))
+	if err != nil {
+		return "", fmt.Errorf("X11 display %q: %w", os.Getenv("DISPLAY"), err)
+	}
+	defer x.conn.Close()
+	selection := uint32(x11AtomPrimary)
+	if sel == "clipboard" {
+		if selection, err = x.internAtom("CLIPBOARD"); err != nil {
+			return "", err
+		}
+	}
+	utf8, err := x.internAtom("UTF8_STRING")
+	if err != nil {
+		return "", err
+	}
+	property, err := x.internAtom("DDG_SELECTION")
+	if err != nil {
+		return "", err
+	}
+	window := x.idBase | 1
+	const inputOnly = 2
+	if err := x.send(1, 0, []uint32{window, x.root, 0, 1 | 1<<16, inputOnly << 16, 0, 0}, nil); err != nil {
+		return "", err
+	}
+	// ConvertSelection; the owner answers with a SelectionNotify.
+	if err := x.send(24, 0, []uint32{window, selection, utf8, property, 0}, nil); err != nil {
+		return "", err
+	}
+	const selectionNotify = 31
+	_ = x.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		msg, err := x.next()
+		if err != nil {
+			return "", err
+		}
+		if msg[0] == 0 {
+			return "", fmt.Errorf("X11 error %d", msg[1])
+		}
+		if msg[0]&0x7f != selectionNotify {
+			continue
+		}
+		if binary.LittleEndian.Uint32(msg[20:]) == 0 {
+			return "", nil // no owner, or it can't give text
+		}
+		break
+	}
+	// GetProperty, deleting it once read.
+	if err := x.send(20, 1, []uint32{window, property, 0, 0, maxSelection / 4}, nil); err != nil {
+		return "", err
+	}
+	msg, err := x.reply()
+	if err != nil {
+		return "", err
+	}
+	if msg[1] != 8 {
+		return "", nil // INCR, or not text: not an alias anyway
+	}
+	n := int(binary.LittleEndian.Uint32(msg[16:]))
+	if 32+n > len(msg) {
+		return "", errors.New("short X11 property reply")
+	}
+	return string(msg[32 : 32+n]), nil
+}
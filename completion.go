@@ -0,0 +1,598 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cmdSpec describes a command for completion specs. It mirrors the dispatch
+// in main and the help text, so keep the three in step.
+type cmdSpec struct {
+	Name    string
+	Aliases []string
+	Desc    string
+	Arg     string // name of the positional argument, if any
+	ArgFile bool   // the positional argument is a path
+	ArgDyn  string // completeKinds entry that completes the argument
+	Flags   []flagSpec
+	Subs    []cmdSpec
+}
+
+type flagSpec struct {
+	Name   string // long form, e.g. "--output"
+	Short  string // e.g. "-o"
+	Arg    string // value name; empty for switches
+	File   bool   // the value is a path
+	Dyn    string // completeKinds entry that completes the value
+	Values []string
+	Desc   string
+}
+
+var globalFlags = []flagSpec{
+	{Name: "--read-only", Desc: "Only allow commands that read local state"},
+	{Name: "--yes", Desc: "Answer yes to confirmations"},
+	{Name: "--quiet", Short: "-q", Desc: "Print only the bare value, e.g. the address"},
+	{Name: "--output", Short: "-o", Arg: "format", Values: []string{outputPlain, outputColor, outputJSON, outputJSONL, outputTemplate, outputVim, outputQuiet}, Desc: "Output format"},
+	{Name: "--json", Desc: "Same as -o json"},
+	{Name: "--template", Arg: "tmpl", Desc: "Go template applied to each result"},
+	{Name: "--profile", Arg: "name", Desc: "Use a separate profile"},
+	{Name: "--record-fixtures", Arg: "dir", File: true, Desc: "Save every API exchange to a directory"},
+	{Name: "--replay-fixtures", Arg: "dir", File: true, Desc: "Answer API calls from recorded fixtures"},
+}
+
+var commandTree = []cmdSpec{
+	{Name: "gen", Aliases: []string{"generate"}, Desc: "Generate new Duck email", Flags: []flagSpec{
+		{Name: "--override", Desc: "Go over a generation budget once"},
+		{Name: "--for", Arg: "label", Dyn: "labels", Desc: "Label the alias with the site it's for"},
+		{Name: "--label", Arg: "label", Dyn: "labels", Desc: "Same as --for"},
+		{Name: "--note", Arg: "text", Desc: "Note why the alias was made (repeatable)"},
+		{Name: "--field", Arg: "key=value", Desc: "Attach a custom field"},
+		{Name: "--remind", Arg: "when", Desc: "Set a review date (90d, YYYY-MM-DD)"},
+		{Name: "--qr", Desc: "Also show the alias as a QR code"},
+		{Name: "--clear-after", Arg: "duration", Desc: "Wipe it off the clipboard after this long"},
+		{Name: "--count", Short: "-n", Arg: "n", Desc: "Generate n aliases at once"},
+		{Name: "--namespace", Arg: "name", Dyn: "namespaces", Desc: "Tag them for a test run"},
+		{Name: "--resume", Desc: "Finish an interrupted -n run"},
+		{Name: "--restart", Desc: "Discard an interrupted -n run and start over"},
+	}},
+	{Name: "settings", Desc: "View or change settings", Flags: []flagSpec{
+		{Name: "--apikey", Arg: "key", Desc: "Set the API key"},
+		{Name: "--clipboard", Arg: "yes|no", Values: []string{"yes", "no"}, Desc: "Enable or disable clipboard copying"},
+		{Name: "--ddggen", Arg: "yes|no", Values: []string{"yes", "no"}, Desc: "Generate when run without a command"},
+		{Name: "--clipboard-backends", Arg: "list", Desc: "Clipboard backends to try in order"},
+		{Name: "--clipboard-clear-seconds", Arg: "n", Desc: "Wipe generated aliases off the clipboard after n seconds"},
+		{Name: "--selection", Arg: "target", Values: []string{"clipboard", "primary", "both"}, Desc: "Which X11/Wayland selection to set"},
+		{Name: "--quiet-mode", Arg: "yes|no", Values: []string{"yes", "no"}, Desc: "Make -q the default output"},
+		{Name: "--alias", Arg: "name=command", Desc: "Add a command alias (empty command removes it)"},
+		{Name: "--readonly", Arg: "yes|no", Values: []string{"yes", "no"}, Desc: "Enable read-only mode"},
+		{Name: "--pre-generate-hook", Arg: "cmd", Desc: "Command that can veto a generation"},
+		{Name: "--max-per-day", Arg: "n", Desc: "Daily generation limit"},
+		{Name: "--max-per-week", Arg: "n", Desc: "Weekly generation limit"},
+		{Name: "--weekly-summary", Arg: "notify|email|no", Values: []string{"notify", "email", "no"}, Desc: "Weekly summary from the daemon"},
+		{Name: "--mail-to", Arg: "address", Desc: "Mail every generated alias to yourself"},
+		{Name: "--smtp-server", Arg: "host:port", Desc: "SMTP server for --mail-to (default: sendmail)"},
+		{Name: "--smtp-user", Arg: "name", Desc: "SMTP login; password from DDG_SMTP_PASSWORD"},
+		{Name: "--imap-server", Arg: "host[:port]", Desc: "IMAP server whose mail is counted per alias"},
+		{Name: "--imap-user", Arg: "name", Desc: "IMAP login; password from 'ddg imap login' or DDG_IMAP_PASSWORD"},
+		{Name: "--imap-mailbox", Arg: "name", Desc: "IMAP folder to watch (default INBOX)"},
+		{Name: "--alert-per-day", Arg: "n", Desc: "Alert when an alias gets n messages in a day"},
+		{Name: "--notification", Arg: "sink=target", Desc: "Push daemon events to ntfy, Pushover, Matrix or a webhook"},
+		{Name: "--egress", Arg: "open|strict", Values: []string{"open", "strict"}, Desc: "Only connect to allow-listed hosts"},
+		{Name: "--egress-allow", Arg: "hosts", Desc: "Hosts strict egress also allows"},
+		{Name: "--log-target", Arg: "target", Values: []string{"stderr", "journald", "syslog"}, Desc: "Where the daemon logs"},
+	}},
+	{Name: "config", Desc: "Config backups", Subs: []cmdSpec{
+		{Name: "backups", Desc: "List config backups"},
+		{Name: "rollback", Desc: "Restore a backup (1 is the newest)", Arg: "n"},
+	}},
+	{Name: "token", Desc: "Show, set, validate, delete or migrate the API key", Subs: []cmdSpec{
+		{Name: "show", Desc: "Show the stored key", Flags: []flagSpec{{Name: "--reveal", Desc: "Don't mask the key"}}},
+		{Name: "set", Desc: "Store a new key", Arg: "key"},
+		{Name: "validate", Desc: "Check the key against DuckDuckGo"},
+		{Name: "delete", Desc: "Remove the stored key"},
+		{Name: "migrate", Desc: "Move the key to another keystore", Flags: []flagSpec{
+			{Name: "--to", Arg: "keystore", Values: []string{keystoreFile, keystoreKeychain}, Desc: "Target keystore"},
+		}},
+	}},
+	{Name: "list", Aliases: []string{"ls"}, Desc: "Aliases in history, newest first", Flags: []flagSpec{
+		{Name: "--since", Arg: "when", Desc: "Only aliases made since (7d, YYYY-MM-DD)"},
+		{Name: "--tag", Arg: "tag", Desc: "Only aliases with this tag"},
+		{Name: "--namespace", Arg: "name", Dyn: "namespaces", Desc: "Only aliases in this namespace"},
+		{Name: "--limit", Arg: "n", Desc: "Show at most n"},
+	}},
+	{Name: "search", Desc: "Find aliases by address, label, tags, notes or fields", Arg: "term"},
+	{Name: "show", Desc: "Everything history has on one alias", Arg: "n|address|label", ArgDyn: "aliases"},
+	{Name: "history", Desc: "Clean up or merge alias history", Subs: []cmdSpec{
+		{Name: "dedupe", Desc: "Collapse duplicate entries"},
+		{Name: "purge", Desc: "Remove deleted aliases for good"},
+		{Name: "merge", Desc: "Union another history file into this one", Arg: "file", ArgFile: true},
+	}},
+	{Name: "import", Desc: "Backfill history from a password manager export", Arg: "csv", ArgFile: true, Flags: []flagSpec{
+		{Name: "--format", Arg: "format", Values: []string{"lastpass", "bitwarden", "1password-csv"}, Desc: "Which password manager exported it"},
+	}},
+	{Name: "scan-browser", Desc: "Find aliases in browser saved logins", Flags: []flagSpec{
+		{Name: "--browser-profile", Arg: "dir", File: true, Desc: "Chrome or Firefox profile directory (repeatable)"},
+	}},
+	{Name: "scan-mail", Desc: "See which aliases get mail, from a Maildir or mbox", Arg: "mailbox", ArgFile: true, Flags: []flagSpec{
+		{Name: "--dry-run", Desc: "Report without annotating history"},
+	}},
+	{Name: "imap", Desc: "Count mail per alias from an IMAP mailbox", Subs: []cmdSpec{
+		{Name: "watch", Desc: "Count new mail, and keep counting", Flags: []flagSpec{
+			{Name: "--once", Desc: "Sync once and exit"},
+			{Name: "--every", Arg: "duration", Desc: "How often to check (default 2m)"},
+		}},
+		{Name: "login", Desc: "Keep the IMAP password in the keychain"},
+		{Name: "logout", Desc: "Remove the IMAP password from the keychain"},
+	}},
+	{Name: "noisiest", Desc: "Aliases that got the most mail lately", Flags: []flagSpec{
+		{Name: "--days", Arg: "n", Desc: "How far back to count (default 30)"},
+		{Name: "--top", Arg: "n", Desc: "How many to show (default 10)"},
+	}},
+	{Name: "report", Desc: "Reports on alias mail", Subs: []cmdSpec{
+		{Name: "leaks", Desc: "Aliases getting mail from senders unrelated to their label", Flags: []flagSpec{
+			{Name: "--top", Arg: "n", Desc: "Show only the n worst"},
+		}},
+	}},
+	{Name: "provision", Desc: "Generate one labelled alias per CSV row", Arg: "csv", ArgFile: true, Flags: []flagSpec{
+		{Name: "--delay", Arg: "duration", Desc: "Pause between generations"},
+		{Name: "--parallel", Arg: "n", Desc: "Requests at a time"},
+		{Name: "--label-template", Arg: "tmpl", Desc: "Template for each label"},
+		{Name: "--resume", Desc: "Skip labels already done"},
+		{Name: "--restart", Desc: "Discard the journal and start over"},
+		{Name: "--override", Desc: "Go over a generation budget"},
+	}},
+	{Name: "serve", Desc: "Run a local HTTP daemon that hands out aliases", Flags: []flagSpec{
+		{Name: "--addr", Arg: "host:port", Desc: "Listen address"},
+		{Name: "--advertise", Desc: "Announce the daemon via Bonjour/mDNS"},
+		{Name: "--no-sandbox", Desc: "Don't restrict the daemon and its hooks"},
+	}, Subs: []cmdSpec{
+		{Name: "users", Desc: "Manage daemon users", Subs: []cmdSpec{
+			{Name: "add", Desc: "Add a user", Arg: "name"},
+			{Name: "list", Desc: "List users"},
+			{Name: "remove", Desc: "Remove a user", Arg: "name"},
+		}},
+		{Name: "keys", Desc: "Manage scoped keys", Subs: []cmdSpec{
+			{Name: "add", Desc: "Issue a scoped key", Arg: "name", Flags: []flagSpec{
+				{Name: "--scope", Arg: "scopes", Desc: "Comma-separated: " + scopeGenerate + ", " + scopeHistory + ", " + scopeEvents},
+				{Name: "--user", Arg: "name", Desc: "User the key acts for"},
+			}},
+			{Name: "list", Desc: "List keys"},
+			{Name: "remove", Desc: "Remove a key", Arg: "name"},
+		}},
+		{Name: "bookmarklet", Desc: "Print a bookmarklet that fills the focused field", Flags: []flagSpec{
+			{Name: "--addr", Arg: "host:port", Desc: "Daemon address"},
+		}},
+	}},
+	{Name: "pair", Desc: "Show a QR code that gives a phone its own daemon key", Flags: []flagSpec{
+		{Name: "--user", Arg: "name", Desc: "Daemon user the phone generates as"},
+		{Name: "--addr", Arg: "host:port", Desc: "Address the phone should use"},
+	}},
+	{Name: "install-windows-service", Desc: "Run the daemon as a Windows service", Flags: []flagSpec{
+		{Name: "--addr", Arg: "host:port", Desc: "Address the daemon listens on"},
+		{Name: "--user", Arg: "account", Desc: "Account the service runs as"},
+		{Name: "--remove", Desc: "Stop and remove the service"},
+	}},
+	{Name: "client", Desc: "Ask a running daemon for an alias", Flags: []flagSpec{
+		{Name: "--token", Arg: "token", Desc: "Daemon key"},
+	}, Subs: []cmdSpec{
+		{Name: "gen", Desc: "Generate via the daemon", Flags: []flagSpec{{Name: "--label", Arg: "label", Desc: "Label for the alias"}}},
+		{Name: "list", Desc: "Show the daemon's history"},
+		{Name: "status", Desc: "Show the daemon's health"},
+	}},
+	{Name: "agent", Desc: "Forward this machine's daemon to another over SSH", Subs: []cmdSpec{
+		{Name: "ssh", Desc: "Log in with the daemon socket forwarded", Arg: "host"},
+	}},
+	{Name: "remote", Desc: "Generate an alias on another machine over SSH", Arg: "host", Flags: []flagSpec{
+		{Name: "--ddg", Arg: "path", Desc: "ddg on the remote machine"},
+	}},
+	{Name: "register-url-handler", Desc: "Open ddg:// links with ddg", Flags: []flagSpec{
+		{Name: "--remove", Desc: "Unregister the handler"},
+	}},
+	{Name: "mutt-query", Desc: "Complete aliases in mail clients", Arg: "query", Flags: []flagSpec{
+		{Name: "--no-header", Desc: "Leave out the header line (aerc)"},
+	}},
+	{Name: "rpc", Desc: "JSON-RPC 2.0 on stdio for editor extensions"},
+	{Name: "launcher", Desc: "Feed Alfred, Raycast, rofi or Walker", Arg: "query", Flags: []flagSpec{
+		{Name: "--protocol", Arg: "name", Values: launcherProtocols(), Desc: "Launcher format"},
+		{Name: "--run", Arg: "arg", Desc: "Act on a picked row"},
+	}},
+	{Name: "remind", Desc: "Alias review dates", Subs: []cmdSpec{
+		{Name: "list", Desc: "Aliases with a review date"},
+		{Name: "set", Desc: "Set a review date", Arg: "address", ArgDyn: "aliases"},
+		{Name: "export", Desc: "iCalendar file for any calendar app", Flags: []flagSpec{
+			{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+		}},
+		{Name: "push", Desc: "Add them to the macOS Reminders app"},
+	}},
+	{Name: "export", Desc: "History as CSV for a password manager to import", Flags: []flagSpec{
+		{Name: "--format", Arg: "format", Values: []string{"csv", "bitwarden", "1password"}, Desc: "Which password manager imports it"},
+		{Name: "--tag", Arg: "tag", Desc: "Only aliases with this tag"},
+		{Name: "--out", Arg: "file", File: true, Desc: "Write to a file instead of stdout"},
+	}},
+	{Name: "sheet", Desc: "Printable table of labels and aliases", Flags: []flagSpec{
+		{Name: "--tag", Arg: "tag", Desc: "Only aliases with this tag"},
+		{Name: "--format", Arg: "format", Values: []string{"md", "html"}, Desc: "Document format"},
+		{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+	}},
+	{Name: "team", Desc: "Share who generated which alias with a team", Subs: []cmdSpec{
+		{Name: "join", Desc: "Share through a synced folder", Arg: "dir", ArgFile: true, Flags: []flagSpec{
+			{Name: "--as", Arg: "name", Desc: "Your name in the team"},
+			{Name: "--publish", Desc: "Also share your existing history"},
+		}},
+		{Name: "history", Desc: "Everyone's aliases", Arg: "search"},
+		{Name: "status", Desc: "Show the team setup"},
+		{Name: "leave", Desc: "Stop sharing"},
+	}},
+	{Name: "profile", Desc: "List, export or import profiles", Subs: []cmdSpec{
+		{Name: "list", Desc: "Show profiles"},
+		{Name: "export", Desc: "Pack a profile into a .tar.gz", Arg: "name", Flags: []flagSpec{
+			{Name: "--with-secrets", Desc: "Include the API key and signing keys"},
+			{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+		}},
+		{Name: "import", Desc: "Unpack an export as a profile", Arg: "file", ArgFile: true, Flags: []flagSpec{
+			{Name: "--as", Arg: "name", Desc: "Profile name"},
+			{Name: "--force", Desc: "Replace an existing profile"},
+		}},
+	}},
+	{Name: "audit", Desc: "Export the audit log and verify exports", Subs: []cmdSpec{
+		{Name: "export", Desc: "Write the audit log", Flags: []flagSpec{
+			{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+			{Name: "--sign", Desc: "Also write an HMAC signature"},
+		}},
+		{Name: "verify", Desc: "Check an export hasn't been altered", Arg: "file", ArgFile: true, Flags: []flagSpec{
+			{Name: "--sig", Arg: "file", File: true, Desc: "Signature file"},
+		}},
+	}},
+	{Name: "completion", Desc: "Completion specs for shells and terminals", Subs: []cmdSpec{
+		{Name: "spec", Desc: "Print a completion spec", Flags: []flagSpec{
+			{Name: "--format", Arg: "format", Values: []string{"fig", "carapace"}, Desc: "Spec format"},
+		}},
+	}},
+	{Name: "whoami", Desc: "Show your Duck address and where it forwards", Flags: []flagSpec{
+		{Name: "--refresh", Desc: "Ask DuckDuckGo instead of using the cache"},
+	}},
+	{Name: "summary", Desc: "This week's aliases generated, burned and due for review"},
+	{Name: "who", Desc: "Show what an alias was generated for", Arg: "address", ArgDyn: "aliases"},
+	{Name: "field", Desc: "Set custom fields on an alias", Arg: "address", ArgDyn: "aliases"},
+	{Name: "delete", Desc: "Hide an alias from history", Arg: "address", ArgDyn: "aliases", Flags: []flagSpec{
+		{Name: "--purge", Desc: "Remove it from history for good"},
+	}},
+	{Name: "exec", Desc: "Run a command with a new alias in {{DUCK}} and $DDG_EMAIL", Flags: []flagSpec{
+		{Name: "--for", Arg: "label", Dyn: "labels", Desc: "Label the alias with the site it's for"},
+		{Name: "--note", Arg: "text", Desc: "Note why the alias was made (repeatable)"},
+		{Name: "--field", Arg: "key=value", Desc: "Attach metadata (repeatable)"},
+	}},
+	{Name: "curlfill", Desc: "Fill a signup form with a new alias as a curl command", Arg: "url", Flags: []flagSpec{
+		{Name: "--field", Arg: "name", Desc: "The form field the alias goes in"},
+		{Name: "--set", Arg: "name=value", Desc: "Fill in another field (repeatable)"},
+		{Name: "--submit", Desc: "Send the form once confirmed"},
+	}},
+	{Name: "burn", Desc: "Mark an alias burned, deactivating it in the dashboard", Arg: "address", ArgDyn: "aliases", Flags: []flagSpec{
+		{Name: "--reason", Arg: "text", Desc: "Why it's burned"},
+		{Name: "--open", Desc: "Copy it and open the dashboard to deactivate it"},
+		{Name: "--namespace", Arg: "name", Dyn: "namespaces", Desc: "Burn every alias in a namespace"},
+	}},
+	{Name: "undelete", Desc: "Restore a deleted alias", Arg: "address", ArgDyn: "deleted"},
+	{Name: "restore-trash", Desc: "List or restore data discarded by reset and purges", Arg: "id"},
+	{Name: "open", Desc: "Copy the alias for a site and open its login page", Arg: "label", ArgDyn: "labels", Flags: []flagSpec{
+		{Name: "--signup", Desc: "Open the signup page instead"},
+	}},
+	{Name: "insights", Desc: "Local stats on your aliases"},
+	{Name: "reset", Desc: "Reset settings, token and/or history", Flags: []flagSpec{
+		{Name: "--settings", Desc: "Reset settings, keeping the API key"},
+		{Name: "--token", Desc: "Remove the API key"},
+		{Name: "--history", Desc: "Clear the alias history"},
+		{Name: "--all", Desc: "All of the above (the default)"},
+	}},
+	{Name: "prompt-segment", Desc: "Status snippet for shell prompts"},
+	{Name: "bench", Desc: "Time each step of generating an alias", Flags: []flagSpec{
+		{Name: "--n", Arg: "rounds", Desc: "How many rounds (default 5)"},
+		{Name: "--dry-run-network", Desc: "Time the API without generating aliases"},
+	}},
+	{Name: "bundle", Desc: "Build an offline install bundle", Flags: []flagSpec{
+		{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+		{Name: "--prefix", Arg: "dir", File: true, Desc: "Where the bundle will be installed (default /usr/local)"},
+	}},
+	{Name: "packaging", Desc: "Downstream package manifests", Subs: []cmdSpec{
+		{Name: "manifest", Desc: "Manifest for this version", Flags: []flagSpec{
+			{Name: "--type", Arg: "type", Values: []string{"homebrew", "scoop", "aur"}, Desc: "Package manager"},
+			{Name: "--sha256", Arg: "hex", Desc: "Checksum of the downloaded artifact"},
+			{Name: "--file", Arg: "path", File: true, Desc: "Artifact to checksum"},
+			{Name: "--out", Arg: "file", File: true, Desc: "Write to a file"},
+		}},
+	}},
+	{Name: "doctor", Desc: "Show diagnostics"},
+	{Name: "version", Desc: "Show the version"},
+	{Name: "help", Desc: "Show help"},
+}
+
+// specWriters render the command tree for 'ddg completion spec'.
+var specWriters = map[string]func(io.Writer) error{
+	"fig":      writeFigSpec,
+	"carapace": writeCarapaceSpec,
+}
+
+func doCompletion(args []string) {
+	if len(args) == 0 || args[0] != "spec" {
+		exitErr(fmt.Errorf("usage: ddg completion spec --format fig|carapace"))
+	}
+	format := ""
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--format" && i+1 < len(args) {
+			format = strings.ToLower(args[i+1])
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	write, ok := specWriters[format]
+	if !ok {
+		exitErr(fmt.Errorf("usage: ddg completion spec --format fig|carapace"))
+	}
+	if err := write(os.Stdout); err != nil {
+		exitErr(err)
+	}
+}
+
+// Fig (and Warp, Amazon Q) specs are TypeScript; the object itself is JSON.
+type figSpec struct {
+	Name        any         `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Subcommands []figSpec   `json:"subcommands,omitempty"`
+	Options     []figOption `json:"options,omitempty"`
+	Args        *figArg     `json:"args,omitempty"`
+}
+
+type figOption struct {
+	Name         any     `json:"name"`
+	Description  string  `json:"description,omitempty"`
+	IsPersistent bool    `json:"isPersistent,omitempty"`
+	Args         *figArg `json:"args,omitempty"`
+}
+
+type figArg struct {
+	Name        string        `json:"name"`
+	Template    string        `json:"template,omitempty"`
+	Suggestions []string      `json:"suggestions,omitempty"`
+	Generators  *figGenerator `json:"generators,omitempty"`
+	IsOptional  bool          `json:"isOptional,omitempty"`
+}
+
+type figGenerator struct {
+	Script  []string `json:"script"`
+	SplitOn string   `json:"splitOn"`
+}
+
+// figDynamic has Fig run 'ddg __complete <kind>' for suggestions.
+func figDynamic(kind string) *figGenerator {
+	if kind == "" {
+		return nil
+	}
+	return &figGenerator{Script: []string{"ddg", completeCommand, kind}, SplitOn: "\n"}
+}
+
+func writeFigSpec(w io.Writer) error {
+	root := figSpec{Name: "ddg", Description: "DuckDuckGo email alias generator"}
+	for _, f := range globalFlags {
+		o := figFlag(f)
+		o.IsPersistent = true
+		root.Options = append(root.Options, o)
+	}
+	for _, c := range commandTree {
+		root.Subcommands = append(root.Subcommands, figCommand(c))
+	}
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "const completionSpec: Fig.Spec = %s;\n\nexport default completionSpec;\n", b)
+	return err
+}
+
+func figCommand(c cmdSpec) figSpec {
+	s := figSpec{Name: c.Name, Description: c.Desc}
+	if len(c.Aliases) > 0 {
+		s.Name = append([]string{c.Name}, c.Aliases...)
+	}
+	if c.Arg != "" {
+		s.Args = &figArg{Name: c.Arg, IsOptional: len(c.Subs) > 0, Generators: figDynamic(c.ArgDyn)}
+		if c.ArgFile {
+			s.Args.Template = "filepaths"
+		}
+	}
+	for _, f := range c.Flags {
+		s.Options = append(s.Options, figFlag(f))
+	}
+	for _, sub := range c.Subs {
+		s.Subcommands = append(s.Subcommands, figCommand(sub))
+	}
+	return s
+}
+
+func figFlag(f flagSpec) figOption {
+	o := figOption{Name: f.Name, Description: f.Desc}
+	if f.Short != "" {
+		o.Name = []string{f.Short, f.Name}
+	}
+	if f.Arg != "" {
+		o.Args = &figArg{Name: f.Arg, Suggestions: f.Values, Generators: figDynamic(f.Dyn)}
+		if f.File {
+			o.Args.Template = "filepaths"
+		}
+	}
+	return o
+}
+
+// writeCarapaceSpec writes a carapace-spec YAML file. Every scalar is
+// double-quoted, which YAML reads the same as a Go quoted string for the
+// plain text used here.
+func writeCarapaceSpec(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# yaml-language-server: $schema=https://carapace.sh/schemas/command.json\n")
+	fmt.Fprintf(&b, "name: ddg\ndescription: %s\n", strconv.Quote("DuckDuckGo email alias generator"))
+	carapaceFlags(&b, "", "persistentflags", globalFlags)
+	carapaceCompletion(&b, "", cmdSpec{Flags: globalFlags})
+	if len(commandTree) > 0 {
+		b.WriteString("commands:\n")
+		for _, c := range commandTree {
+			carapaceCommand(&b, "  ", c)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func carapaceCommand(b *strings.Builder, indent string, c cmdSpec) {
+	fmt.Fprintf(b, "%s- name: %s\n", indent, c.Name)
+	in := indent + "  "
+	if len(c.Aliases) > 0 {
+		fmt.Fprintf(b, "%saliases: [%s]\n", in, quoteList(c.Aliases))
+	}
+	fmt.Fprintf(b, "%sdescription: %s\n", in, strconv.Quote(c.Desc))
+	carapaceFlags(b, in, "flags", c.Flags)
+	carapaceCompletion(b, in, c)
+	if len(c.Subs) > 0 {
+		fmt.Fprintf(b, "%scommands:\n", in)
+		for _, sub := range c.Subs {
+			carapaceCommand(b, in+"  ", sub)
+		}
+	}
+}
+
+func carapaceFlags(b *strings.Builder, indent, key string, flags []flagSpec) {
+	if len(flags) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", indent, key)
+	for _, f := range flags {
+		name := f.Name
+		if f.Short != "" {
+			name = f.Short + ", " + name
+		}
+		if f.Arg != "" {
+			name += "="
+		}
+		fmt.Fprintf(b, "%s  %s: %s\n", indent, name, strconv.Quote(f.Desc))
+	}
+}
+
+// carapaceCompletion lists fixed values, file arguments and values that
+// carapace gets by running 'ddg __complete <kind>'.
+func carapaceCompletion(b *strings.Builder, indent string, c cmdSpec) {
+	var flags []string
+	for _, f := range c.Flags {
+		name := strings.TrimPrefix(f.Name, "--")
+		switch {
+		case len(f.Values) > 0:
+			flags = append(flags, fmt.Sprintf("%s    %s: [%s]", indent, name, quoteList(f.Values)))
+		case f.File:
+			flags = append(flags, fmt.Sprintf("%s    %s: [\"$files\"]", indent, name))
+		case f.Dyn != "":
+			flags = append(flags, fmt.Sprintf("%s    %s: [%s]", indent, name, carapaceDynamic(f.Dyn)))
+		}
+	}
+	positional := ""
+	switch {
+	case c.ArgFile:
+		positional = `"$files"`
+	case c.ArgDyn != "":
+		positional = carapaceDynamic(c.ArgDyn)
+	}
+	if len(flags) == 0 && positional == "" {
+		return
+	}
+	fmt.Fprintf(b, "%scompletion:\n", indent)
+	if len(flags) > 0 {
+		fmt.Fprintf(b, "%s  flag:\n%s\n", indent, strings.Join(flags, "\n"))
+	}
+	if positional != "" {
+		fmt.Fprintf(b, "%s  positional:\n%s    - [%s]\n", indent, indent, positional)
+	}
+}
+
+func carapaceDynamic(kind string) string {
+	return strconv.Quote("$(ddg " + completeCommand + " " + kind + ")")
+}
+
+func quoteList(vals []string) string {
+	quoted := make([]string, len(vals))
+	for i, v := range vals {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// completeCommand is the hidden command completion specs call back into.
+const completeCommand = "__complete"
+
+// completeKinds produce dynamic completions from local history.
+var completeKinds = map[string]func([]historyEntry) []string{
+	"labels":     completeLabels,
+	"aliases":    completeAliases,
+	"deleted":    completeDeleted,
+	"namespaces": completeNamespaces,
+}
+
+// doComplete prints one candidate per line for 'ddg __complete <kind>
+// [<prefix>]'. Errors print nothing: a completion must never spill
+// messages into the user's prompt.
+func doComplete(args []string) {
+	if len(args) == 0 {
+		os.Exit(1)
+	}
+	kind, ok := completeKinds[args[0]]
+	if !ok {
+		os.Exit(1)
+	}
+	prefix := ""
+	if len(args) > 1 {
+		prefix = strings.ToLower(args[1])
+	}
+	entries, err := readHistory()
+	if err != nil {
+		os.Exit(1)
+	}
+	for _, c := range kind(entries) {
+		if strings.HasPrefix(strings.ToLower(c), prefix) {
+			fmt.Println(c)
+		}
+	}
+}
+
+// completeLabels returns distinct labels, most recently used first.
+func completeLabels(entries []historyEntry) []string {
+	var labels []string
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		l := entries[i].Label
+		if l != "" && entries[i].Deleted == nil && !seen[strings.ToLower(l)] {
+			seen[strings.ToLower(l)] = true
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// completeAliases returns every address, newest first.
+func completeAliases(entries []historyEntry) []string {
+	return addressesWhere(entries, func(e historyEntry) bool { return e.Deleted == nil })
+}
+
+// completeDeleted returns soft-deleted addresses, for undelete.
+func completeDeleted(entries []historyEntry) []string {
+	return addressesWhere(entries, func(e historyEntry) bool { return e.Deleted != nil })
+}
+
+func addressesWhere(entries []historyEntry, keep func(historyEntry) bool) []string {
+	var aliases []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		if keep(entries[i]) {
+			aliases = append(aliases, entries[i].Address)
+		}
+	}
+	return aliases
+}
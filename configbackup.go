@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configBackups is how many earlier versions of the config are kept, as
+// .ddg.conf.bak.1 (the newest) to .bak.5.
+const configBackups = 5
+
+func configBackupPath(path string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", path, n)
+}
+
+// rotateConfigBackups shifts the backups down one and saves the current
+// config as .bak.1, unless next is what's already there: most runs rewrite
+// the config unchanged, and those must not push out real history. Backups
+// leave the API key out, so a deleted or replaced key doesn't live on.
+func rotateConfigBackups(path, next string) error {
+	cur, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(cur) == next {
+		return nil
+	}
+	for n := configBackups - 1; n >= 1; n-- {
+		err := os.Rename(configBackupPath(path, n), configBackupPath(path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.WriteFile(configBackupPath(path, 1), []byte(setConfigAPIKey(string(cur), "")), 0600)
+}
+
+// scrubConfigBackups blanks the API key in every backup, for backups saved
+// before they left it out.
+func scrubConfigBackups(path string) {
+	for n := 1; n <= configBackups; n++ {
+		p := configBackupPath(path, n)
+		b, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if scrubbed := setConfigAPIKey(string(b), ""); scrubbed != string(b) {
+			_ = os.WriteFile(p, []byte(scrubbed), 0600)
+		}
+	}
+}
+
+// setConfigAPIKey returns config data with the api line's value replaced
+// by key.
+func setConfigAPIKey(data, key string) string {
+	var out strings.Builder
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if k, _, ok := strings.Cut(line, "="); ok && strings.EqualFold(strings.TrimSpace(k), apiKey) {
+			line = apiKey + " = " + key
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// configAPIKey returns the value of the api line in config data.
+func configAPIKey(data string) string {
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), "="); ok && strings.EqualFold(strings.TrimSpace(k), apiKey) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func doConfig(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg config <backups|rollback [<n>]>"))
+	}
+	path, err := confPath()
+	if err != nil {
+		exitErr(err)
+	}
+	switch strings.ToLower(args[0]) {
+	case "backups":
+		configListBackups(path)
+	case "rollback":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 || n > configBackups {
+				exitErr(fmt.Errorf("usage: ddg config rollback [<1-%d>]", configBackups))
+			}
+		}
+		configRollback(path, n)
+	default:
+		exitErr(fmt.Errorf("usage: unknown config command %s", args[0]))
+	}
+}
+
+type configBackup struct {
+	N        int       `json:"n"`
+	Path     string    `json:"path"`
+	Modified time.Time `json:"modified"`
+}
+
+func configListBackups(path string) {
+	var backups []configBackup
+	for n := 1; n <= configBackups; n++ {
+		p := configBackupPath(path, n)
+		if fi, err := os.Stat(p); err == nil {
+			backups = append(backups, configBackup{N: n, Path: p, Modified: fi.ModTime()})
+		}
+	}
+	printList(backups, func(b configBackup) string {
+		return fmt.Sprintf("%d  %s  %s", b.N, b.Modified.Local().Format("2006-01-02 15:04"), b.Path)
+	})
+	if len(backups) == 0 && !out.machine() {
+		fmt.Println("No config backups yet; one is kept each time the config changes.")
+	}
+}
+
+// configRollback restores backup n. The config it replaces becomes .bak.1,
+// so rolling back is itself undone by 'ddg config rollback'. Backups have
+// no API key, so the current one is kept.
+func configRollback(path string, n int) {
+	requireWritable("roll back the config")
+	b, err := os.ReadFile(configBackupPath(path, n))
+	if os.IsNotExist(err) {
+		exitErr(fmt.Errorf("there is no config backup %d", n))
+	}
+	if err != nil {
+		exitErr(err)
+	}
+	cur, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		exitErr(err)
+	}
+	err = writeConfigFile(path, setConfigAPIKey(string(b), configAPIKey(string(cur))))
+	audit("config.rollback", err, map[string]string{"backup": strconv.Itoa(n)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Config rolled back to backup %d. 'ddg config rollback' undoes it.", n),
+		map[string]int{"restored": n})
+}
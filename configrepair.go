@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// configError is a line of the config file readConfig couldn't make sense
+// of. readConfig still returns everything it could read alongside it.
+type configError struct {
+	Path string
+	Line int
+	Text string
+}
+
+func (e *configError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Path, e.Text)
+	}
+	return fmt.Sprintf("%s:%d: can't parse %q", e.Path, e.Line, e.Text)
+}
+
+// repairConfig is the recovery flow for a damaged config: rather than
+// treating it as a first run, show what's wrong, offer a backup and let the
+// user rebuild the file from what was readable or fix it by hand.
+func repairConfig(partial conf, cerr *configError) (conf, error) {
+	fmt.Fprintf(os.Stderr, "%s Your config file is damaged: %v\n", sym("⚠️"), cerr)
+	if !stdinIsTerminal() {
+		return conf{}, fmt.Errorf("%w; fix the file by hand or run ddg in a terminal to repair it", cerr)
+	}
+	requireWritable("repair the config")
+
+	backup := cerr.Path + ".broken"
+	if strings.ToLower(ask(prompt{Label: "Back it up to " + backup + " first? (yes/no)", Default: "yes", Validate: validYesNo})) == "yes" {
+		b, err := os.ReadFile(cerr.Path)
+		if err == nil {
+			err = os.WriteFile(backup, b, 0600)
+		}
+		if err != nil {
+			return conf{}, fmt.Errorf("backing up the config: %w", err)
+		}
+		fmt.Println(sym("✅") + " Saved a copy to " + backup + ".")
+	}
+
+	for {
+		choice := strings.ToLower(ask(prompt{
+			Label:   "[r]ebuild it from what's readable, [e]dit it, or [q]uit",
+			Default: "r",
+		}))
+		switch {
+		case strings.HasPrefix(choice, "r"):
+			hasKey := partial.APIKey != "" || keystoreName(partial) != keystoreFile
+			if hasKey && partial.SetupComplete == "" {
+				partial.SetupComplete = "true"
+			}
+			err := writeConfig(partial)
+			audit("config.repair", err, map[string]string{"how": "rebuild"})
+			if err != nil {
+				return conf{}, err
+			}
+			if hasKey {
+				fmt.Println(sym("✅") + " Config rebuilt; your API key was kept.")
+			} else {
+				fmt.Println(sym("⚠️") + " Config rebuilt, but the API key line was lost; setup will ask for it.")
+			}
+			return partial, nil
+		case strings.HasPrefix(choice, "e"):
+			if err := editFile(cerr.Path); err != nil {
+				return conf{}, err
+			}
+			c, err := readConfig()
+			var again *configError
+			if errors.As(err, &again) {
+				fmt.Fprintf(os.Stderr, "%s Still damaged: %v\n", sym("⚠️"), again)
+				partial, cerr = c, again
+				continue
+			}
+			audit("config.repair", err, map[string]string{"how": "edit"})
+			return c, err
+		case strings.HasPrefix(choice, "q"):
+			os.Exit(1)
+		}
+	}
+}
+
+// editFile opens path in $VISUAL or $EDITOR (vi if neither is set) and
+// waits for it to close.
+func editFile(path string) error {
+	editor := orDefault(os.Getenv("VISUAL"), os.Getenv("EDITOR"))
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "editor", path)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command(editor, path)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", editor, err)
+	}
+	return nil
+}
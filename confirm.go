@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// assumeYesEnv answers every confirmation up to riskHigh, for scripts.
+const assumeYesEnv = "DDG_ASSUME_YES"
+
+// assumeYes is set by the global --yes flag.
+var assumeYes bool
+
+// risk says how much a confirmation protects, and so what may answer it
+// instead of the user.
+type risk int
+
+const (
+	// riskLow guards actions that are easy to take back or only read:
+	// --yes or DDG_ASSUME_YES answers it.
+	riskLow risk = iota
+	// riskHigh guards actions that lose data short of the trash: the
+	// question carries a warning, and --yes or DDG_ASSUME_YES answers it.
+	riskHigh
+	// riskCritical guards wiping everything: after yes the user types a
+	// word back. Only --yes on the command line answers it, so a stray
+	// DDG_ASSUME_YES in the environment can't.
+	riskCritical
+)
+
+// confirmation is one question guarding a destructive action.
+type confirmation struct {
+	Question string // without "(yes/no)"
+	Risk     risk
+	Word     string // riskCritical: what the user types to go ahead
+}
+
+// confirm asks c and reports whether to go ahead. Anything but "yes" is a
+// no. Without a terminal on stdin, and nothing that answers for the user,
+// it exits saying how to confirm.
+func confirm(c confirmation) bool {
+	if yesAssumed(c.Risk) {
+		return true
+	}
+	if !stdinIsTerminal() {
+		hint := "pass --yes"
+		if c.Risk < riskCritical {
+			hint += " or set " + assumeYesEnv + "=1"
+		}
+		exitErr(fmt.Errorf("%q needs confirming but stdin is not a terminal; %s", c.Question, hint))
+	}
+	label := c.Question + " (yes/no)"
+	if c.Risk >= riskHigh {
+		label = sym("⚠️") + " " + label
+	}
+	if strings.ToLower(ask(prompt{Label: label})) != "yes" {
+		return false
+	}
+	if c.Risk == riskCritical {
+		return ask(prompt{Label: fmt.Sprintf("Type '%s' to go ahead. This is your final chance to go back", c.Word)}) == c.Word
+	}
+	return true
+}
+
+// yesAssumed reports whether confirmations at risk r are answered yes
+// without asking.
+func yesAssumed(r risk) bool {
+	return assumeYes || (r < riskCritical && envYes(assumeYesEnv))
+}
+
+// envYes reports whether the variable name is set to 1, yes or true.
+func envYes(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(name))) {
+	case "1", "yes", "true":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	issuesURL = repoURL + "/issues/new"
+
+	// crashAliasesEnv set to 1 keeps aliases in crash reports, for when
+	// one is what triggers the bug.
+	crashAliasesEnv = "DDG_CRASH_ALIASES"
+
+	crashDirName = "crashes"
+	crashesKept  = 10
+)
+
+var aliasPattern = regexp.MustCompile(`(?i)[a-z0-9._+-]+@duck\.com`)
+
+// recoverCrash turns a panic into a crash report instead of a raw stack
+// trace on the terminal, then exits as a panic would. It must be deferred
+// directly.
+func recoverCrash() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	path, err := writeCrashReport(v, debug.Stack())
+	msg := "ddg crashed, which is a bug. "
+	short := fmt.Errorf("internal error; crash report: %s", path)
+	if err != nil {
+		msg += fmt.Sprintf("The crash report couldn't be saved (%v); please file an issue at %s with what you ran.", err, issuesURL)
+		short = fmt.Errorf("internal error; crash report not saved: %w", err)
+	} else {
+		msg += fmt.Sprintf("A report without your API key or aliases is in\n  %s\nPlease file an issue at %s and attach it.", path, issuesURL)
+	}
+	if !writeErrorReport(short) {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	os.Exit(2)
+}
+
+// sanitizeCrash takes the API key out of s, and aliases too unless the user
+// opted in.
+func sanitizeCrash(s string) string {
+	s = redactSecrets(s)
+	if os.Getenv(crashAliasesEnv) != "1" {
+		s = aliasPattern.ReplaceAllString(s, "[alias]")
+	}
+	return s
+}
+
+// writeCrashReport saves a sanitized report of v to the crashes directory,
+// keeping the newest crashesKept, and returns its path. Only the command's
+// name is recorded, not its arguments.
+func writeCrashReport(v interface{}, stack []byte) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, crashDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	cmd := "(none)"
+	if len(os.Args) > 1 {
+		cmd = "(plugin or unknown)"
+		if name := strings.ToLower(os.Args[1]); isBuiltinCommand(name) {
+			cmd = name
+		}
+	}
+	now := time.Now()
+	report := fmt.Sprintf("ddg crash report\nversion: %s (%s %s/%s)\ntime: %s\ncommand: %s\npanic: %s\n\n%s",
+		version, runtime.Version(), runtime.GOOS, runtime.GOARCH, now.Format(time.RFC3339), cmd,
+		sanitizeCrash(fmt.Sprint(v)), sanitizeCrash(string(stack)))
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405.000")+".txt")
+	if err := os.WriteFile(path, []byte(report), 0600); err != nil {
+		return "", err
+	}
+	if old, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt")); len(old) > crashesKept {
+		sort.Strings(old)
+		for _, p := range old[:len(old)-crashesKept] {
+			_ = os.Remove(p)
+		}
+	}
+	return path, nil
+}
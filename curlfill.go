@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxFormPage caps how much of a signup page curlfill reads.
+const maxFormPage = 2 << 20
+
+// formField is one named control of an HTML form.
+type formField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Required bool   `json:"required,omitempty"`
+	email    bool   // looks like where the email address goes
+}
+
+// htmlForm is a form as curlfill found it on the page.
+type htmlForm struct {
+	Action string
+	Method string
+	Fields []formField
+}
+
+// filledForm is what curlfill prints: the request that signs up with a
+// fresh alias.
+type filledForm struct {
+	Address string            `json:"address"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Fields  []formField       `json:"fields"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+	Missing []string          `json:"missing,omitempty"` // required, and still empty
+	Curl    string            `json:"curl"`
+}
+
+// doCurlfill fetches a signup page, finds the form with an email field,
+// fills in a fresh alias and prints the request as a curl command; with
+// --submit it sends it too, once confirmed.
+func doCurlfill(args []string) {
+	pageURL, fieldName, submit := "", "", false
+	set := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--field" && i+1 < len(args) {
+			fieldName = args[i+1]
+			i++
+		} else if args[i] == "--set" && i+1 < len(args) {
+			k, v, ok := strings.Cut(args[i+1], "=")
+			if !ok || k == "" {
+				exitErr(fmt.Errorf("--set %q: want name=value", args[i+1]))
+			}
+			set[k] = v
+			i++
+		} else if args[i] == "--submit" {
+			submit = true
+		} else if strings.HasPrefix(args[i], "-") || pageURL != "" {
+			unknownArg(args[i])
+		} else {
+			pageURL = args[i]
+		}
+	}
+	if pageURL == "" {
+		exitErr(fmt.Errorf("usage: ddg curlfill <url> [--field <name>] [--set name=value...] [--submit]"))
+	}
+	if !strings.Contains(pageURL, "://") {
+		pageURL = "https://" + pageURL
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil || (page.Scheme != "https" && page.Scheme != "http") || page.Host == "" {
+		exitErr(fmt.Errorf("%q is not an http(s) URL", pageURL))
+	}
+
+	jar, _ := cookiejar.New(nil)
+	// Not apiClient: this is someone else's site, which fixtures don't
+	// stand in for, but egress is checked all the same.
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Jar:     jar,
+		Transport: &http.Transport{
+			Proxy:               egressProxy,
+			DialContext:         (&cachingDialer{}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+	resp, err := client.Get(page.String())
+	if err != nil {
+		exitErr(err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFormPage))
+	resp.Body.Close()
+	if err != nil {
+		exitErr(err)
+	}
+	if resp.StatusCode/100 != 2 {
+		exitErr(fmt.Errorf("%s: %s", page, resp.Status))
+	}
+	// Redirects count: the form is relative to where it was served from.
+	page = resp.Request.URL
+
+	form, email, ok := findSignupForm(parseForms(string(body)), fieldName)
+	if !ok {
+		if fieldName != "" {
+			exitErr(fmt.Errorf("no form on %s has a field named %q", page, fieldName))
+		}
+		exitErr(fmt.Errorf("no form on %s has an email field; name it with --field", page))
+	}
+	action, err := page.Parse(form.Action)
+	if err != nil {
+		exitErr(fmt.Errorf("form action %q: %w", form.Action, err))
+	}
+
+	requireWritable("generate an alias")
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	entry, err := generateAlias(cfg, historyEntry{Label: importLabel(page.String()), Notes: "made for 'ddg curlfill'"})
+	if err != nil {
+		exitErr(err)
+	}
+
+	f := filledForm{Address: entry.Address, Method: form.Method, URL: action.String(), Cookies: map[string]string{}}
+	values := url.Values{}
+	for _, fl := range form.Fields {
+		if fl.Name == email.Name {
+			fl.Value = entry.Address
+		} else if v, ok := set[fl.Name]; ok {
+			fl.Value = v
+		}
+		if fl.Value == "" && (fl.Required || fl.Type == "password") {
+			f.Missing = append(f.Missing, fl.Name)
+		}
+		f.Fields = append(f.Fields, fl)
+		values.Add(fl.Name, fl.Value)
+	}
+	for _, c := range jar.Cookies(action) {
+		f.Cookies[c.Name] = c.Value
+	}
+	f.Curl = curlCommand(f)
+
+	human := cyan(entry.Address) + " goes in " + email.Name + " of the form at " + f.URL + ":\n\n" + f.Curl
+	if len(f.Missing) > 0 {
+		human += "\n\n" + sym("⚠️") + " Still empty: " + strings.Join(f.Missing, ", ") + " (fill them in with --set name=value)"
+	}
+	out.Result(f, human)
+	if !submit {
+		return
+	}
+
+	if !confirm(confirmation{Question: fmt.Sprintf("Submit the form to %s as %s?", action.Host, entry.Address)}) {
+		audit("curlfill.submit", errCancelled, map[string]string{"address": entry.Address, "url": f.URL})
+		fmt.Fprintln(os.Stderr, sym("❌")+" Not submitted.")
+		return
+	}
+	var req *http.Request
+	if f.Method == http.MethodGet {
+		u := *action
+		u.RawQuery = values.Encode()
+		req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+	} else {
+		req, err = http.NewRequest(f.Method, f.URL, strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		exitErr(err)
+	}
+	req.Header.Set("Referer", page.String())
+	resp, err = client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			err = fmt.Errorf("%s answered %s", action.Host, resp.Status)
+		}
+	}
+	audit("curlfill.submit", err, map[string]string{"address": entry.Address, "url": f.URL})
+	if err != nil {
+		exitErr(err)
+	}
+	fmt.Fprintf(os.Stderr, "%s Submitted: %s, ending at %s\n", sym("✅"), resp.Status, resp.Request.URL)
+}
+
+// curlCommand is f as a curl command line, one option per line.
+func curlCommand(f filledForm) string {
+	first := "curl "
+	if f.Method == http.MethodGet {
+		first += "-G "
+	} else if f.Method != http.MethodPost {
+		first += "-X " + f.Method + " "
+	}
+	lines := []string{first + shellQuote(f.URL)}
+	var cookies []string
+	for _, name := range sortedKeys(f.Cookies) {
+		cookies = append(cookies, name+"="+f.Cookies[name])
+	}
+	if len(cookies) > 0 {
+		lines = append(lines, "-b "+shellQuote(strings.Join(cookies, "; ")))
+	}
+	for _, fl := range f.Fields {
+		lines = append(lines, "--data-urlencode "+shellQuote(fl.Name+"="+fl.Value))
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+// findSignupForm returns the form holding the field named name, or with no
+// name, the first form with something that looks like an email field.
+func findSignupForm(forms []htmlForm, name string) (htmlForm, formField, bool) {
+	for _, f := range forms {
+		for _, fl := range f.Fields {
+			if (name != "" && fl.Name == name) || (name == "" && fl.email) {
+				return f, fl, true
+			}
+		}
+	}
+	return htmlForm{}, formField{}, false
+}
+
+// parseForms finds the forms in an HTML page and the named controls in
+// each: what a browser would submit, before the user types anything.
+// It's a tag scanner, not a parser, which is plenty for signup pages.
+func parseForms(doc string) []htmlForm {
+	var forms []htmlForm
+	var cur *htmlForm
+	var sel *formField // the select whose options are being read
+	for _, t := range scanTags(doc) {
+		switch t.name {
+		case "form":
+			forms = append(forms, htmlForm{Action: t.attrs["action"], Method: strings.ToUpper(orDefault(t.attrs["method"], "GET"))})
+			cur = &forms[len(forms)-1]
+		case "/form":
+			cur = nil
+		case "input", "textarea", "select":
+			name := t.attrs["name"]
+			if cur == nil || name == "" {
+				continue
+			}
+			_, disabled := t.attrs["disabled"]
+			_, required := t.attrs["required"]
+			fl := formField{Name: name, Type: strings.ToLower(orDefault(t.attrs["type"], "text")), Value: t.attrs["value"], Required: required}
+			if t.name != "input" {
+				fl.Type = t.name
+			}
+			switch fl.Type {
+			case "submit", "button", "reset", "image", "file":
+				continue
+			case "checkbox", "radio":
+				if _, checked := t.attrs["checked"]; !checked {
+					continue
+				}
+				if _, ok := t.attrs["value"]; !ok {
+					fl.Value = "on"
+				}
+			}
+			if disabled {
+				continue
+			}
+			hint := strings.ToLower(name + " " + t.attrs["id"] + " " + t.attrs["autocomplete"])
+			fl.email = fl.Type == "email" || (fl.Type == "text" && strings.Contains(hint, "mail"))
+			cur.Fields = append(cur.Fields, fl)
+			if t.name == "select" {
+				sel = &cur.Fields[len(cur.Fields)-1]
+				sel.Value = ""
+			}
+		case "option":
+			if sel == nil {
+				continue
+			}
+			_, selected := t.attrs["selected"]
+			if selected || sel.Value == "" {
+				sel.Value = t.attrs["value"]
+			}
+		case "/select":
+			sel = nil
+		}
+	}
+	return forms
+}
+
+// htmlTag is a start or end tag ("/form"), with its attributes unescaped.
+type htmlTag struct {
+	name  string
+	attrs map[string]string
+}
+
+// scanTags returns the tags of doc in order, skipping comments and what's
+// inside script and style elements.
+func scanTags(doc string) []htmlTag {
+	var tags []htmlTag
+	for {
+		i := strings.IndexByte(doc, '<')
+		if i < 0 {
+			return tags
+		}
+		doc = doc[i+1:]
+		if strings.HasPrefix(doc, "!--") {
+			end := strings.Index(doc, "-->")
+			if end < 0 {
+				return tags
+			}
+			doc = doc[end+3:]
+			continue
+		}
+		n := 0
+		if strings.HasPrefix(doc, "/") {
+			n = 1 // an end tag
+		}
+		for n < len(doc) && !strings.ContainsRune(" \t\r\n/>", rune(doc[n])) {
+			n++
+		}
+		t := htmlTag{name: strings.ToLower(doc[:n]), attrs: map[string]string{}}
+		doc = doc[n:]
+		// Attributes, up to the closing '>'.
+		for {
+			doc = strings.TrimLeft(doc, " \t\r\n/")
+			if doc == "" || doc[0] == '>' {
+				break
+			}
+			n := 0
+			for n < len(doc) && !strings.ContainsRune(" \t\r\n/>=", rune(doc[n])) {
+				n++
+			}
+			key := strings.ToLower(doc[:n])
+			doc = strings.TrimLeft(doc[n:], " \t\r\n")
+			val := ""
+			if strings.HasPrefix(doc, "=") {
+				doc = strings.TrimLeft(doc[1:], " \t\r\n")
+				if doc != "" && (doc[0] == '"' || doc[0] == '\'') {
+					end := strings.IndexByte(doc[1:], doc[0])
+					if end < 0 {
+						return tags
+					}
+					val, doc = doc[1:end+1], doc[end+2:]
+				} else {
+					n := 0
+					for n < len(doc) && !strings.ContainsRune(" \t\r\n>", rune(doc[n])) {
+						n++
+					}
+					val, doc = doc[:n], doc[n:]
+				}
+			}
+			if key != "" {
+				if _, dup := t.attrs[key]; !dup {
+					t.attrs[key] = html.UnescapeString(val)
+				}
+			}
+		}
+		if doc != "" {
+			doc = doc[1:]
+		}
+		tags = append(tags, t)
+		if t.name == "script" || t.name == "style" {
+			end := strings.Index(strings.ToLower(doc), "</"+t.name)
+			if end < 0 {
+				return tags
+			}
+			doc = doc[end:]
+		}
+	}
+}
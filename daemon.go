@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// The daemon itself is left out of minimal builds (-tags minimal); these are
+// the parts of it that clients, profiles and completion still need.
+
+const (
+	defaultServeAddr  = "127.0.0.1:8765"
+	usersFileName     = "users.json"
+	keysFileName      = "keys.json"
+	serverKeyFileName = "server.key"
+)
+
+// Scopes a local API key can be issued with.
+const (
+	scopeGenerate = "generate"
+	scopeHistory  = "history"
+	scopeEvents   = "events" // subscribe to /events
+)
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
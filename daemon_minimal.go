@@ -0,0 +1,29 @@
+//go:build minimal
+
+package main
+
+import "fmt"
+
+// A minimal build (go build -tags minimal) is for servers and container
+// images: everything but the daemon, which is what pulls in the HTTP server,
+// the event stream and pairing. ddg client and DDG_AGENT_SOCK still work
+// against a daemon running elsewhere.
+
+var errMinimalBuild = fmt.Errorf("this is a minimal build of ddg without the daemon; install the full build for it")
+
+func doServe(args []string) { exitErr(errMinimalBuild) }
+
+func doPair(args []string) { exitErr(errMinimalBuild) }
+
+func doInstallWindowsService(args []string) { exitErr(errMinimalBuild) }
+
+// Completion shouldn't offer what isn't there.
+func init() {
+	kept := commandTree[:0]
+	for _, c := range commandTree {
+		if c.Name != "serve" && c.Name != "pair" && c.Name != "install-windows-service" {
+			kept = append(kept, c)
+		}
+	}
+	commandTree = kept
+}
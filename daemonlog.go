@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// logTargetKey picks where the daemon logs: "stderr" (the default),
+// "journald" or "syslog", for running it under a service manager.
+const logTargetKey = "log_target"
+
+const (
+	logStderr   = "stderr"
+	logJournald = "journald"
+	logSyslog   = "syslog"
+)
+
+// Priorities, as syslog and journald number them.
+const (
+	priErr     = 3
+	priWarning = 4
+	priInfo    = 6
+)
+
+// daemonLogger writes one message at a priority.
+type daemonLogger interface {
+	log(pri int, msg string) error
+}
+
+// dlog is where the daemon's messages go; serve replaces it according to
+// log_target.
+var dlog daemonLogger = stderrLogger{}
+
+// logf logs through dlog, falling back to stderr if that fails so nothing
+// is lost silently.
+func logf(pri int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if err := dlog.log(pri, msg); err != nil {
+		_ = stderrLogger{}.log(pri, msg)
+	}
+}
+
+func openDaemonLog(target string) (daemonLogger, error) {
+	switch target {
+	case "", logStderr:
+		return stderrLogger{}, nil
+	case logJournald:
+		return openJournald()
+	case logSyslog:
+		return openSyslog()
+	}
+	return nil, fmt.Errorf("unknown %s %q (use stderr, journald or syslog)", logTargetKey, target)
+}
+
+// stderrLogger is what the daemon always did: info through the normal
+// output, warnings and errors on stderr.
+type stderrLogger struct{}
+
+func (stderrLogger) log(pri int, msg string) error {
+	switch {
+	case pri <= priErr:
+		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+	case pri == priWarning:
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	default:
+		out.Info("%s", msg)
+	}
+	return nil
+}
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldLogger speaks journald's native protocol, so priorities survive
+// as PRIORITY= instead of being guessed from the text.
+type journaldLogger struct {
+	conn *net.UnixConn
+}
+
+func openJournald() (daemonLogger, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return journaldLogger{conn: conn}, nil
+}
+
+func (j journaldLogger) log(pri int, msg string) error {
+	var b bytes.Buffer
+	field := func(k, v string) {
+		if !strings.Contains(v, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+			return
+		}
+		// Multi-line values are sent as the name, a little-endian length
+		// and the raw bytes.
+		b.WriteString(k + "\n")
+		_ = binary.Write(&b, binary.LittleEndian, uint64(len(v)))
+		b.WriteString(v + "\n")
+	}
+	field("MESSAGE", msg)
+	field("PRIORITY", fmt.Sprint(pri))
+	field("SYSLOG_IDENTIFIER", "ddg")
+	if profileName != "" {
+		field("DDG_PROFILE", profileName)
+	}
+	_, err := j.conn.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,73 @@
+// Package ddg holds the parts of duckduckgone that are useful outside the
+// CLI, for importers, form-fillers and other tools that deal with
+// DuckDuckGo Email Protection addresses.
+//
+// # Compatibility
+//
+// From v1.0.0 on, ddg and ddgtest follow semantic versioning: nothing
+// exported is removed or changed incompatibly within v1. New fields,
+// functions and methods may be added in minor releases, so use keyed
+// struct literals. 'make build' checks this with apidiff against the last
+// v1 tag. The CLI (package main) makes no such promise.
+package ddg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Domain is the domain every DuckDuckGo alias lives under.
+const Domain = "duck.com"
+
+// maxUserLen is the longest local part an email address may have.
+const maxUserLen = 64
+
+// NormalizeAlias returns s in the canonical form ddg stores: surrounding
+// whitespace, angle brackets and a "mailto:" prefix removed, lower case.
+// It does not check that the result is a valid alias; see ValidateAlias.
+func NormalizeAlias(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimSuffix(s, ">"), "<")
+	if len(s) >= 7 && strings.EqualFold(s[:7], "mailto:") {
+		s = s[7:]
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// SplitAlias splits a normalized alias into its username and domain.
+func SplitAlias(s string) (user, domain string, err error) {
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return "", "", fmt.Errorf("%q has no @", s)
+	}
+	return s[:at], s[at+1:], nil
+}
+
+// ValidateAlias reports why s is not a plausible @duck.com alias, or nil if
+// it is. s is normalized first, so "<Foo-Bar@Duck.com>" is accepted.
+func ValidateAlias(s string) error {
+	user, domain, err := SplitAlias(NormalizeAlias(s))
+	if err != nil {
+		return err
+	}
+	if domain != Domain {
+		return fmt.Errorf("%q is not a @%s address", s, Domain)
+	}
+	if user == "" || len(user) > maxUserLen {
+		return fmt.Errorf("%q has an invalid username length", s)
+	}
+	if user[0] == '-' || user[len(user)-1] == '-' {
+		return fmt.Errorf("%q: username may not start or end with '-'", s)
+	}
+	for _, r := range user {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("%q: username may only contain letters, digits and '-'", s)
+		}
+	}
+	return nil
+}
+
+// IsAlias reports whether s is a plausible @duck.com alias.
+func IsAlias(s string) bool {
+	return ValidateAlias(s) == nil
+}
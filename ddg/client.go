@@ -0,0 +1,185 @@
+package ddg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is where the DuckDuckGo Email Protection API lives.
+const DefaultBaseURL = "https://quack.duckduckgo.com"
+
+const (
+	addressesPath = "/api/email/addresses"
+	dashboardPath = "/api/email/dashboard"
+)
+
+// Client calls the DuckDuckGo Email Protection API with one account's token.
+// The zero value is not usable; Token must be set.
+type Client struct {
+	Token      string
+	BaseURL    string       // DefaultBaseURL if empty
+	HTTPClient *http.Client // http.DefaultClient if nil
+	Tracer     Tracer       // optional: a span around every API call
+	Middleware []Middleware // run around every request, the first outermost
+}
+
+// String leaves the token out, so logging a Client doesn't leak it.
+func (c Client) String() string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return fmt.Sprintf("ddg.Client{BaseURL: %q}", base)
+}
+
+// GoString is String, for %#v.
+func (c Client) GoString() string { return c.String() }
+
+// APIError is a non-2xx answer from the API (or from a daemon speaking the
+// same protocol).
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // from a 429's Retry-After header, if any
+	Err        error
+}
+
+func (e *APIError) Error() string { return e.Err.Error() }
+func (e *APIError) Unwrap() error { return e.Err }
+
+// Unauthorized reports whether err is an APIError with status 401, meaning
+// the token was rejected.
+func Unauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// Generate asks DuckDuckGo for a new alias and returns it, normalized.
+func (c *Client) Generate(ctx context.Context) (string, error) {
+	body, err := c.do(ctx, "ddg.Generate", http.MethodPost, addressesPath)
+	if err != nil {
+		return "", err
+	}
+	var parsed struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("decode error: %w", err)
+	}
+	if parsed.Address == "" {
+		return "", fmt.Errorf("no address in response")
+	}
+	addr := NormalizeAlias(parsed.Address + "@" + Domain)
+	if err := ValidateAlias(addr); err != nil {
+		return "", fmt.Errorf("unexpected address in response: %w", err)
+	}
+	return addr, nil
+}
+
+// CheckToken verifies the token against an endpoint that doesn't generate
+// anything.
+func (c *Client) CheckToken(ctx context.Context) error {
+	_, err := c.do(ctx, "ddg.CheckToken", http.MethodGet, dashboardPath)
+	return err
+}
+
+// Account is what the API says about a token's owner.
+type Account struct {
+	Username           string `json:"username"`         // their personal address is Username@duck.com
+	ForwardingEmail    string `json:"forwarding_email"` // where mail to every alias goes
+	AddressesGenerated int    `json:"addresses_generated"`
+}
+
+// Account fetches the details of the account the token belongs to. Like
+// CheckToken, it generates nothing.
+func (c *Client) Account(ctx context.Context) (Account, error) {
+	body, err := c.do(ctx, "ddg.Account", http.MethodGet, dashboardPath)
+	if err != nil {
+		return Account{}, err
+	}
+	// The dashboard also echoes the token back; only what's needed is kept.
+	var parsed struct {
+		User struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		} `json:"user"`
+		Stats struct {
+			AddressesGenerated int `json:"addresses_generated"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return Account{}, fmt.Errorf("decode error: %w", err)
+	}
+	return Account{
+		Username:           parsed.User.Username,
+		ForwardingEmail:    parsed.User.Email,
+		AddressesGenerated: parsed.Stats.AddressesGenerated,
+	}, nil
+}
+
+// do makes one API call, traced as op.
+func (c *Client) do(ctx context.Context, op, method, path string) (body []byte, err error) {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := c.startSpan(ctx, op,
+		Attr{"http.request.method", method}, Attr{"server.address", req.URL.Hostname()}, Attr{"url.path", path})
+	defer func() { span.End(err) }()
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := chain(hc.Do, c.Middleware)(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(Attr{"http.response.status_code", resp.StatusCode})
+	body, _ = io.ReadAll(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("invalid token")}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e := &APIError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		e.Err = fmt.Errorf("rate limited")
+		if e.RetryAfter > 0 {
+			e.Err = fmt.Errorf("rate limited, retry in %s", e.RetryAfter)
+		}
+		return nil, e
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("HTTP %d", resp.StatusCode)}
+	}
+	return body, nil
+}
+
+// parseRetryAfter understands both forms of Retry-After: seconds and an
+// HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d.Round(time.Second)
+		}
+	}
+	return 0
+}
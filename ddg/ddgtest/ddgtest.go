@@ -0,0 +1,136 @@
+// Package ddgtest provides a fake DuckDuckGo Email Protection API for
+// testing code built on package ddg, without touching the network.
+//
+//	srv := ddgtest.NewServer(ddgtest.Address("abc-def"), ddgtest.RateLimited(30*time.Second))
+//	defer srv.Close()
+//	c := srv.Client("token")
+//	addr, _ := c.Generate(ctx) // "abc-def@duck.com"
+//	_, err := c.Generate(ctx)  // *ddg.APIError with RetryAfter 30s
+package ddgtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// Response is one scripted answer.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// Address answers with a successful generation of user@duck.com.
+func Address(user string) Response {
+	return Response{Status: http.StatusCreated, Body: fmt.Sprintf(`{"address":%q}`, user)}
+}
+
+// OK answers 200 with an empty object, as the dashboard endpoint does for a
+// valid token.
+func OK() Response {
+	return Response{Status: http.StatusOK, Body: "{}"}
+}
+
+// Unauthorized answers 401, as for a revoked or mistyped token.
+func Unauthorized() Response {
+	return Response{Status: http.StatusUnauthorized, Body: `{"error":"unauthorized"}`}
+}
+
+// RateLimited answers 429 with Retry-After set to d, rounded to seconds.
+// A zero d leaves the header out.
+func RateLimited(d time.Duration) Response {
+	r := Response{Status: http.StatusTooManyRequests, Header: http.Header{}}
+	if d > 0 {
+		r.Header.Set("Retry-After", strconv.Itoa(int(d.Round(time.Second)/time.Second)))
+	}
+	return r
+}
+
+// Malformed answers 200 with a body that isn't valid JSON.
+func Malformed() Response {
+	return Response{Status: http.StatusOK, Body: `{"address":`}
+}
+
+// Status answers with an arbitrary status code and no body.
+func Status(code int) Response {
+	return Response{Status: code}
+}
+
+// Request is what the server saw of one call.
+type Request struct {
+	Method string
+	Path   string
+	Token  string
+}
+
+// Server is a fake API that answers every request, whatever the endpoint,
+// with the next scripted response. Once the script runs out it answers 500
+// so a test that makes more calls than expected fails loudly.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+// NewServer starts a server that plays responses in order. Close it when
+// done.
+func NewServer(responses ...Response) *Server {
+	s := &Server{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Push appends responses to the script.
+func (s *Server) Push(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, responses...)
+}
+
+// Requests returns the calls made so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Client returns a ddg.Client that talks to s with token.
+func (s *Server) Client(token string) *ddg.Client {
+	return &ddg.Client{Token: token, BaseURL: s.URL, HTTPClient: s.Server.Client()}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("Authorization")
+	if len(token) > 7 {
+		token = token[7:]
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Token: token})
+	if len(s.responses) == 0 {
+		s.mu.Unlock()
+		http.Error(w, "ddgtest: no scripted response left", http.StatusInternalServerError)
+		return
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	s.mu.Unlock()
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if resp.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
@@ -0,0 +1,30 @@
+package ddg
+
+import "net/http"
+
+// Doer sends a request and returns the response, like http.Client.Do.
+type Doer func(*http.Request) (*http.Response, error)
+
+// Middleware wraps the call that sends each API request, to log it, add
+// headers (a proxy's own Proxy-Authorization, say) or change the response
+// before the client reads it. It may modify the request it's handed.
+type Middleware func(next Doer) Doer
+
+// SetHeader is middleware that sets a header on every request.
+func SetHeader(key, value string) Middleware {
+	return func(next Doer) Doer {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set(key, value)
+			return next(req)
+		}
+	}
+}
+
+// chain wraps send in mw, the first outermost, so it sees the request
+// first and the response last.
+func chain(send Doer, mw []Middleware) Doer {
+	for i := len(mw) - 1; i >= 0; i-- {
+		send = mw[i](send)
+	}
+	return send
+}
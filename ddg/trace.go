@@ -0,0 +1,67 @@
+package ddg
+
+import "context"
+
+// Tracer puts the client's API calls into an application's traces. ddg
+// doesn't depend on OpenTelemetry; an adapter is a few lines:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string, attrs ...ddg.Attr) (context.Context, ddg.Span) {
+//		ctx, s := o.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		sp := otelSpan{s}
+//		sp.SetAttributes(attrs...)
+//		return ctx, sp
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttributes(attrs ...ddg.Attr) {
+//		for _, a := range attrs {
+//			switch v := a.Value.(type) {
+//			case int:
+//				s.Span.SetAttributes(attribute.Int(a.Key, v))
+//			case string:
+//				s.Span.SetAttributes(attribute.String(a.Key, v))
+//			}
+//		}
+//	}
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.Span.RecordError(err)
+//			s.Span.SetStatus(codes.Error, err.Error())
+//		}
+//		s.Span.End()
+//	}
+//
+// The request is made with the context Start returns, so an otelhttp
+// transport in HTTPClient nests its span under the client's.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span is one traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	End(err error) // err is nil on success
+}
+
+// Attr is a span attribute, named after OpenTelemetry's semantic
+// conventions. Value is a string or an int. The token is never one.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attr) {}
+func (noopSpan) End(error)             {}
+
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return c.Tracer.Start(ctx, name, attrs...)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// doDelete marks an alias as deleted. It stays in the history file, hidden
+// from every listing, until 'ddg undelete' brings it back or --purge removes
+// it, leaving the old history file in the trash.
+func doDelete(args []string) {
+	address, purge := "", false
+	for _, a := range args {
+		if a == "--purge" {
+			purge = true
+		} else if strings.HasPrefix(a, "-") || address != "" {
+			unknownArg(a)
+		} else {
+			address = a
+		}
+	}
+	if address == "" {
+		exitErr(fmt.Errorf("usage: ddg delete <address> [--purge]"))
+	}
+	requireWritable("delete an alias")
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	if _, ok := findHistory(entries, address); !ok {
+		exitErr(fmt.Errorf("%s is not in your history", address))
+	}
+
+	if purge {
+		if !confirm(confirmation{Question: "Permanently remove " + address + " from history?", Risk: riskHigh}) {
+			audit("history.purge", errCancelled, map[string]string{"address": address})
+			fmt.Println(sym("❌") + " Purge cancelled.")
+			return
+		}
+		kept := entries[:0]
+		for _, e := range entries {
+			if !strings.EqualFold(e.Address, address) {
+				kept = append(kept, e)
+			}
+		}
+		id, err := trashHistory("delete --purge")
+		if err == nil {
+			err = writeHistory(kept, seen)
+		}
+		audit("history.purge", err, map[string]string{"address": address, "trash": id})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done(fmt.Sprintf("%s removed from history. 'ddg restore-trash %s' undoes it.", address, id),
+			map[string]string{"address": address, "purged": "true", "trash": id})
+		return
+	}
+
+	now := time.Now()
+	for i := range entries {
+		if strings.EqualFold(entries[i].Address, address) && entries[i].Deleted == nil {
+			entries[i].Deleted = &now
+		}
+	}
+	err = writeHistory(entries, seen)
+	audit("history.delete", err, map[string]string{"address": address})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("%s deleted. 'ddg undelete %s' restores it.", address, address), map[string]string{"address": address})
+}
+
+func doUndelete(args []string) {
+	if len(args) != 1 {
+		exitErr(fmt.Errorf("usage: ddg undelete <address>"))
+	}
+	address := args[0]
+	requireWritable("restore an alias")
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	found := false
+	for i := range entries {
+		if strings.EqualFold(entries[i].Address, address) && entries[i].Deleted != nil {
+			entries[i].Deleted = nil
+			found = true
+		}
+	}
+	if !found {
+		exitErr(fmt.Errorf("%s is not a deleted alias", address))
+	}
+	err = writeHistory(entries, seen)
+	audit("history.undelete", err, map[string]string{"address": address})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(address+" restored.", map[string]string{"address": address})
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess keeps cmd off ddg's console, so closing it doesn't end cmd.
+func detachProcess(cmd *exec.Cmd) {
+	const detachedProcess, createNewProcessGroup = 0x00000008, 0x00000200
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup}
+}
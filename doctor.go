@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// doctorReport is what 'ddg doctor' has detected about this machine.
+type doctorReport struct {
+	Version           string          `json:"version"`
+	Platform          string          `json:"platform"`
+	Config            string          `json:"config"`
+	ConfigError       string          `json:"config_error,omitempty"`
+	DataDir           string          `json:"data_dir"`
+	Keystore          string          `json:"keystore"`
+	ClipboardBackends map[string]bool `json:"clipboard_backends"`
+	ClipboardDetected string          `json:"clipboard_detected"`
+	ClipboardCached   string          `json:"clipboard_cached"`
+	ClipboardOrder    []string        `json:"clipboard_order,omitempty"`
+}
+
+// doDoctor prints what ddg has detected about this machine, to help debug
+// "it doesn't copy" style problems.
+func doDoctor() {
+	r := doctorReport{
+		Version:           version,
+		Platform:          runtime.GOOS + "/" + runtime.GOARCH,
+		ClipboardBackends: map[string]bool{},
+		ClipboardCached:   getState(stateClipboardBackend),
+	}
+	r.Config, _ = confPath()
+	r.DataDir, _ = dataDir()
+	cfg, err := readConfig()
+	var broken *configError
+	if errors.As(err, &broken) {
+		r.ConfigError = broken.Error()
+	}
+	r.Keystore = keystoreName(cfg)
+	r.ClipboardOrder = splitList(cfg.ClipBackends)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ddg %s on %s\n\n", r.Version, r.Platform)
+	fmt.Fprintf(&b, "Config:    %s\n", r.Config)
+	if r.ConfigError != "" {
+		fmt.Fprintf(&b, "           %s damaged: %s (run ddg to repair it)\n", sym("⚠️"), r.ConfigError)
+	}
+	fmt.Fprintf(&b, "Data dir:  %s\n", r.DataDir)
+	fmt.Fprintf(&b, "Keystore:  %s\n", r.Keystore)
+
+	b.WriteString("\nClipboard backends:\n")
+	for _, cb := range clipboardBackends {
+		ok := cb.available()
+		r.ClipboardBackends[cb.name] = ok
+		mark := sym("✗")
+		if ok {
+			mark = sym("✓")
+		}
+		fmt.Fprintf(&b, "  %s %s\n", mark, cb.name)
+	}
+	if ok := nativeClipboard.available(); ok {
+		r.ClipboardBackends[nativeClipboard.name] = true
+		fmt.Fprintf(&b, "  %s native (when listed in clipboard_backends)\n", sym("✓"))
+	} else {
+		r.ClipboardBackends[nativeClipboard.name] = false
+		fmt.Fprintf(&b, "  %s native\n", sym("✗"))
+	}
+	detected, ok := detectClipboard()
+	if ok {
+		r.ClipboardDetected = detected
+	} else {
+		detected = "none"
+	}
+	if tty := sshTTY(); tty != "" {
+		detected += " (SSH session on " + tty + ")"
+	}
+	fmt.Fprintf(&b, "Detected:  %s\n", detected)
+	fmt.Fprintf(&b, "Cached:    %s", emptyToDash(r.ClipboardCached))
+	if len(r.ClipboardOrder) > 0 {
+		fmt.Fprintf(&b, "\nConfigured order: %s", strings.Join(r.ClipboardOrder, " → "))
+	}
+	out.Result(r, b.String())
+}
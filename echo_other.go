@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func disableEcho() (func(), error) {
+	return nil, fmt.Errorf("hiding input is not supported on %s", runtime.GOOS)
+}
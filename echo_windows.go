@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// disableEcho switches off echo on the console on stdin and returns a func
+// that restores it.
+func disableEcho() (func(), error) {
+	const enableEchoInput = 0x0004
+	h := syscall.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+	if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode&^enableEchoInput)); r == 0 {
+		return nil, err
+	}
+	return func() {
+		procSetConsoleMode.Call(uintptr(h), uintptr(mode))
+	}, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// With egress = strict, every connection ddg itself opens must go to the
+// API endpoint or a host listed in egress_allow (a notification sink, the
+// SMTP server, a proxy); anything else fails before it's dialed and is
+// audited. Programs ddg runs (hooks, ssh, sendmail) are not covered.
+const (
+	egressKey      = "egress"
+	egressAllowKey = "egress_allow"
+
+	egressOpen   = "open"
+	egressStrict = "strict"
+)
+
+var errEgressBlocked = errors.New("blocked by the egress allow-list")
+
+// egress is set once at startup, before anything connects.
+var egress struct {
+	strict bool
+	allow  map[string]bool
+}
+
+// egressHosts is the allow-list for cfg: the API host first.
+func egressHosts(cfg conf) []string {
+	hosts := []string{}
+	if u, err := url.Parse(ddg.DefaultBaseURL); err == nil {
+		hosts = append(hosts, u.Hostname())
+	}
+	for _, h := range splitList(cfg.EgressAllow) {
+		hosts = append(hosts, strings.ToLower(h))
+	}
+	return hosts
+}
+
+func configureEgress(cfg conf) {
+	if cfg.Egress != egressStrict {
+		return
+	}
+	egress.strict = true
+	egress.allow = map[string]bool{}
+	for _, h := range egressHosts(cfg) {
+		egress.allow[h] = true
+	}
+}
+
+// checkEgress refuses host, a name or an IP as it appears in the URL, unless
+// it's allowed.
+func checkEgress(host string) error {
+	if !egress.strict || egress.allow[strings.ToLower(host)] {
+		return nil
+	}
+	audit("egress.blocked", errEgressBlocked, map[string]string{"host": host})
+	return fmt.Errorf("%w: %s (allow it with 'ddg settings --egress-allow')", errEgressBlocked, host)
+}
+
+func egressSummary(v settingsView) string {
+	if v.Egress != egressStrict {
+		return egressOpen
+	}
+	return egressStrict + " (" + strings.Join(v.EgressAllow, ", ") + ")"
+}
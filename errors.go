@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+var (
+	errReadOnly   = errors.New("read-only mode")
+	errUnknownArg = errors.New("unknown argument")
+)
+
+// errorReport is how an error that ends a command is written in the json
+// and jsonl formats: one line on stderr, {"error": {...}}, so stdout only
+// ever carries results. Code is the contract wrappers switch on:
+// unauthorized, rate_limited, api_error, network, over_budget, hook_veto,
+// cancelled, read_only, usage, config_error, or error for anything else.
+type errorReport struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Status     int    `json:"status,omitempty"`      // HTTP status, for API errors
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds, for rate_limited
+}
+
+// jsonErrors reports whether errors should be written as errorReports.
+func jsonErrors() bool {
+	return out.format == outputJSON || out.format == outputJSONL
+}
+
+func reportFor(err error) errorReport {
+	r := errorReport{Code: "error", Message: redactSecrets(err.Error())}
+	var apiErr *ddg.APIError
+	var cfgErr *configError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		r.Status = apiErr.StatusCode
+		switch apiErr.StatusCode {
+		case 401:
+			r.Code = "unauthorized"
+		case 429:
+			r.Code = "rate_limited"
+			r.RetryAfter = int(apiErr.RetryAfter.Seconds())
+		default:
+			r.Code = "api_error"
+		}
+	case errors.Is(err, errOverBudget):
+		r.Code = "over_budget"
+	case errors.Is(err, errHookVeto):
+		r.Code = "hook_veto"
+	case errors.Is(err, errCancelled):
+		r.Code = "cancelled"
+	case errors.Is(err, errReadOnly):
+		r.Code = "read_only"
+	case errors.Is(err, errUnknownArg) || strings.HasPrefix(err.Error(), "usage:"):
+		r.Code = "usage"
+	case errors.As(err, &cfgErr):
+		r.Code = "config_error"
+	case errors.As(err, &netErr):
+		r.Code = "network"
+	}
+	return r
+}
+
+// writeErrorReport writes err in the json contract. It returns false in
+// human formats, where the caller prints prose instead.
+func writeErrorReport(err error) bool {
+	if !jsonErrors() {
+		return false
+	}
+	if len(out.pending) > 0 {
+		out.Flush() // results that made it before the error
+	}
+	b, _ := json.Marshal(map[string]errorReport{"error": reportFor(err)})
+	fmt.Fprintln(os.Stderr, string(b))
+	return true
+}
+
+// unknownArg rejects a command-line argument and exits.
+func unknownArg(arg string) {
+	if !writeErrorReport(fmt.Errorf("%w: %s", errUnknownArg, arg)) {
+		fmt.Fprintf(os.Stderr, "Unknown argument: %s\n", arg)
+	}
+	os.Exit(1)
+}
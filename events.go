@@ -0,0 +1,265 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// serveEvent is one message on the daemon's event stream.
+type serveEvent struct {
+	Type    string    `json:"type"` // "generate"
+	User    string    `json:"user"`
+	Address string    `json:"address"`
+	Label   string    `json:"label,omitempty"`
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // "daemon", or "cli" for aliases made outside it
+}
+
+// maxSentEvents bounds eventHub.sent. Only recent addresses can turn up
+// again, appended by the daemon and the CLI at the same time.
+const maxSentEvents = 10000
+
+// eventHub fans events out to /events subscribers. Each subscriber only
+// hears about its own user's aliases, except the notification forwarder,
+// which subscribes as "" and hears about everyone's.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan serveEvent]string
+	sent map[string]bool // addresses already announced or in history, so the history watcher skips them
+	seq  []string        // sent's keys, oldest first, to forget them in order
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan serveEvent]string{}, sent: map[string]bool{}}
+}
+
+// remember marks addresses as announced without announcing them.
+func (h *eventHub) remember(addresses ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, a := range addresses {
+		h.rememberLocked(a)
+	}
+}
+
+// rememberLocked marks address as announced and reports whether it wasn't
+// already, forgetting the oldest once there are maxSentEvents.
+func (h *eventHub) rememberLocked(address string) bool {
+	key := ddg.NormalizeAlias(address)
+	if h.sent[key] {
+		return false
+	}
+	h.sent[key] = true
+	h.seq = append(h.seq, key)
+	if len(h.seq) > maxSentEvents {
+		delete(h.sent, h.seq[0])
+		h.seq = h.seq[1:]
+	}
+	return true
+}
+
+func (h *eventHub) subscribe(user string) chan serveEvent {
+	c := make(chan serveEvent, 16)
+	h.mu.Lock()
+	h.subs[c] = user
+	h.mu.Unlock()
+	return c
+}
+
+func (h *eventHub) unsubscribe(c chan serveEvent) {
+	h.mu.Lock()
+	delete(h.subs, c)
+	h.mu.Unlock()
+}
+
+// publish never blocks: a subscriber too slow to keep 16 events buffered
+// misses some rather than holding up generation.
+func (h *eventHub) publish(ev serveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.rememberLocked(ev.Address) {
+		return
+	}
+	for c, user := range h.subs {
+		if user != "" && user != ev.User {
+			continue
+		}
+		select {
+		case c <- ev:
+		default:
+		}
+	}
+}
+
+// handleEvents streams events as server-sent events. EventSource can't set
+// headers, so the key may also come as ?access_token=.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	if t := r.URL.Query().Get("access_token"); t != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+t)
+	}
+	who, status, err := s.authenticate(r, scopeEvents)
+	if err != nil {
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	c := s.events.subscribe(who.Name)
+	defer s.events.unsubscribe(c)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// Proxies and browsers drop connections that stay silent too long.
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case ev := <-c:
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+		}
+		flusher.Flush()
+	}
+}
+
+// forwardEvents pushes every event to the configured notification sinks,
+// so a headless daemon's aliases still show up on a phone.
+func (s *server) forwardEvents(sinks map[string]notifySink, stop <-chan struct{}) {
+	defer recoverCrash()
+	c := s.events.subscribe("")
+	defer s.events.unsubscribe(c)
+	for {
+		select {
+		case <-stop:
+			return
+		case ev := <-c:
+			msg := ev.Address
+			if ev.Label != "" {
+				msg += " for " + ev.Label
+			}
+			if s.local == nil {
+				msg += " (" + ev.User + ")"
+			}
+			for name, sink := range sinks {
+				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+				err := sink.send(ctx, "New Duck alias", msg)
+				cancel()
+				audit("notify."+name, err, map[string]string{"address": ev.Address})
+				if err != nil {
+					logf(priWarning, "%s notification failed: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+// watchHistory announces aliases that other ddg processes append to the
+// local history, so subscribers hear about 'ddg gen' in a terminal too.
+// Everything already in history counts as announced. Rewrites (dedupe,
+// delete, field edits, merges) replace the file, bigger or smaller, and are
+// rescanned silently: none of what they write is a new alias.
+func (s *server) watchHistory(stop <-chan struct{}) {
+	defer recoverCrash()
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	var (
+		last   os.FileInfo
+		offset int64
+	)
+	rescan := func() {
+		last, offset = nil, 0
+		fi, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		var addrs []string
+		for _, e := range historyLines(b) {
+			addrs = append(addrs, e.Address)
+		}
+		s.events.remember(addrs...)
+		last, offset = fi, int64(bytes.LastIndexByte(b, '\n')+1)
+	}
+	rescan()
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if last == nil || !os.SameFile(last, fi) || fi.Size() < offset {
+			rescan()
+			continue
+		}
+		last = fi
+		if fi.Size() == offset {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		b, err := io.ReadAll(io.NewSectionReader(f, offset, fi.Size()-offset))
+		f.Close()
+		if err != nil {
+			continue
+		}
+		// A line still being written waits for the next tick.
+		complete := bytes.LastIndexByte(b, '\n') + 1
+		offset += int64(complete)
+		for _, e := range historyLines(b[:complete]) {
+			s.events.publish(serveEvent{Type: "generate", User: "local", Address: e.Address, Label: e.Label, Time: e.Created, Source: "cli"})
+		}
+	}
+}
+
+// historyLines parses the complete history lines in b, skipping any that
+// don't parse.
+func historyLines(b []byte) []historyEntry {
+	var entries []historyEntry
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		var e historyEntry
+		if json.Unmarshal(line, &e) == nil && e.Address != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// execPlaceholder is replaced by the new alias in every argument of the
+	// command 'ddg exec' runs.
+	execPlaceholder = "{{DUCK}}"
+	// execEmailEnv carries the new alias in the command's environment.
+	execEmailEnv = "DDG_EMAIL"
+)
+
+// doExec generates an alias and runs a command with it, for signups done
+// with curl and for test harnesses:
+//
+//	ddg exec --for example.com -- curl -d email={{DUCK}} https://example.com/signup
+//
+// The command gets the terminal, and ddg exits with its status.
+func doExec(args []string) {
+	var e historyEntry
+	i := 0
+	for ; i < len(args); i++ {
+		if args[i] == "--" {
+			i++
+			break
+		} else if (args[i] == "--for" || args[i] == "--label") && i+1 < len(args) {
+			e.Label = args[i+1]
+			i++
+		} else if args[i] == "--note" && i+1 < len(args) {
+			e.Notes = joinNotes(e.Notes, strings.TrimSpace(args[i+1]))
+			i++
+		} else if args[i] == "--field" && i+1 < len(args) {
+			k, v, err := parseField(args[i+1])
+			if err != nil {
+				exitErr(err)
+			}
+			if e.Fields == nil {
+				e.Fields = map[string]string{}
+			}
+			if v != "" {
+				e.Fields[k] = v
+			}
+			i++
+		} else if strings.HasPrefix(args[i], "-") {
+			unknownArg(args[i])
+		} else {
+			break
+		}
+	}
+	command := args[i:]
+	if len(command) == 0 {
+		exitErr(fmt.Errorf("usage: ddg exec [--for <label>] [--note <text>] [--field key=value] -- <command> [args...]; %s in the args and $%s hold the alias", execPlaceholder, execEmailEnv))
+	}
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		exitErr(err)
+	}
+	name := filepath.Base(command[0])
+	e.Notes = joinNotes(e.Notes, "made for 'ddg exec "+name+"'")
+
+	requireWritable("generate an alias")
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	entry, err := generateAlias(cfg, e)
+	if err != nil {
+		exitErr(err)
+	}
+	// Stdout is the command's.
+	fmt.Fprintf(os.Stderr, "Generated %s for %s.\n", entry.Address, name)
+
+	argv := make([]string, len(command)-1)
+	for j, a := range command[1:] {
+		argv[j] = strings.ReplaceAll(a, execPlaceholder, entry.Address)
+	}
+	cmd := exec.Command(path, argv...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), execEmailEnv+"="+entry.Address)
+	err = cmd.Run()
+	audit("exec", err, map[string]string{"address": entry.Address, "command": name})
+	if err != nil {
+		// The command has already said what went wrong.
+		if ee, ok := err.(*exec.ExitError); ok {
+			os.Exit(ee.ExitCode())
+		}
+		exitErr(err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportFormat is a CSV layout a password manager imports. Rows carry the
+// alias as the username and no password, so they land as logins to fill
+// in or merge with the existing ones.
+type exportFormat struct {
+	Name   string
+	Header []string
+	Row    func(e historyEntry) []string
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv": {Name: "CSV", Header: []string{"address", "label", "url", "created", "tags", "notes", "fields"},
+		Row: func(e historyEntry) []string {
+			return []string{e.Address, e.Label, exportURL(e.Label), e.Created.UTC().Format(time.RFC3339),
+				strings.Join(e.Tags, ", "), e.Notes, formatFields(e.Fields)}
+		}},
+	// https://bitwarden.com/help/condition-bitwarden-import/
+	"bitwarden": {Name: "Bitwarden", Header: []string{"folder", "favorite", "type", "name", "notes", "fields", "reprompt", "login_uri", "login_username", "login_password", "login_totp"},
+		Row: func(e historyEntry) []string {
+			var fields []string
+			for _, k := range sortedKeys(e.Fields) {
+				fields = append(fields, k+": "+e.Fields[k])
+			}
+			return []string{"", "", "login", orDefault(e.Label, e.Address), e.Notes, strings.Join(fields, "\n"), "0", exportURL(e.Label), e.Address, "", ""}
+		}},
+	// What 1Password's CSV import maps without asking.
+	"1password": {Name: "1Password", Header: []string{"Title", "Website", "Username", "Password", "Notes"},
+		Row: func(e historyEntry) []string {
+			return []string{orDefault(e.Label, e.Address), exportURL(e.Label), e.Address, "", e.Notes}
+		}},
+}
+
+func exportFormatNames() []string {
+	names := make([]string, 0, len(exportFormats))
+	for n := range exportFormats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// doExport writes the live history as a CSV file for a password manager to
+// import, so each alias can sit next to the login it belongs to.
+func doExport(args []string) {
+	format, tag, outPath := "", "", ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--format" && i+1 < len(args) {
+			format = strings.ToLower(args[i+1])
+			i++
+		} else if args[i] == "--tag" && i+1 < len(args) {
+			tag = args[i+1]
+			i++
+		} else if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	// The name import knows it by.
+	if format == "1password-csv" {
+		format = "1password"
+	}
+	f, ok := exportFormats[format]
+	if !ok {
+		exitErr(fmt.Errorf("usage: ddg export --format %s [--tag <tag>] [--out <file>]", strings.Join(exportFormatNames(), "|")))
+	}
+
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(f.Header)
+	n := 0
+	for _, e := range mergeHistory(entries, nil) {
+		if tag != "" && !hasTag(e.Tags, tag) {
+			continue
+		}
+		_ = w.Write(f.Row(e))
+		n++
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		exitErr(err)
+	}
+
+	if outPath == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	err = os.WriteFile(outPath, buf.Bytes(), 0600)
+	audit("history.export", err, map[string]string{"format": format, "file": outPath, "count": fmt.Sprint(n)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Wrote %d aliases to %s for %s to import.", n, outPath, f.Name), map[string]interface{}{"file": outPath, "format": format, "count": n})
+}
+
+// exportURL is the site a label names, for the URL column, or "".
+func exportURL(label string) string {
+	u, ok := siteURL(label, "")
+	if !ok {
+		return ""
+	}
+	return u
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// doField sets or removes custom fields on an alias in history.
+func doField(args []string) {
+	if len(args) < 2 {
+		exitErr(fmt.Errorf("usage: ddg field <address> key=value [key=value...]"))
+	}
+	requireWritable("change custom fields")
+	address := args[0]
+	set := map[string]string{}
+	for _, a := range args[1:] {
+		k, v, err := parseField(a)
+		if err != nil {
+			exitErr(err)
+		}
+		set[k] = v
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	var fields map[string]string
+	found := false
+	for i := range entries {
+		if !strings.EqualFold(entries[i].Address, address) {
+			continue
+		}
+		found = true
+		for k, v := range set {
+			if v == "" {
+				delete(entries[i].Fields, k)
+				continue
+			}
+			if entries[i].Fields == nil {
+				entries[i].Fields = map[string]string{}
+			}
+			entries[i].Fields[k] = v
+		}
+		fields = entries[i].Fields
+	}
+	if !found {
+		exitErr(fmt.Errorf("%s is not in your history", address))
+	}
+	err = writeHistory(entries, seen)
+	audit("field.set", err, map[string]string{"address": address, "keys": strings.Join(sortedKeys(set), ",")})
+	if err != nil {
+		exitErr(err)
+	}
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	out.Done(fmt.Sprintf("Fields of %s: %s", address, orDefault(formatFields(fields), "none")), fields)
+}
+
+// parseField splits a key=value argument. Keys are lowercased so
+// Account_ID and account_id are the same field.
+func parseField(s string) (string, string, error) {
+	k, v, ok := strings.Cut(s, "=")
+	k = strings.ToLower(strings.TrimSpace(k))
+	if !ok || k == "" || strings.ContainsAny(k, " \t") {
+		return "", "", fmt.Errorf("field %q: want key=value", s)
+	}
+	return k, strings.TrimSpace(v), nil
+}
+
+// formatFields renders fields as "a=1, b=2", sorted by key.
+func formatFields(fields map[string]string) string {
+	parts := make([]string, 0, len(fields))
+	for _, k := range sortedKeys(fields) {
+		parts = append(parts, k+"="+fields[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls with the same key into one, like
+// golang.org/x/sync/singleflight: callers that arrive while a call is in
+// flight wait for it and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	val  string
+	err  error
+	dups int
+}
+
+// do runs fn once per key at a time. shared reports whether the result went
+// to more than one caller.
+func (g *flightGroup) do(key string, fn func() (string, error)) (val string, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := &flightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		if v := recover(); v != nil {
+			// Waiters get an error; this caller panics on, so that
+			// recoverRequest answers 500 and the crash is logged.
+			c.err = errors.New("internal error")
+			defer panic(v)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		shared = c.dups > 0
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
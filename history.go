@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const (
+	dataDirName     = ".ddg"
+	historyFileName = "history.jsonl"
+)
+
+// historyEntry is one generated alias as recorded in the local history.
+type historyEntry struct {
+	Address string     `json:"address"`
+	Created time.Time  `json:"created"`
+	Label   string     `json:"label,omitempty"`
+	Tags    []string   `json:"tags,omitempty"`
+	Notes   string     `json:"notes,omitempty"`
+	By      string     `json:"by,omitempty"`      // team member, in a team history
+	Review  *time.Time `json:"review,omitempty"`  // when to reconsider the alias
+	Deleted *time.Time `json:"deleted,omitempty"` // soft-deleted; see liveHistory
+
+	// Fields is free-form metadata, e.g. account_id=1234.
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Duplicate is set on a freshly generated entry whose address was
+	// already in history. It is never stored.
+	Duplicate bool `json:"-"`
+}
+
+func doHistory(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg history <dedupe|purge|merge <file>>"))
+	}
+	switch strings.ToLower(args[0]) {
+	case "dedupe":
+		historyDedupe()
+	case "purge":
+		historyPurge()
+	case "merge":
+		if len(args) < 2 {
+			exitErr(fmt.Errorf("usage: ddg history merge <file>"))
+		}
+		historyMerge(args[1])
+	default:
+		exitErr(fmt.Errorf("usage: unknown history command %s", args[0]))
+	}
+}
+
+func historyDedupe() {
+	requireWritable("rewrite history")
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	merged := mergeHistory(entries, nil)
+	err = writeHistory(merged, seen)
+	audit("history.dedupe", err, map[string]string{"removed": strconv.Itoa(len(entries) - len(merged))})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Removed %d duplicate entries (%d remaining).", len(entries)-len(merged), len(merged)),
+		map[string]int{"removed": len(entries) - len(merged), "total": len(merged)})
+}
+
+// historyPurge drops soft-deleted entries after saving the current file to
+// the trash.
+func historyPurge() {
+	requireWritable("rewrite history")
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	live, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	removed := len(entries) - len(live)
+	if removed == 0 {
+		out.Done("No deleted aliases to purge.", map[string]int{"removed": 0})
+		return
+	}
+	if !confirm(confirmation{Question: fmt.Sprintf("Permanently remove %d deleted aliases from history?", removed), Risk: riskHigh}) {
+		audit("history.purge", errCancelled, nil)
+		fmt.Println(sym("❌") + " Purge cancelled.")
+		return
+	}
+	id, err := trashHistory("history purge")
+	if err == nil {
+		err = writeHistory(live, seen)
+	}
+	audit("history.purge", err, map[string]string{"removed": strconv.Itoa(removed), "trash": id})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Purged %d deleted aliases. 'ddg restore-trash %s' undoes it.", removed, id),
+		map[string]interface{}{"removed": removed, "trash": id})
+}
+
+// trashHistory saves the history file to the trash before it loses entries.
+func trashHistory(action string) (string, error) {
+	path, err := historyPath()
+	if err != nil {
+		return "", err
+	}
+	id, err := saveToTrash(action, path)
+	if err != nil {
+		return "", fmt.Errorf("saving history to the trash: %w", err)
+	}
+	return id, nil
+}
+
+func historyMerge(path string) {
+	requireWritable("rewrite history")
+	local, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(local)
+	other, err := readHistoryFile(path)
+	if err != nil {
+		exitErr(err)
+	}
+	before := len(mergeHistory(local, nil))
+	merged := mergeHistory(local, other)
+	err = writeHistory(merged, seen)
+	audit("history.merge", err, map[string]string{"source": path, "added": strconv.Itoa(len(merged) - before)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Merged %s: %d entries added (%d total).", path, len(merged)-before, len(merged)),
+		map[string]int{"added": len(merged) - before, "total": len(merged)})
+}
+
+// mergeHistory unions a and b by address. When the same address appears more
+// than once the earliest created date wins, the first non-empty label is kept,
+// distinct notes are concatenated and fields are combined (the first value of
+// a key wins), so no record is ever silently dropped. A deletion on either
+// side sticks.
+func mergeHistory(a, b []historyEntry) []historyEntry {
+	byAddr := map[string]*historyEntry{}
+	var order []string
+	for _, e := range append(append([]historyEntry{}, a...), b...) {
+		key := ddg.NormalizeAlias(e.Address)
+		cur, ok := byAddr[key]
+		if !ok {
+			e := e
+			byAddr[key] = &e
+			order = append(order, key)
+			continue
+		}
+		if !e.Created.IsZero() && (cur.Created.IsZero() || e.Created.Before(cur.Created)) {
+			cur.Created = e.Created
+		}
+		if cur.Label == "" {
+			cur.Label = e.Label
+		}
+		if cur.Deleted == nil {
+			cur.Deleted = e.Deleted
+		}
+		cur.Tags = unionTags(cur.Tags, e.Tags)
+		cur.Notes = joinNotes(cur.Notes, e.Notes)
+		for k, v := range e.Fields {
+			if _, ok := cur.Fields[k]; !ok {
+				if cur.Fields == nil {
+					cur.Fields = map[string]string{}
+				}
+				cur.Fields[k] = v
+			}
+		}
+	}
+
+	out := make([]historyEntry, 0, len(order))
+	for _, key := range order {
+		out = append(out, *byAddr[key])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+func joinNotes(a, b string) string {
+	if b == "" || a == b {
+		return a
+	}
+	if a == "" {
+		return b
+	}
+	for _, n := range strings.Split(a, "; ") {
+		if n == b {
+			return a
+		}
+	}
+	return a + "; " + b
+}
+
+func unionTags(a, b []string) []string {
+	for _, t := range b {
+		if !hasTag(a, t) {
+			a = append(a, t)
+		}
+	}
+	return a
+}
+
+// hasTags reports whether tags has every one of want.
+func hasTags(tags, want []string) bool {
+	for _, t := range want {
+		if !hasTag(tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// dataDir returns the directory holding history and other local state,
+// creating it if needed.
+func dataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, dataDirName)
+	if profileName != "" {
+		dir = filepath.Join(dir, profilesDirName, profileName)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFileName), nil
+}
+
+func readHistory() (entries []historyEntry, err error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	span := storageSpan("ddg.history.read", path)
+	defer func() {
+		span.SetAttributes(ddg.Attr{Key: "ddg.entries", Value: len(entries)})
+		span.End(err)
+	}()
+	entries, err = readHistoryFile(path)
+	if os.IsNotExist(err) {
+		entries, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// historyAddresses returns the addresses in entries, as writeHistory wants
+// them: taken right after readHistory, before the caller drops anything.
+func historyAddresses(entries []historyEntry) map[string]bool {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		seen[ddg.NormalizeAlias(e.Address)] = true
+	}
+	return seen
+}
+
+// liveHistory is readHistory without soft-deleted entries. Anything that
+// only lists or searches aliases should use it; anything that rewrites the
+// file must use readHistory so deleted entries survive.
+func liveHistory() ([]historyEntry, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	live := entries[:0]
+	for _, e := range entries {
+		if e.Deleted == nil {
+			live = append(live, e)
+		}
+	}
+	return live, nil
+}
+
+func readHistoryFile(path string) ([]historyEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []historyEntry
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var e historyEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// findHistory returns the entry for address, if any.
+func findHistory(entries []historyEntry, address string) (historyEntry, bool) {
+	for _, e := range entries {
+		if ddg.NormalizeAlias(e.Address) == ddg.NormalizeAlias(address) {
+			return e, true
+		}
+	}
+	return historyEntry{}, false
+}
+
+func appendHistory(e historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	return appendHistoryFile(path, e)
+}
+
+func appendHistoryFile(path string, e historyEntry) (err error) {
+	span := storageSpan("ddg.history.append", path)
+	defer func() { span.End(err) }()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// A single O_APPEND write doesn't interleave anyway; the lock is what
+	// keeps it from landing between another writer's read and rename.
+	if unlock, err := lockFile(path); err == nil {
+		defer unlock()
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// writeHistory replaces the history file, going through a temp file so an
+// interrupted write never truncates it. It holds the history lock and keeps
+// any entries appended since readHistory (by the daemon, say), so a
+// concurrent generation is never lost to last-writer-wins. seen is what
+// historyAddresses returned for that read: entries missing from it were
+// added by someone else and are kept, the rest were dropped on purpose.
+func writeHistory(entries []historyEntry, seen map[string]bool) (err error) {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	span := storageSpan("ddg.history.write", path)
+	defer func() {
+		span.SetAttributes(ddg.Attr{Key: "ddg.entries", Value: len(entries)})
+		span.End(err)
+	}()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return fmt.Errorf("locking history: %w", err)
+	}
+	defer unlock()
+
+	current, err := readHistoryFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	have := map[string]bool{}
+	for _, e := range entries {
+		have[ddg.NormalizeAlias(e.Address)] = true
+	}
+	for _, e := range current {
+		key := ddg.NormalizeAlias(e.Address)
+		if !have[key] && !seen[key] {
+			entries = append(entries, e)
+			have[key] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
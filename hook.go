@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	preGenerateHook = "pre_generate_hook"
+	hookTimeout     = 30 * time.Second
+)
+
+// errHookVeto means the pre_generate_hook refused a generation.
+var errHookVeto = errors.New("refused by pre_generate_hook")
+
+// hookInput is written to the hook's stdin as JSON.
+type hookInput struct {
+	Label string   `json:"label"`
+	Tags  []string `json:"tags,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+	User  string   `json:"user"`
+}
+
+// runPreGenerateHook runs the configured pre_generate_hook, if any, before
+// an alias is generated for e on behalf of user. The hook is either the
+// path of an executable or a shell command line; either way it gets the
+// label as $1 and in DDG_LABEL (plus DDG_TAGS and DDG_USER), and the same as
+// JSON on stdin. A non-zero exit, or failing to
+// run at all, vetoes the generation: policies fail closed.
+func runPreGenerateHook(cfg conf, e historyEntry, user string) error {
+	if cfg.PreGenerateHook == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if path := expandHome(cfg.PreGenerateHook); isExecutable(path) {
+		cmd = exec.CommandContext(ctx, path, e.Label)
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", cfg.PreGenerateHook)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", cfg.PreGenerateHook, preGenerateHook, e.Label)
+	}
+	in, _ := json.Marshal(hookInput{Label: e.Label, Tags: e.Tags, Notes: e.Notes, User: user})
+	cmd.Stdin = strings.NewReader(string(in) + "\n")
+	// The hook's output is for the user, and must not end up in ours.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"DDG_LABEL="+e.Label,
+		"DDG_TAGS="+strings.Join(e.Tags, ","),
+		"DDG_USER="+user,
+	)
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: timed out after %s", errHookVeto, hookTimeout)
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("%w (exit status %d)", errHookVeto, ee.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errHookVeto, err)
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~/ with the home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode()&0111 != 0
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// The IMAP watcher follows one mailbox (INBOX unless imap_mailbox says
+// otherwise) on imap_server, over TLS, as imap_user. The password is kept
+// in the keychain ('ddg imap login') when that's the keystore, and read
+// from DDG_IMAP_PASSWORD otherwise. It only ever EXAMINEs the mailbox and
+// fetches headers, so nothing is marked read.
+const (
+	imapServerKey  = "imap_server"
+	imapUserKey    = "imap_user"
+	imapMailboxKey = "imap_mailbox"
+
+	imapPasswordEnv     = "DDG_IMAP_PASSWORD"
+	imapPasswordAccount = "imap"
+
+	defaultIMAPMailbox = "INBOX"
+	defaultIMAPPort    = "993"
+	defaultIMAPEvery   = 2 * time.Minute
+
+	mailActivityFileName = "mail-activity.json"
+	// activityKeep is how many days of per-alias counts are kept, and how
+	// far back the first sync of a mailbox looks.
+	activityKeep = 90
+	// activitySenders is how many of an alias's senders are remembered.
+	activitySenders = 20
+	// imapFetchBatch is how many messages one FETCH asks for.
+	imapFetchBatch = 200
+)
+
+// imapHeaderFields are the headers fetched per message: what mailParties
+// reads, and the date.
+const imapHeaderFields = "FROM TO CC DELIVERED-TO X-ORIGINAL-TO ENVELOPE-TO DATE"
+
+// mailActivity is what the watcher has counted, kept in the data directory.
+// UIDs only mean something within one mailbox and UIDVALIDITY, so a change
+// to either starts the count over.
+type mailActivity struct {
+	Mailbox     string                    `json:"mailbox"` // user@server/mailbox
+	UIDValidity uint32                    `json:"uid_validity"`
+	LastUID     uint32                    `json:"last_uid"`
+	Synced      time.Time                 `json:"synced"`
+	Aliases     map[string]*aliasActivity `json:"aliases"`
+}
+
+type aliasActivity struct {
+	Days     map[string]int `json:"days"` // YYYY-MM-DD to messages received
+	LastSeen time.Time      `json:"last_seen"`
+	Senders  map[string]int `json:"senders"`
+	Alerted  string         `json:"alerted,omitempty"` // the day alert_per_day last fired
+}
+
+func doIMAP(args []string) {
+	sub := ""
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "watch":
+		doIMAPWatch(args)
+	case "login":
+		doIMAPLogin(args)
+	case "logout":
+		for _, a := range args {
+			unknownArg(a)
+		}
+		err := keychainStore{account: imapPasswordAccount}.Delete()
+		audit("imap.logout", err, nil)
+		if err != nil {
+			exitErr(fmt.Errorf("removing the IMAP password from the keychain: %w", err))
+		}
+		out.Done("IMAP password removed from the keychain.", map[string]interface{}{"removed": true})
+	default:
+		exitErr(fmt.Errorf("usage: ddg imap watch [--once] [--every 2m] | login | logout"))
+	}
+}
+
+func doIMAPWatch(args []string) {
+	once, every := false, defaultIMAPEvery
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--once" {
+			once = true
+		} else if args[i] == "--every" && i+1 < len(args) {
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil || d < 10*time.Second {
+				exitErr(fmt.Errorf("--every wants a duration of at least 10s, got %q", args[i+1]))
+			}
+			every = d
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		exitErr(err)
+	}
+	if cfg.IMAPServer == "" || cfg.IMAPUser == "" {
+		exitErr(fmt.Errorf("no IMAP account is set; use 'ddg settings --imap-server <host[:port]> --imap-user <name>'"))
+	}
+	report := func(msg string) { out.Info("%s", msg) }
+	if once {
+		if err := syncIMAP(cfg, report); err != nil {
+			exitErr(err)
+		}
+		return
+	}
+	report(fmt.Sprintf("Watching %s on %s every %s; Ctrl-C to stop.", imapMailbox(cfg), cfg.IMAPServer, every))
+	watchIMAP(cfg, every, nil, report)
+}
+
+// doIMAPLogin stores the IMAP password in the keychain.
+func doIMAPLogin(args []string) {
+	for _, a := range args {
+		unknownArg(a)
+	}
+	cfg, _ := readConfig()
+	if keystoreName(cfg) != keystoreKeychain {
+		exitErr(fmt.Errorf("the IMAP password is only stored in the keychain; move the API key there first ('ddg token migrate --to keychain') or set %s", imapPasswordEnv))
+	}
+	pw := ask(prompt{Label: "IMAP password for " + orDefault(cfg.IMAPUser, "your account"), Secret: true})
+	if pw == "" {
+		exitErr(errCancelled)
+	}
+	err := keychainStore{account: imapPasswordAccount}.Set(pw)
+	audit("imap.login", err, map[string]string{"user": cfg.IMAPUser})
+	if err != nil {
+		exitErr(fmt.Errorf("writing the IMAP password to the keychain: %w", err))
+	}
+	out.Done("IMAP password saved in the keychain.", map[string]interface{}{"saved": true})
+}
+
+// imapSummary describes the watcher's account for 'ddg settings'.
+func imapSummary(v settingsView) string {
+	if v.IMAPServer == "" {
+		return "-"
+	}
+	return v.IMAPUser + " on " + v.IMAPServer + ", " + v.IMAPMailbox
+}
+
+func imapMailbox(cfg conf) string {
+	return orDefault(cfg.IMAPMailbox, defaultIMAPMailbox)
+}
+
+// imapPassword reads the password from the environment or the keychain.
+func imapPassword(cfg conf) (string, error) {
+	if pw := os.Getenv(imapPasswordEnv); pw != "" {
+		registerSecret(pw)
+		return pw, nil
+	}
+	if keystoreName(cfg) == keystoreKeychain {
+		pw, err := keychainStore{account: imapPasswordAccount}.Get()
+		if err == nil && pw != "" {
+			registerSecret(pw)
+			return pw, nil
+		}
+	}
+	return "", fmt.Errorf("no IMAP password: run 'ddg imap login' or set %s", imapPasswordEnv)
+}
+
+// watchIMAP syncs every interval until stop is closed (or forever, for a
+// nil stop), reporting new mail and errors through report. A failed sync
+// is retried at the next tick.
+func watchIMAP(cfg conf, every time.Duration, stop <-chan struct{}, report func(string)) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		if err := syncIMAP(cfg, report); err != nil {
+			report("IMAP: " + err.Error())
+		}
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// syncIMAP counts the mail that arrived since the last sync.
+func syncIMAP(cfg conf, report func(string)) error {
+	pw, err := imapPassword(cfg)
+	if err != nil {
+		return err
+	}
+	act, err := readMailActivity()
+	if err != nil {
+		return err
+	}
+	c, err := dialIMAP(cfg.IMAPServer)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	if _, err := c.cmd("LOGIN " + imapQuote(cfg.IMAPUser) + " " + imapQuote(pw)); err != nil {
+		return fmt.Errorf("logging in as %s: %w", cfg.IMAPUser, err)
+	}
+	mailbox := imapMailbox(cfg)
+	resp, err := c.cmd("EXAMINE " + imapQuote(mailbox))
+	if err != nil {
+		return fmt.Errorf("%s: %w", mailbox, err)
+	}
+	validity := imapUIDValidity(resp)
+
+	key := cfg.IMAPUser + "@" + cfg.IMAPServer + "/" + mailbox
+	search := "UID SEARCH UID " + strconv.FormatUint(uint64(act.LastUID)+1, 10) + ":*"
+	if act.Mailbox != key || act.UIDValidity != validity {
+		// A new mailbox: start from the last activityKeep days of it.
+		act = &mailActivity{Mailbox: key, UIDValidity: validity, Aliases: map[string]*aliasActivity{}}
+		search = "UID SEARCH SINCE " + time.Now().AddDate(0, 0, -activityKeep).Format("2-Jan-2006")
+	}
+	resp, err = c.cmd(search)
+	if err != nil {
+		return err
+	}
+	var uids []uint32
+	for _, r := range resp {
+		if rest, ok := strings.CutPrefix(r.line, "* SEARCH"); ok {
+			for _, f := range strings.Fields(rest) {
+				// n:* always matches the newest message, even an old one.
+				if n, err := strconv.ParseUint(f, 10, 32); err == nil && uint32(n) > act.LastUID {
+					uids = append(uids, uint32(n))
+				}
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	now := time.Now()
+	fresh := map[string]int{}
+	messages := 0
+	for len(uids) > 0 {
+		batch := uids
+		if len(batch) > imapFetchBatch {
+			batch = batch[:imapFetchBatch]
+		}
+		uids = uids[len(batch):]
+		set := make([]string, len(batch))
+		for i, u := range batch {
+			set[i] = strconv.FormatUint(uint64(u), 10)
+		}
+		resp, err := c.cmd("UID FETCH " + strings.Join(set, ",") + " (UID BODY.PEEK[HEADER.FIELDS (" + imapHeaderFields + ")])")
+		if err != nil {
+			return err
+		}
+		for _, r := range resp {
+			uid := imapFetchUID(r.line)
+			if uid == 0 || r.literal == nil {
+				continue
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(append(r.literal, "\r\n"...)))
+			if err != nil {
+				continue
+			}
+			date, err := msg.Header.Date()
+			if err != nil || date.After(now) {
+				date = now
+			}
+			sender, aliases := mailParties(msg.Header)
+			for _, a := range aliases {
+				act.note(a, sender, date)
+				fresh[a]++
+			}
+			if uid > act.LastUID {
+				act.LastUID = uid
+			}
+			messages++
+		}
+	}
+	_, _ = c.cmd("LOGOUT")
+
+	alertTraffic(cfg, act, fresh, report)
+	act.prune(now)
+	act.Synced = now
+	if err := writeMailActivity(act); err != nil {
+		return err
+	}
+	if len(fresh) > 0 {
+		var parts []string
+		for _, a := range topKeys(fresh, len(fresh)) {
+			parts = append(parts, fmt.Sprintf("%s (%d)", a, fresh[a]))
+		}
+		report(fmt.Sprintf("%d new messages to aliases: %s", messages, strings.Join(parts, ", ")))
+	}
+	return nil
+}
+
+func (act *mailActivity) note(alias, sender string, date time.Time) {
+	a := act.Aliases[alias]
+	if a == nil {
+		a = &aliasActivity{Days: map[string]int{}, Senders: map[string]int{}}
+		act.Aliases[alias] = a
+	}
+	a.Days[date.Local().Format("2006-01-02")]++
+	if date.After(a.LastSeen) {
+		a.LastSeen = date
+	}
+	if sender != "" {
+		a.Senders[sender]++
+	}
+}
+
+// prune drops days older than activityKeep, and all but the busiest
+// senders, so the file doesn't grow with the mailbox.
+func (act *mailActivity) prune(now time.Time) {
+	cutoff := now.AddDate(0, 0, -activityKeep).Format("2006-01-02")
+	for _, a := range act.Aliases {
+		for d := range a.Days {
+			if d < cutoff {
+				delete(a.Days, d)
+			}
+		}
+		if len(a.Senders) > activitySenders {
+			keep := map[string]int{}
+			for _, s := range topKeys(a.Senders, activitySenders) {
+				keep[s] = a.Senders[s]
+			}
+			a.Senders = keep
+		}
+	}
+}
+
+func mailActivityPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, mailActivityFileName), nil
+}
+
+func readMailActivity() (*mailActivity, error) {
+	act := &mailActivity{Aliases: map[string]*aliasActivity{}}
+	path, err := mailActivityPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return act, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, act); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if act.Aliases == nil {
+		act.Aliases = map[string]*aliasActivity{}
+	}
+	return act, nil
+}
+
+func writeMailActivity(act *mailActivity) error {
+	path, err := mailActivityPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(act, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// imapConn is just enough of an IMAP4rev1 client for the watcher: tagged
+// commands, and untagged responses with at most one literal each.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+type imapResp struct {
+	line    string // with any literal's {n} marker
+	literal []byte
+}
+
+// dialIMAP connects to addr (port 993 if none is given) over TLS and reads
+// the greeting.
+func dialIMAP(addr string) (*imapConn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, defaultIMAPPort
+	}
+	if err := checkEgress(host); err != nil {
+		return nil, err
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host})
+	if err != nil {
+		return nil, err
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("%s: unexpected greeting %q", addr, greeting)
+	}
+	return c, nil
+}
+
+func (c *imapConn) close() { c.conn.Close() }
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+var imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+
+// cmd sends one command and returns its untagged responses, or the
+// server's NO or BAD as an error.
+func (c *imapConn) cmd(command string) ([]imapResp, error) {
+	c.tag++
+	tag := "D" + strconv.Itoa(c.tag)
+	_ = c.conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+	var resp []imapResp
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return resp, fmt.Errorf("IMAP server said: %s", rest)
+			}
+			return resp, nil
+		}
+		r := imapResp{line: line}
+		// A literal is followed by the rest of the response on a new line.
+		for {
+			m := imapLiteral.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			n, _ := strconv.Atoi(m[1])
+			if n > mailHeaderMax {
+				return nil, fmt.Errorf("IMAP literal of %d bytes is too large", n)
+			}
+			lit := make([]byte, n)
+			if _, err := io.ReadFull(c.r, lit); err != nil {
+				return nil, err
+			}
+			if r.literal == nil {
+				r.literal = lit
+			}
+			if line, err = c.readLine(); err != nil {
+				return nil, err
+			}
+			r.line += " " + line
+		}
+		resp = append(resp, r)
+	}
+}
+
+// imapQuote makes s an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(s)
+	return `"` + s + `"`
+}
+
+var (
+	imapUIDValidityCode = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+	imapUIDItem         = regexp.MustCompile(`^\* \d+ FETCH \(.*?\bUID (\d+)`)
+)
+
+func imapUIDValidity(resp []imapResp) uint32 {
+	for _, r := range resp {
+		if m := imapUIDValidityCode.FindStringSubmatch(r.line); m != nil {
+			n, _ := strconv.ParseUint(m[1], 10, 32)
+			return uint32(n)
+		}
+	}
+	return 0
+}
+
+func imapFetchUID(line string) uint32 {
+	m := imapUIDItem.FindStringSubmatch(line)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(m[1], 10, 32)
+	return uint32(n)
+}
+
+// noisyAlias is one row of 'ddg noisiest'.
+type noisyAlias struct {
+	Address   string    `json:"address"`
+	Label     string    `json:"label,omitempty"`
+	Messages  int       `json:"messages"`
+	LastSeen  time.Time `json:"last_seen"`
+	TopSender string    `json:"top_sender,omitempty"`
+}
+
+// doNoisiest ranks live aliases by the mail the IMAP watcher counted for
+// them over the last days, to help pick what to burn.
+func doNoisiest(args []string) {
+	days, top := 30, 10
+	for i := 0; i < len(args); i++ {
+		if (args[i] == "--days" || args[i] == "--top") && i+1 < len(args) {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				exitErr(fmt.Errorf("%s needs a positive number", args[i]))
+			}
+			if args[i] == "--days" {
+				days = n
+			} else {
+				top = n
+			}
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if days > activityKeep {
+		exitErr(fmt.Errorf("--days can be at most %d; older counts aren't kept", activityKeep))
+	}
+	act, err := readMailActivity()
+	if err != nil {
+		exitErr(err)
+	}
+	if act.Synced.IsZero() {
+		exitErr(fmt.Errorf("no mail has been counted yet; run 'ddg imap watch' (or 'ddg serve' with imap_server set)"))
+	}
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	var rows []noisyAlias
+	for _, e := range entries {
+		a := act.Aliases[ddg.NormalizeAlias(e.Address)]
+		if a == nil {
+			continue
+		}
+		n := 0
+		for d, c := range a.Days {
+			if d > cutoff {
+				n += c
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		row := noisyAlias{Address: e.Address, Label: e.Label, Messages: n, LastSeen: a.LastSeen}
+		if s := topKeys(a.Senders, 1); len(s) > 0 {
+			row.TopSender = s[0]
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Messages > rows[j].Messages })
+	if len(rows) > top {
+		rows = rows[:top]
+	}
+	printList(rows, func(r noisyAlias) string {
+		return fmt.Sprintf("%4d  %s  %s  last %s, mostly from %s", r.Messages, cyan(r.Address), orDefault(r.Label, "-"),
+			r.LastSeen.Local().Format("2006-01-02"), orDefault(r.TopSender, "-"))
+	})
+	if !out.machine() {
+		fmt.Printf("Messages per alias over the last %d days (counted up to %s). 'ddg delete <address>' burns one.\n",
+			days, act.Synced.Local().Format("2006-01-02 15:04"))
+	}
+}
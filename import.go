@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// importFormat names the columns of a password manager's CSV export that
+// import reads; each lists the header names seen across versions. Nothing
+// else, the passwords least of all, is kept.
+type importFormat struct {
+	Name  string
+	URL   []string
+	User  []string
+	Title []string
+}
+
+var importFormats = map[string]importFormat{
+	"lastpass":      {Name: "LastPass", URL: []string{"url"}, User: []string{"username"}, Title: []string{"name"}},
+	"bitwarden":     {Name: "Bitwarden", URL: []string{"login_uri"}, User: []string{"login_username"}, Title: []string{"name"}},
+	"1password-csv": {Name: "1Password", URL: []string{"url", "website"}, User: []string{"username"}, Title: []string{"title"}},
+}
+
+func doImport(args []string) {
+	format, path := "", ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--format" && i+1 < len(args) {
+			format = strings.ToLower(args[i+1])
+			i++
+		} else if path == "" && (args[i] == "-" || !strings.HasPrefix(args[i], "--")) {
+			path = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	f, ok := importFormats[format]
+	if !ok || path == "" {
+		exitErr(fmt.Errorf("usage: ddg import --format lastpass|bitwarden|1password-csv <file>"))
+	}
+	requireWritable("rewrite history")
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			exitErr(err)
+		}
+		defer file.Close()
+		r = file
+	}
+	found, err := readImport(r, f, time.Now().UTC())
+	if err != nil {
+		exitErr(fmt.Errorf("%s: %w", path, err))
+	}
+
+	local, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(local)
+	before := len(mergeHistory(local, nil))
+	merged := mergeHistory(local, found)
+	err = writeHistory(merged, seen)
+	added := len(merged) - before
+	audit("history.import", err, map[string]string{"format": format, "found": strconv.Itoa(len(found)), "added": strconv.Itoa(added)})
+	if err != nil {
+		exitErr(err)
+	}
+	msg := fmt.Sprintf("Found %d logins with a Duck alias in the %s export: %d aliases added to history, %d already there.",
+		len(found), f.Name, added, len(mergeHistory(found, nil))-added)
+	if path != "-" {
+		msg += "\n" + sym("⚠️") + " " + path + " still holds your passwords; delete it when you're done."
+	}
+	out.Done(msg, map[string]interface{}{"format": format, "found": len(found), "added": added, "total": len(merged)})
+}
+
+// readImport returns a history entry for every row whose username is a
+// @duck.com address, labelled with the site the login is for.
+func readImport(r io.Reader, f importFormat, now time.Time) ([]historyEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, dup := cols[h]; !dup {
+			cols[h] = i
+		}
+	}
+	col := func(names []string) int {
+		for _, n := range names {
+			if i, ok := cols[n]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	urlCol, userCol, titleCol := col(f.URL), col(f.User), col(f.Title)
+	if userCol < 0 {
+		return nil, fmt.Errorf("no %s column; is this a %s export?", f.User[0], f.Name)
+	}
+	field := func(rec []string, i int) string {
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var entries []historyEntry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		addr := ddg.NormalizeAlias(field(rec, userCol))
+		if !ddg.IsAlias(addr) {
+			continue
+		}
+		label := importLabel(field(rec, urlCol))
+		if label == "" {
+			label = cleanURLField(field(rec, titleCol))
+		}
+		e := historyEntry{Address: addr, Created: now, Label: label, Notes: "imported from " + f.Name}
+		if label != "" {
+			e.Notes += " (" + label + ")"
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// importLabel turns a login's URL into a label the way the bookmarklet
+// does: the host, lower-cased, without "www.". Bitwarden puts several
+// URIs in one field; the first is used.
+func importLabel(raw string) string {
+	raw, _, _ = strings.Cut(raw, ",")
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	// LastPass gives secure notes the URL http://sn.
+	if err != nil || !strings.Contains(u.Hostname(), ".") {
+		return ""
+	}
+	return cleanURLField(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// burnedTag marks an alias the user has deactivated.
+const burnedTag = "burned"
+
+// insights is the summary 'ddg insights' computes from local files only.
+type insights struct {
+	Total          int            `json:"total"`
+	First          *time.Time     `json:"first,omitempty"`
+	Last           *time.Time     `json:"last,omitempty"`
+	PerMonth       float64        `json:"average_per_month"`
+	Burned         int            `json:"burned"`
+	BurnedRatio    float64        `json:"burned_ratio"`
+	Weekdays       map[string]int `json:"by_weekday"`
+	BusiestDays    []countedItem  `json:"busiest_days"`
+	TopTags        []countedItem  `json:"top_tags"`
+	GenerateErrors int            `json:"generate_errors"`
+	Actions        map[string]int `json:"actions"`
+}
+
+type countedItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func doInsights() {
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	events, err := readAudit()
+	if err != nil {
+		exitErr(err)
+	}
+	in := computeInsights(entries, events)
+	out.Result(in, formatInsights(in))
+}
+
+func computeInsights(entries []historyEntry, events []auditEvent) insights {
+	in := insights{Weekdays: map[string]int{}, Actions: map[string]int{}}
+	days := map[string]int{}
+	tags := map[string]int{}
+	for _, e := range entries {
+		in.Total++
+		if hasTag(e.Tags, burnedTag) {
+			in.Burned++
+		}
+		for _, t := range e.Tags {
+			if !strings.EqualFold(t, burnedTag) {
+				tags[strings.ToLower(t)]++
+			}
+		}
+		if e.Created.IsZero() {
+			continue
+		}
+		c := e.Created
+		if in.First == nil || c.Before(*in.First) {
+			in.First = &c
+		}
+		if in.Last == nil || c.After(*in.Last) {
+			in.Last = &c
+		}
+		local := c.Local()
+		in.Weekdays[local.Weekday().String()]++
+		days[local.Format("2006-01-02")]++
+	}
+	if in.Total > 0 {
+		in.BurnedRatio = float64(in.Burned) / float64(in.Total)
+	}
+	if in.First != nil {
+		months := in.Last.Sub(*in.First).Hours() / 24 / 30.44
+		if months < 1 {
+			months = 1
+		}
+		in.PerMonth = float64(in.Total) / months
+	}
+	in.BusiestDays = topCounts(days, 3)
+	in.TopTags = topCounts(tags, 5)
+
+	for _, ev := range events {
+		in.Actions[ev.Action]++
+		if ev.Action == "generate" && ev.Outcome != "ok" {
+			in.GenerateErrors++
+		}
+	}
+	return in
+}
+
+func topCounts(m map[string]int, n int) []countedItem {
+	items := make([]countedItem, 0, len(m))
+	for k, v := range m {
+		items = append(items, countedItem{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Name < items[j].Name
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+func formatInsights(in insights) string {
+	if in.Total == 0 {
+		return "No aliases in history yet. Generate some and come back!"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Aliases generated: %d", in.Total)
+	if in.First != nil {
+		fmt.Fprintf(&b, " (%s – %s)", in.First.Local().Format("2006-01-02"), in.Last.Local().Format("2006-01-02"))
+	}
+	fmt.Fprintf(&b, "\nAverage per month: %.1f\n", in.PerMonth)
+	fmt.Fprintf(&b, "Burned: %d (%.0f%%)\n", in.Burned, in.BurnedRatio*100)
+
+	b.WriteString("\nBy weekday:\n")
+	for _, d := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		n := in.Weekdays[d.String()]
+		fmt.Fprintf(&b, "  %-9s %3d", d.String(), n)
+		if bar := strings.Repeat("█", n*30/maxInt(in.Total, 1)); bar != "" {
+			b.WriteString(" " + bar)
+		}
+		b.WriteString("\n")
+	}
+	if len(in.BusiestDays) > 0 {
+		b.WriteString("\nBusiest signup days:\n")
+		for _, d := range in.BusiestDays {
+			fmt.Fprintf(&b, "  %s  %d\n", d.Name, d.Count)
+		}
+	}
+	if len(in.TopTags) > 0 {
+		b.WriteString("\nTop tags:\n")
+		for _, t := range in.TopTags {
+			fmt.Fprintf(&b, "  %-20s %d\n", t.Name, t.Count)
+		}
+	}
+	if in.GenerateErrors > 0 {
+		fmt.Fprintf(&b, "\nFailed generations: %d\n", in.GenerateErrors)
+	}
+	b.WriteString("\nAll of this was computed locally; nothing left your machine.")
+	return b.String()
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func readAudit() (events []auditEvent, err error) {
+	path, err := auditPath()
+	if err != nil {
+		return nil, err
+	}
+	span := storageSpan("ddg.audit.read", path)
+	defer func() {
+		span.SetAttributes(ddg.Attr{Key: "ddg.entries", Value: len(events)})
+		span.End(err)
+	}()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var ev auditEvent
+		if json.Unmarshal(sc.Bytes(), &ev) == nil {
+			events = append(events, ev)
+		}
+	}
+	return events, sc.Err()
+}
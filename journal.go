@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const journalDirName = "journal"
+
+// journal records the progress of a multi-address operation so that a run
+// which dies midway (rate limit, network, Ctrl-C) can be resumed without
+// generating a second alias for anything that already got one.
+type journal struct {
+	path string
+	done map[string]string
+	f    *os.File
+}
+
+type journalRecord struct {
+	Key     string    `json:"key"`
+	Address string    `json:"address"`
+	Time    time.Time `json:"time"`
+}
+
+// openJournal opens the journal for operation op on input id (e.g. the CSV
+// path). An unfinished journal is only picked up when resume is set; restart
+// discards it. Otherwise an existing journal is an error, because silently
+// starting over is exactly how duplicate aliases get made.
+func openJournal(op, id string, resume, restart bool) (*journal, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, journalDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(op + "\x00" + id))
+	path := filepath.Join(dir, op+"-"+hex.EncodeToString(sum[:8])+".jsonl")
+
+	if restart {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	j := &journal{path: path, done: map[string]string{}}
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil && !resume:
+		return nil, fmt.Errorf("an interrupted %s run was found for %s; rerun with --resume to continue or --restart to start over", op, id)
+	case err == nil:
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		for sc.Scan() {
+			var rec journalRecord
+			if json.Unmarshal(sc.Bytes(), &rec) == nil && rec.Key != "" {
+				j.done[rec.Key] = rec.Address
+			}
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	j.f, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Done reports whether key was completed by an earlier run, and the address
+// it received.
+func (j *journal) Done(key string) (string, bool) {
+	addr, ok := j.done[key]
+	return addr, ok
+}
+
+// Len is the number of keys already completed.
+func (j *journal) Len() int {
+	return len(j.done)
+}
+
+// Record marks key as completed. It is written through immediately so a
+// crash right after generation still leaves a trace.
+func (j *journal) Record(key, address string) error {
+	line, err := json.Marshal(journalRecord{Key: key, Address: address, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.done[key] = address
+	return j.f.Sync()
+}
+
+// Close closes the journal but keeps it on disk for a later --resume.
+func (j *journal) Close() error {
+	return j.f.Close()
+}
+
+// Finish removes the journal once the whole operation succeeded.
+func (j *journal) Finish() error {
+	j.f.Close()
+	return os.Remove(j.path)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	keystoreFile     = "file"
+	keystoreKeychain = "keychain"
+
+	keychainService = "duckduckgone"
+	keychainAccount = "api"
+)
+
+// keystore is a place the API key can be kept outside of the config file.
+type keystore interface {
+	Get() (string, error)
+	Set(token string) error
+	Delete() error
+}
+
+// keystoreName returns the configured keystore, defaulting to the config file.
+func keystoreName(c conf) string {
+	if c.Keystore == "" {
+		return keystoreFile
+	}
+	return c.Keystore
+}
+
+func openKeystore(name string) (keystore, error) {
+	switch name {
+	case keystoreKeychain:
+		return keychainStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown keystore %q (use file or keychain)", name)
+	}
+}
+
+// loadToken fills in c.APIKey when the key is kept in an external keystore.
+func loadToken(c *conf) error {
+	name := keystoreName(*c)
+	if name == keystoreFile {
+		return nil
+	}
+	ks, err := openKeystore(name)
+	if err != nil {
+		return err
+	}
+	token, err := ks.Get()
+	if err != nil {
+		return fmt.Errorf("reading API key from %s: %w", name, err)
+	}
+	c.APIKey = token
+	return nil
+}
+
+// saveToken stores token in the configured keystore and writes the config.
+func saveToken(c *conf, token string) error {
+	c.APIKey = token
+	// What's cached described the old key.
+	_ = setState(stateAccount, "")
+	_ = setState(stateToken, "")
+	if name := keystoreName(*c); name != keystoreFile {
+		ks, err := openKeystore(name)
+		if err != nil {
+			return err
+		}
+		if err := ks.Set(token); err != nil {
+			return fmt.Errorf("writing API key to %s: %w", name, err)
+		}
+	}
+	return writeConfig(*c)
+}
+
+// keychainStore keeps the key in the OS credential store: the login keychain
+// on macOS and the Secret Service (via secret-tool) on Linux. The zero value
+// holds the API key; other secrets use their own account. Accounts of named
+// profiles are suffixed with "@<profile>".
+type keychainStore struct {
+	account string
+}
+
+func (k keychainStore) acct() string {
+	a := k.account
+	if a == "" {
+		a = keychainAccount
+	}
+	if profileName != "" {
+		a += "@" + profileName
+	}
+	return a
+}
+
+func (k keychainStore) Get() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", k.acct(), "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", k.acct())
+	default:
+		return "", fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (k keychainStore) Set(token string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// Through 'security -i' on stdin: as an argument, the secret would
+		// show in ps to every user on the machine.
+		if strings.ContainsAny(token, "\r\n") {
+			return fmt.Errorf("a keychain secret can't contain line breaks")
+		}
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keychainService), securityQuote(k.acct()), securityQuote(token)))
+		// It exits 0 whatever its commands did; failures only show on stderr.
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("security: %s", msg)
+		}
+		return nil
+	case "linux":
+		label := "DuckDuckGone API key"
+		if k.account != "" {
+			label = "DuckDuckGone " + k.account
+		}
+		cmd = exec.Command("secret-tool", "store", "--label="+label, "service", keychainService, "account", k.acct())
+		cmd.Stdin = bytes.NewBufferString(token)
+	default:
+		return fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
+
+// securityQuote quotes s as one word for 'security -i'.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (k keychainStore) Delete() error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", k.acct())
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", keychainService, "account", k.acct())
+	default:
+		return fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
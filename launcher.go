@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// launcherGenerate prefixes the argument of the "generate new" item; the
+// rest is the label to generate with.
+const launcherGenerate = "generate:"
+
+// launcherItem is one row shown by a launcher. Arg is handed back to
+// 'ddg launcher --run' when the row is picked.
+type launcherItem struct {
+	UID      string
+	Title    string
+	Subtitle string
+	Arg      string
+}
+
+// launcherRenderer writes items in one launcher's expected format. Supporting
+// a new launcher means adding one here.
+type launcherRenderer func(w io.Writer, items []launcherItem) error
+
+var launcherRenderers = map[string]launcherRenderer{
+	"alfred":  renderAlfred,
+	"raycast": renderRaycast,
+	"rofi":    renderRofi,
+	"walker":  renderWalker,
+}
+
+// launcherProtocols returns the supported --protocol names, sorted.
+func launcherProtocols() []string {
+	names := make([]string, 0, len(launcherRenderers))
+	for n := range launcherRenderers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func doLauncher(args []string) {
+	protocol, run := "", ""
+	var query []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--protocol" && i+1 < len(args) {
+			protocol = strings.ToLower(args[i+1])
+			i++
+		} else if args[i] == "--run" && i+1 < len(args) {
+			run = args[i+1]
+			i++
+		} else {
+			query = append(query, args[i])
+		}
+	}
+
+	// rofi runs the same script again with the picked row.
+	if protocol == "rofi" && os.Getenv("ROFI_RETV") == "1" {
+		run = os.Getenv("ROFI_INFO")
+		query = nil
+	}
+	if run != "" {
+		launcherRun(run)
+		return
+	}
+
+	render, ok := launcherRenderers[protocol]
+	if !ok {
+		exitErr(fmt.Errorf("usage: ddg launcher --protocol <%s> [<query>]", strings.Join(launcherProtocols(), "|")))
+	}
+	if err := render(os.Stdout, launcherItems(strings.Join(query, " "))); err != nil {
+		exitErr(err)
+	}
+}
+
+// launcherItems is what every launcher shows: "generate new" first, then
+// the history matching query, newest first.
+func launcherItems(query string) []launcherItem {
+	gen := launcherItem{UID: "generate", Title: "Generate new alias", Subtitle: "Copies it to the clipboard", Arg: launcherGenerate + query}
+	if query != "" {
+		gen.Title = fmt.Sprintf("Generate new alias for %q", query)
+	}
+	items := []launcherItem{gen}
+
+	entries, _ := liveHistory()
+	entries = mergeHistory(entries, nil)
+	q := strings.ToLower(query)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if q != "" && !strings.Contains(strings.ToLower(e.Address+" "+e.Label+" "+strings.Join(e.Tags, " ")), q) {
+			continue
+		}
+		sub := e.Created.Local().Format("2006-01-02")
+		if e.Label != "" {
+			sub = e.Label + " · " + sub
+		}
+		items = append(items, launcherItem{UID: e.Address, Title: e.Address, Subtitle: sub, Arg: e.Address})
+	}
+	return items
+}
+
+// launcherRun carries out a picked row: generate, or copy an existing
+// address. The address is also printed for launchers that paste output.
+func launcherRun(arg string) {
+	cfg, err := loadSettings(false)
+	if err != nil {
+		exitErr(err)
+	}
+	addr := arg
+	if strings.HasPrefix(arg, launcherGenerate) {
+		requireWritable("generate an alias")
+		if cfg, err = ensureConfig(false); err != nil {
+			exitErr(err)
+		}
+		e, err := generateAlias(cfg, historyEntry{Label: strings.TrimSpace(strings.TrimPrefix(arg, launcherGenerate))})
+		if err != nil {
+			exitErr(err)
+		}
+		addr = e.Address
+	}
+	if _, err := copyToClipboard(cfg, addr); err != nil {
+		fmt.Fprintf(os.Stderr, "Clipboard copy failed: %v\n", err)
+	}
+	fmt.Println(addr)
+}
+
+// renderAlfred writes Script Filter JSON.
+func renderAlfred(w io.Writer, items []launcherItem) error {
+	type alfredItem struct {
+		UID          string `json:"uid"`
+		Title        string `json:"title"`
+		Subtitle     string `json:"subtitle"`
+		Arg          string `json:"arg"`
+		Autocomplete string `json:"autocomplete,omitempty"`
+	}
+	res := struct {
+		Items []alfredItem `json:"items"`
+	}{Items: []alfredItem{}}
+	for _, it := range items {
+		ai := alfredItem{UID: it.UID, Title: it.Title, Subtitle: it.Subtitle, Arg: it.Arg}
+		if it.UID != "generate" {
+			ai.Autocomplete = it.Title
+		}
+		res.Items = append(res.Items, ai)
+	}
+	return json.NewEncoder(w).Encode(res)
+}
+
+// renderRaycast writes the JSON list a Raycast script command or extension
+// turns into a List, with the command to run for each row.
+func renderRaycast(w io.Writer, items []launcherItem) error {
+	self := launcherSelf()
+	type raycastItem struct {
+		ID       string   `json:"id"`
+		Title    string   `json:"title"`
+		Subtitle string   `json:"subtitle"`
+		Command  []string `json:"command"`
+	}
+	list := []raycastItem{}
+	for _, it := range items {
+		list = append(list, raycastItem{ID: it.UID, Title: it.Title, Subtitle: it.Subtitle, Command: []string{self, "launcher", "--run", it.Arg}})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{"items": list})
+}
+
+// renderRofi writes rofi script-mode rows; the argument travels in the
+// row's info field and comes back as ROFI_INFO.
+func renderRofi(w io.Writer, items []launcherItem) error {
+	fmt.Fprint(w, "\x00prompt\x1fddg\n\x00markup-rows\x1ffalse\n")
+	for _, it := range items {
+		if _, err := fmt.Fprintf(w, "%s  (%s)\x00info\x1f%s\n", it.Title, it.Subtitle, it.Arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderWalker writes entries for a Walker external module.
+func renderWalker(w io.Writer, items []launcherItem) error {
+	self := launcherSelf()
+	type walkerItem struct {
+		Label string `json:"label"`
+		Sub   string `json:"sub"`
+		Exec  string `json:"exec"`
+	}
+	list := []walkerItem{}
+	for _, it := range items {
+		list = append(list, walkerItem{Label: it.Title, Sub: it.Subtitle, Exec: shellQuote(self) + " launcher --run " + shellQuote(it.Arg)})
+	}
+	return json.NewEncoder(w).Encode(list)
+}
+
+func launcherSelf() string {
+	if p, err := os.Executable(); err == nil {
+		return p
+	}
+	return "ddg"
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// aliasLeak is one row of 'ddg report leaks': an alias getting mail from
+// senders that have nothing to do with what it was made for.
+type aliasLeak struct {
+	Address   string        `json:"address"`
+	Label     string        `json:"label"`
+	Unrelated []countedItem `json:"unrelated_senders"` // sender domains, busiest first
+	Messages  int           `json:"unrelated_messages"`
+	Related   int           `json:"related_messages"`
+}
+
+func doReport(args []string) {
+	if len(args) == 0 || args[0] != "leaks" {
+		exitErr(fmt.Errorf("usage: ddg report leaks [--top <n>]"))
+	}
+	top := 0
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--top" && i+1 < len(args) {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				exitErr(fmt.Errorf("--top needs a positive number"))
+			}
+			top = n
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	act, err := readMailActivity()
+	if err != nil {
+		exitErr(err)
+	}
+	leaks, judged, seen := findLeaks(entries, act)
+	if seen == 0 {
+		exitErr(fmt.Errorf("no mail has been seen for any alias yet; run 'ddg scan-mail <mailbox>' or 'ddg imap watch' first"))
+	}
+	if top > 0 && len(leaks) > top {
+		leaks = leaks[:top]
+	}
+	printList(leaks, func(l aliasLeak) string {
+		var from []string
+		for _, s := range l.Unrelated {
+			from = append(from, fmt.Sprintf("%s (%d)", s.Name, s.Count))
+		}
+		return fmt.Sprintf("%s  %s  %d unrelated messages from %s", cyan(l.Address), l.Label, l.Messages, strings.Join(from, ", "))
+	})
+	if !out.machine() {
+		fmt.Printf("%d of %d aliases with mail and a label get mail from senders unrelated to it.", len(leaks), judged)
+		if skipped := seen - judged; skipped > 0 {
+			fmt.Printf(" %d more have no usable label to judge by.", skipped)
+		}
+		fmt.Println()
+	}
+}
+
+// findLeaks ranks the aliases that get mail from unrelated senders, by how
+// many such senders, then how many messages. Senders come from the IMAP
+// watcher's counts and from what scan-mail noted on history entries. It
+// also returns how many aliases had mail, and how many of those could be
+// judged by their label.
+func findLeaks(entries []historyEntry, act *mailActivity) (leaks []aliasLeak, judged, seen int) {
+	for _, e := range entries {
+		senders := map[string]int{}
+		if a := act.Aliases[ddg.NormalizeAlias(e.Address)]; a != nil {
+			for s, n := range a.Senders {
+				senders[s] += n
+			}
+		}
+		for _, s := range strings.Fields(e.Fields[fieldTopSenders]) {
+			if _, ok := senders[s]; !ok {
+				senders[s] = 1
+			}
+		}
+		if len(senders) == 0 {
+			continue
+		}
+		seen++
+		keys := labelKeys(e.Label)
+		if len(keys) == 0 {
+			continue
+		}
+		judged++
+		l := aliasLeak{Address: e.Address, Label: e.Label}
+		domains := map[string]int{}
+		for s, n := range senders {
+			_, domain, _ := strings.Cut(s, "@")
+			if senderRelated(domain, keys) {
+				l.Related += n
+				continue
+			}
+			domains[domain] += n
+			l.Messages += n
+		}
+		if len(domains) == 0 {
+			continue
+		}
+		for _, d := range topKeys(domains, len(domains)) {
+			l.Unrelated = append(l.Unrelated, countedItem{Name: d, Count: domains[d]})
+		}
+		leaks = append(leaks, l)
+	}
+	sort.SliceStable(leaks, func(i, j int) bool {
+		if len(leaks[i].Unrelated) != len(leaks[j].Unrelated) {
+			return len(leaks[i].Unrelated) > len(leaks[j].Unrelated)
+		}
+		return leaks[i].Messages > leaks[j].Messages
+	})
+	return leaks, judged, seen
+}
+
+// labelKeys returns the names a sender related to label would have in its
+// domain: the site name of a label that is a host ("github" for
+// github.com), otherwise the label's words. Words under three letters say
+// too little to go by.
+func labelKeys(label string) []string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if strings.Contains(label, ".") && !strings.ContainsAny(label, " /") {
+		if name := siteName(label); len(name) >= 3 {
+			return []string{name}
+		}
+		return nil
+	}
+	var keys []string
+	for _, w := range strings.FieldsFunc(label, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len(w) >= 3 {
+			keys = append(keys, w)
+		}
+	}
+	return keys
+}
+
+// siteName is the name a host is registered under: "github" for
+// mail.github.com, "bbc" for www.bbc.co.uk.
+func siteName(host string) string {
+	parts := strings.Split(strings.Trim(host, "."), ".")
+	if len(parts) < 2 {
+		return host
+	}
+	parts = parts[:len(parts)-1]
+	// Two-part suffixes like co.uk and com.au.
+	if n := len(parts); n >= 2 && len(host)-strings.LastIndexByte(host, '.') == 3 {
+		switch parts[n-1] {
+		case "co", "com", "org", "net", "ac", "gov", "edu":
+			parts = parts[:n-1]
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+// senderRelated reports whether domain (example.com of user@example.com)
+// belongs to what keys name. Mail from Duck itself is about the alias, so
+// it's related to all of them.
+func senderRelated(domain string, keys []string) bool {
+	domain = strings.ToLower(domain)
+	if domain == ddg.Domain || strings.HasSuffix(domain, ".duckduckgo.com") || domain == "duckduckgo.com" {
+		return true
+	}
+	for _, k := range keys {
+		if strings.Contains(domain, k) {
+			return true
+		}
+	}
+	return false
+}
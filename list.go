@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// listedAlias is a history entry with the number list and search show it
+// under: 1 is the newest alias, so 'ddg show 1' is the one just made.
+type listedAlias struct {
+	ID int `json:"id"`
+	historyEntry
+}
+
+func (l listedAlias) plain() string { return l.Address }
+
+// numberedHistory returns the live history, one entry per address, newest
+// first and numbered from 1.
+func numberedHistory() ([]listedAlias, error) {
+	entries, err := liveHistory()
+	if err != nil {
+		return nil, err
+	}
+	entries = mergeHistory(entries, nil)
+	rows := make([]listedAlias, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		rows = append(rows, listedAlias{ID: len(rows) + 1, historyEntry: entries[i]})
+	}
+	return rows, nil
+}
+
+func listLine(l listedAlias) string {
+	s := fmt.Sprintf("%3d  %s  %s", l.ID, l.Created.Local().Format("2006-01-02 15:04"), cyan(l.Address))
+	if l.Label != "" {
+		s += "  " + l.Label
+	}
+	if len(l.Tags) > 0 {
+		s += "  [" + strings.Join(l.Tags, ", ") + "]"
+	}
+	return s
+}
+
+// doList prints the aliases in history, newest first.
+func doList(args []string) {
+	var since time.Time
+	var tags []string
+	limit := 0
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--since" && i+1 < len(args) {
+			t, err := parseSince(args[i+1])
+			if err != nil {
+				exitErr(fmt.Errorf("--since: %w", err))
+			}
+			since = t
+			i++
+		} else if args[i] == "--tag" && i+1 < len(args) {
+			tags = append(tags, args[i+1])
+			i++
+		} else if args[i] == "--namespace" && i+1 < len(args) {
+			tag, err := namespaceTag(args[i+1])
+			if err != nil {
+				exitErr(err)
+			}
+			tags = append(tags, tag)
+			i++
+		} else if args[i] == "--limit" && i+1 < len(args) {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				exitErr(fmt.Errorf("--limit needs a positive number"))
+			}
+			limit = n
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	rows, err := numberedHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var shown []listedAlias
+	for _, r := range rows {
+		if r.Created.Before(since) {
+			break // newest first, so nothing after this is newer either
+		}
+		if !hasTags(r.Tags, tags) {
+			continue
+		}
+		shown = append(shown, r)
+		if limit > 0 && len(shown) == limit {
+			break
+		}
+	}
+	printList(shown, listLine)
+}
+
+// doSearch prints the aliases whose address, label, tags, notes or field
+// values contain every one of the terms, ignoring case.
+func doSearch(args []string) {
+	var terms []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "--") {
+			unknownArg(a)
+		}
+		terms = append(terms, strings.ToLower(a))
+	}
+	if len(terms) == 0 {
+		exitErr(fmt.Errorf("usage: ddg search <term>..."))
+	}
+	rows, err := numberedHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var found []listedAlias
+	for _, r := range rows {
+		text := strings.ToLower(strings.Join([]string{r.Address, r.Label, strings.Join(r.Tags, " "), r.Notes, formatFields(r.Fields)}, " "))
+		match := true
+		for _, t := range terms {
+			if !strings.Contains(text, t) {
+				match = false
+				break
+			}
+		}
+		if match {
+			found = append(found, r)
+		}
+	}
+	printList(found, listLine)
+	if len(found) == 0 && !out.machine() {
+		fmt.Fprintf(os.Stderr, "No aliases match %q.\n", strings.Join(args, " "))
+	}
+}
+
+// doShow prints everything history knows about one alias, given as its
+// number in 'ddg list', its address or its label.
+func doShow(args []string) {
+	if len(args) != 1 {
+		exitErr(fmt.Errorf("usage: ddg show <number|address|label>"))
+	}
+	rows, err := numberedHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	r, ok := findListed(rows, args[0])
+	if !ok {
+		exitErr(fmt.Errorf("no alias %q in history; 'ddg list' numbers them, newest first", args[0]))
+	}
+	lines := []string{
+		"Address: " + cyan(hyperlink("mailto:"+r.Address, r.Address)),
+		"Label:   " + emptyToDash(r.Label),
+		"Created: " + r.Created.Local().Format("2006-01-02 15:04 MST"),
+	}
+	if len(r.Tags) > 0 {
+		lines = append(lines, "Tags:    "+strings.Join(r.Tags, ", "))
+	}
+	if r.Notes != "" {
+		lines = append(lines, "Notes:   "+r.Notes)
+	}
+	if len(r.Fields) > 0 {
+		lines = append(lines, "Fields:  "+formatFields(r.Fields))
+	}
+	if r.Review != nil {
+		lines = append(lines, "Review:  "+r.Review.Local().Format("2006-01-02"))
+	}
+	if r.By != "" {
+		lines = append(lines, "By:      "+r.By)
+	}
+	out.Result(r, strings.Join(lines, "\n"))
+}
+
+func findListed(rows []listedAlias, key string) (listedAlias, bool) {
+	if n, err := strconv.Atoi(key); err == nil {
+		if n >= 1 && n <= len(rows) {
+			return rows[n-1], true
+		}
+		return listedAlias{}, false
+	}
+	addr := ddg.NormalizeAlias(key)
+	for _, r := range rows {
+		if ddg.NormalizeAlias(r.Address) == addr {
+			return r, true
+		}
+	}
+	want := siteKey(key)
+	for _, r := range rows {
+		if siteKey(r.Label) == want {
+			return r, true
+		}
+	}
+	return listedAlias{}, false
+}
+
+// parseSince reads a date, or a span back from today: "7d" is a week ago.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(s), time.Local); err == nil {
+		return t, nil
+	}
+	t, err := parseWhen(s)
+	if err != nil {
+		return t, err
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return today.Add(-t.Sub(today)), nil
+}
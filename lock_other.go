@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+// lockFile is a no-op where there's no file locking; concurrent writers
+// fall back to last-writer-wins.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Email-to-self: every generated alias is also mailed to mail_to, as a
+// record that doesn't live on this machine. smtp_server (host:port) sends
+// through that server, logging in as smtp_user with the password in
+// DDG_SMTP_PASSWORD; without it the system sendmail is used.
+const (
+	mailToKey     = "mail_to"
+	smtpServerKey = "smtp_server"
+	smtpUserKey   = "smtp_user"
+
+	smtpPasswordEnv = "DDG_SMTP_PASSWORD"
+)
+
+// sendMail mails subject and body to cfg.MailTo, from the same address.
+func sendMail(cfg conf, subject, body string) error {
+	if cfg.MailTo == "" {
+		return fmt.Errorf("no mail_to address is set")
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n",
+		cfg.MailTo, cfg.MailTo, subject, time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nX-Mailer: ddg " + version + "\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n") + "\r\n")
+
+	if cfg.SMTPServer == "" {
+		if !hasCommand("sendmail") {
+			return fmt.Errorf("no smtp_server is set and sendmail isn't installed")
+		}
+		cmd := exec.Command("sendmail", "-i", cfg.MailTo)
+		cmd.Stdin = &msg
+		if b, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("sendmail: %v: %s", err, strings.TrimSpace(string(b)))
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(cfg.SMTPServer)
+	if err != nil {
+		return fmt.Errorf("smtp_server: %w", err)
+	}
+	if err := checkEgress(host); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		// smtp.SendMail upgrades with STARTTLS when the server offers it,
+		// and PlainAuth refuses to send the password otherwise.
+		auth = smtp.PlainAuth("", cfg.SMTPUser, os.Getenv(smtpPasswordEnv), host)
+	}
+	return smtp.SendMail(cfg.SMTPServer, auth, cfg.MailTo, []string{cfg.MailTo}, msg.Bytes())
+}
+
+// mailToSelf sends e to the user's own inbox if mail_to is set.
+func mailToSelf(cfg conf, e historyEntry) error {
+	if cfg.MailTo == "" {
+		return nil
+	}
+	body := fmt.Sprintf("Address: %s\nLabel: %s\nCreated: %s\n",
+		e.Address, orDefault(e.Label, "-"), e.Created.Local().Format("2006-01-02 15:04 MST"))
+	if len(e.Tags) > 0 {
+		body += "Tags: " + strings.Join(e.Tags, ", ") + "\n"
+	}
+	err := sendMail(cfg, "New Duck alias: "+orDefault(e.Label, e.Address), body)
+	audit("mail", err, map[string]string{"address": e.Address, "to": cfg.MailTo})
+	return err
+}
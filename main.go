@@ -3,16 +3,20 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mikkmer/duckduckgone/ddg"
 )
 
 const (
@@ -20,37 +24,124 @@ const (
 	apiKey       = "api"
 	clip         = "clipboard"
 	ddgGen       = "ddggen"
+	keyStore     = "keystore"
+	readOnlyKey  = "readonly"
+	quietKey     = "quiet"
+	clipBackends = "clipboard_backends"
+	selection    = "selection"
 
 	defaultClip   = "yes"
 	defaultDDGGen = "yes"
-	endpoint      = "https://quack.duckduckgo.com/api/email/addresses"
 	version       = "1.0.0"
 )
 
 type conf struct {
-	APIKey        string
-	Clipboard     string
-	DDGGen        string
-	SetupComplete string // Added to track if setup is complete
+	APIKey          string
+	Clipboard       string
+	DDGGen          string
+	Keystore        string // Where the API key lives: "file" (this config) or "keychain"
+	ReadOnly        string // "yes" refuses anything that generates or changes state
+	Quiet           string // "yes" makes -q the default output
+	ClipBackends    string // Ordered fallback list, e.g. ["wl-copy","xclip","osc52"]
+	Selection       string // "clipboard" (default), "primary" or "both"
+	ClipClear       string // Seconds before a generated address is wiped off the clipboard; empty or 0 means never
+	PreGenerateHook string // Shell command that can veto a generation
+	MaxPerDay       string // Self-imposed generation limits; empty or 0 means none
+	MaxPerWeek      string
+	TeamDir         string // Shared directory for team mode
+	TeamMember      string // Our name in the team directory
+	WeeklySummary   string // "notify" or "email" for the daemon's weekly summary
+	MailTo          string // Our own address, for email-to-self
+	SMTPServer      string // host:port; empty means sendmail
+	SMTPUser        string
+	IMAPServer      string // host[:port] the IMAP watcher reads, over TLS
+	IMAPUser        string
+	IMAPMailbox     string            // default INBOX
+	AlertPerDay     string            // Messages a day to one alias that raise an alert; empty or 0 means never
+	Notifications   map[string]string // Sink name to target, from the notifications.* keys
+	Aliases         map[string]string // Command alias to its definition, from the alias.* keys
+	LogTarget       string            // Where the daemon logs: stderr, journald or syslog
+	Egress          string            // "strict" allows only the API and EgressAllow
+	EgressAllow     string            // List of extra hosts, e.g. ["ntfy.sh"]
+	SetupComplete   string            // Added to track if setup is complete
 }
 
-type ddgResp struct {
-	Address string `json:"address"`
+// scriptCommands are meant to be called from scripts and hotkeys, so their
+// output must not start with the banner.
+var scriptCommands = map[string]bool{
+	"client":            true,
+	"sheet":             true, // documents on stdout
+	"export":            true,
+	"exec":              true, // stdout is the command's
+	"curlfill":          true, // a command to save or pipe
+	"audit":             true,
+	"remind":            true,
+	"launcher":          true,
+	"rpc":               true,
+	"mutt-query":        true,
+	"handle-url":        true,
+	"completion":        true,
+	"packaging":         true, // manifests on stdout
+	"__complete":        true,
+	"__clipboard-owner": true,
+	"__clipboard-clear": true,
+	"prompt-segment":    true,
 }
 
+// Global flags, set by parseGlobalFlags.
+var (
+	readOnly     bool
+	outputFlag   string
+	templateFlag string
+	profileFlag  string
+
+	recordFixtures string
+	replayFixtures string
+)
+
 func main() {
-	printBanner()
+	defer recoverCrash()
+	os.Args = append(os.Args[:1], parseGlobalFlags(os.Args[1:])...)
+	if err := setOutput(outputFlag, templateFlag); err != nil {
+		exitErr(err)
+	}
+	if profileFlag == "" {
+		profileFlag = os.Getenv("DDG_PROFILE")
+	}
+	if err := setProfile(profileFlag); err != nil {
+		exitErr(err)
+	}
+	cfg, _ := readConfig() // whatever parses; egress can't wait for repairs
+	configureEgress(cfg)
+	configureTracing()
+	os.Args = append(os.Args[:1], applyAlias(cfg, os.Args[1:])...)
+	if outputFlag == "" && templateFlag == "" && strings.EqualFold(cfg.Quiet, "yes") {
+		_ = setOutput(outputQuiet, "")
+	}
+	if err := useFixtures(recordFixtures, replayFixtures); err != nil {
+		exitErr(err)
+	}
 	cmd := ""
 	if len(os.Args) > 1 {
 		cmd = strings.ToLower(os.Args[1])
 	}
+	if path, ok := findPlugin(cmd); ok {
+		runPlugin(path, cmd, os.Args[2:])
+		return
+	}
+	if !scriptCommands[cmd] && !out.machine() {
+		printBanner()
+	}
 
 	switch {
 	case cmd == "":
-		cfg, err := ensureConfig(true)
+		cfg, err := loadSettings(true)
 		if err != nil {
 			exitErr(err)
 		}
+		if setupTested {
+			return
+		}
 		if strings.EqualFold(cfg.DDGGen, "yes") {
 			doGenerate()
 		} else {
@@ -61,21 +152,212 @@ func main() {
 		doGenerate()
 	case strings.HasPrefix(cmd, "set"):
 		doSettings()
+	case cmd == "provision":
+		doProvision(os.Args[2:])
+	case cmd == "client":
+		doClient(os.Args[2:])
+	case cmd == "serve":
+		doServe(os.Args[2:])
+	case cmd == "history":
+		doHistory(os.Args[2:])
+	case cmd == "list", cmd == "ls":
+		doList(os.Args[2:])
+	case cmd == "search":
+		doSearch(os.Args[2:])
+	case cmd == "show":
+		doShow(os.Args[2:])
+	case cmd == "import":
+		doImport(os.Args[2:])
+	case cmd == "scan-browser":
+		doScanBrowser(os.Args[2:])
+	case cmd == "scan-mail":
+		doScanMail(os.Args[2:])
+	case cmd == "imap":
+		doIMAP(os.Args[2:])
+	case cmd == "noisiest":
+		doNoisiest(os.Args[2:])
+	case cmd == "report":
+		doReport(os.Args[2:])
+	case cmd == "token":
+		doToken(os.Args[2:])
 	case cmd == "reset":
-		doReset()
+		doReset(os.Args[2:])
+	case cmd == "register-url-handler":
+		doRegisterURLHandler(os.Args[2:])
+	case cmd == "handle-url":
+		doHandleURL(os.Args[2:])
+	case cmd == "mutt-query":
+		doMuttQuery(os.Args[2:])
+	case cmd == "rpc":
+		doRPC()
+	case cmd == "launcher":
+		doLauncher(os.Args[2:])
+	case cmd == "remind":
+		doRemind(os.Args[2:])
+	case cmd == "curlfill":
+		doCurlfill(os.Args[2:])
+	case cmd == "exec":
+		doExec(os.Args[2:])
+	case cmd == "export":
+		doExport(os.Args[2:])
+	case cmd == "sheet":
+		doSheet(os.Args[2:])
+	case cmd == "team":
+		doTeam(os.Args[2:])
+	case cmd == "profile":
+		doProfile(os.Args[2:])
+	case cmd == "audit":
+		doAudit(os.Args[2:])
+	case cmd == "completion":
+		doCompletion(os.Args[2:])
+	case cmd == completeCommand:
+		doComplete(os.Args[2:])
+	case cmd == clipboardOwnerCommand:
+		doClipboardOwner(os.Args[2:])
+	case cmd == clipboardClearCommand:
+		doClipboardClear(os.Args[2:])
+	case cmd == "whoami":
+		doWhoami(os.Args[2:])
+	case cmd == "summary":
+		doSummary(os.Args[2:])
+	case cmd == "who":
+		doWho(os.Args[2:])
+	case cmd == "agent":
+		doAgent(os.Args[2:])
+	case cmd == "remote":
+		doRemote(os.Args[2:])
+	case cmd == "pair":
+		doPair(os.Args[2:])
+	case cmd == "install-windows-service":
+		doInstallWindowsService(os.Args[2:])
+	case cmd == "open":
+		doOpen(os.Args[2:])
+	case cmd == "field":
+		doField(os.Args[2:])
+	case cmd == "delete":
+		doDelete(os.Args[2:])
+	case cmd == "config":
+		doConfig(os.Args[2:])
+	case cmd == "restore-trash":
+		doRestoreTrash(os.Args[2:])
+	case cmd == "burn":
+		doBurn(os.Args[2:])
+	case cmd == "undelete":
+		doUndelete(os.Args[2:])
+	case cmd == "insights":
+		doInsights()
+	case cmd == "prompt-segment":
+		doPromptSegment(os.Args[2:])
+	case cmd == "bench":
+		doBench(os.Args[2:])
+	case cmd == "bundle":
+		doBundle(os.Args[2:])
+	case cmd == "packaging":
+		doPackaging(os.Args[2:])
+	case cmd == "doctor":
+		doDoctor()
 	case cmd == "version":
 		showVersion()
 	case cmd == "help":
 		showHelp()
 	default:
+		if jsonErrors() {
+			exitErr(fmt.Errorf("usage: unknown command %s", cmd))
+		}
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", cmd)
 		showHelp()
 	}
 }
 
+// parseGlobalFlags strips flags that apply to every command and returns the
+// remaining arguments. The flags can come before or after the command, but
+// parsing stops at "--", and at the command itself when it hands its
+// arguments on to another program: see passesArgsOn.
+func parseGlobalFlags(args []string) []string {
+	var rest []string
+	command := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(rest, args[i:]...)
+		case arg == "--read-only":
+			readOnly = true
+		case arg == "--yes":
+			assumeYes = true
+		case arg == "--json":
+			outputFlag = outputJSON
+		case arg == "-q" || arg == "--quiet":
+			outputFlag = outputQuiet
+		case (arg == "--output" || arg == "-o") && i+1 < len(args):
+			outputFlag = strings.ToLower(args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--output="):
+			outputFlag = strings.ToLower(strings.TrimPrefix(arg, "--output="))
+		case arg == "--template" && i+1 < len(args):
+			templateFlag = args[i+1]
+			i++
+		case arg == "--profile" && i+1 < len(args):
+			profileFlag = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--profile="):
+			profileFlag = strings.TrimPrefix(arg, "--profile=")
+		case arg == "--record-fixtures" && i+1 < len(args):
+			recordFixtures = args[i+1]
+			i++
+		case arg == "--replay-fixtures" && i+1 < len(args):
+			replayFixtures = args[i+1]
+			i++
+		default:
+			rest = append(rest, arg)
+			if !command && !strings.HasPrefix(arg, "-") {
+				command = true
+				if passesArgsOn(strings.ToLower(arg)) {
+					return append(rest, args[i+1:]...)
+				}
+			}
+		}
+	}
+	return rest
+}
+
+// passesArgsOn reports whether cmd's arguments are really someone else's (a
+// wrapped command line, a form's values, a plugin's own flags) and must get
+// to it exactly as typed: 'ddg exec -- curl -q' means curl's -q, not ddg's.
+func passesArgsOn(cmd string) bool {
+	if cmd == "exec" || cmd == "curlfill" {
+		return true
+	}
+	_, ok := findPlugin(cmd)
+	return ok
+}
+
+// isReadOnly reports whether read-only mode is on, via flag or config.
+func isReadOnly() bool {
+	if readOnly {
+		return true
+	}
+	// A config that doesn't fully parse still counts: readonly = yes on an
+	// intact line must not be lost to a typo elsewhere.
+	cfg, _ := readConfig()
+	return strings.EqualFold(cfg.ReadOnly, "yes")
+}
+
+// requireWritable exits if read-only mode forbids action.
+func requireWritable(action string) {
+	if isReadOnly() {
+		if !writeErrorReport(fmt.Errorf("%w: refusing to %s", errReadOnly, action)) {
+			fmt.Fprintf(os.Stderr, sym("🔒")+" Read-only mode: refusing to %s.\n", action)
+		}
+		os.Exit(1)
+	}
+}
+
 func printBanner() {
-	orange := "\033[38;5;214m"
-	reset := "\033[0m"
+	orange, reset := "\033["+colorOrange+"m", "\033[0m"
+	if !caps.Color {
+		orange, reset = "", ""
+	}
 
 	fmt.Printf(`%s ____             _    ____             _     ____                  
 |  _ \ _   _  ___| | _|  _ \ _   _  ___| | __/ ___| ___  _ __   ___ 
@@ -90,70 +372,734 @@ func showHelp() {
 	fmt.Println(`Usage: ddg <command>
 
 Commands:
-  gen, generate    Generate new Duck email
+  gen, generate    Generate new Duck email (--for <label> or --label <label> to say
+                   what it's for, --note <text> to say why (repeatable),
+                   --field key=value to attach metadata (repeatable),
+                   --override to exceed a budget,
+                   --remind 90d|YYYY-MM-DD to set a review date,
+                   --qr to also show it as a QR code,
+                   --clear-after 30s to wipe it off the clipboard then,
+                   -n <count> for up to 100 at once, one address per line
+                   (journaled: --resume finishes an interrupted run, --restart drops it),
+                   --namespace <name> to tag them ns:<name> for a test run)
   settings         View or change settings
+  config           List config backups or roll back to one (backups, rollback [<n>])
+  token            Show, set, validate, delete or migrate the API key
+  list, ls         Aliases in history, newest first and numbered (--since 7d|YYYY-MM-DD,
+                   --tag <tag>, --namespace <name>, --limit <n>)
+  search <term>... Aliases whose address, label, tags, notes or fields contain every term
+  show <n|address|label>
+                   Everything history has on one alias; n is its number in 'ddg list'
+  history          Clean up (dedupe, purge deleted) or merge alias history
+  import <csv>     Backfill history from a password manager's CSV export: logins
+                   with a @duck.com username, labelled by site
+                   (--format lastpass|bitwarden|1password-csv)
+  scan-browser     Find aliases in Chrome and Firefox saved logins that history lacks,
+                   and offer to add them (--browser-profile <dir>, --yes; reads
+                   usernames only; DDG_FIREFOX_PASSWORD for a primary password)
+  scan-mail <maildir|mbox>
+                   Which aliases get mail and from whom, read from message headers;
+                   notes last_seen, mail_count and top_senders on history entries
+                   (--dry-run to only report)
+  imap watch       Count mail per alias as it arrives in the imap_server mailbox
+                   (--once, --every 2m); 'imap login' keeps the password in the keychain
+  noisiest         Aliases that got the most mail lately, to decide what to burn
+                   (--days 30, --top 10; counted by 'imap watch' or the daemon)
+  report leaks     Aliases getting mail from senders unrelated to their label, i.e.
+                   who sold or leaked the address (from scan-mail and imap watch; --top n)
+  provision <csv>  Generate one labelled alias per CSV row
+  serve            Run a local HTTP daemon that hands out aliases
+  client           Ask a running daemon for an alias (fast path for hotkeys)
+  remote <host> gen
+                   Generate on another machine over SSH and copy the alias here
+                   (--ddg <path> if ddg isn't on the remote PATH)
+  agent ssh <host> Log in to host with this machine's daemon forwarded, so ddg
+                   there generates here without a token (DDG_AGENT_SOCK)
+  pair             Show a QR code that gives a phone its own daemon key
+  register-url-handler
+                   Open ddg://gen?label=example.com links with ddg (--remove to undo)
+  mutt-query <q>   Complete aliases in mutt/neomutt (query_command) or aerc (--no-header)
+  rpc              JSON-RPC 2.0 on stdio for editor extensions (generate, search)
+  launcher         Feed Alfred, Raycast, rofi or Walker (--protocol <name> [query])
+  remind           Alias review dates: list, set, export as .ics, push to Reminders
+  sheet            Printable table of labels and aliases (--tag, --format md|html)
+  export --format csv|bitwarden|1password
+                   History as a CSV file a password manager imports (--tag, --out)
+  team             Share who generated which alias with a team (tokens stay personal)
+  profile          List, export or import profiles
+  audit            Export the audit log, optionally signed, and verify exports
+  who <address>    Show what an alias was generated for
+  whoami           Show your Duck address and where it forwards (cached 10 minutes;
+                   --refresh to ask now)
+  summary          This week's aliases generated, burned and due for review
+  field <address> key=value...
+                   Set custom fields on an alias (key= removes one)
+  delete <address> Hide an alias from history (undelete restores it, --purge
+                   removes it for good)
+  exec [--for <label>] -- <command> [args...]
+                   Generate an alias and run a command with it in place of {{DUCK}}
+                   and in $DDG_EMAIL (--note, --field as for gen)
+  curlfill <url>   Fetch a signup form, fill in a new alias and print it as a curl
+                   command (--field <name> picks the field, --set name=value fills
+                   others, --submit sends it once confirmed)
+  burn <n|address|label>
+                   Mark an alias burned (--reason <text>); --open copies it and
+                   opens the dashboard to deactivate it there;
+                   --namespace <name> burns every alias in a namespace at once
+  reset            Reset everything, or just --settings, --token or --history
+  restore-trash [<id>]
+                   List what reset and purges discarded (kept 30 days), or put it back
+  open <label>     Copy the alias for a site and open its login page (--signup)
+  completion spec  Completion spec for Fig/Warp or carapace (--format fig|carapace)
+  insights         Local stats on your aliases (nothing leaves the machine)
+  prompt-segment   Profile, today's alias count and key status for starship or
+                   powerlevel10k (local files only; exits 1 if the key was rejected)
+  bench            Time config, keystore, DNS, TLS and the API separately
+                   (--n <rounds>, --dry-run-network to not generate aliases)
+  bundle           Offline install .tar.gz: this binary, man page, completion specs,
+                   service unit and SHA256SUMS (--out <file>, --prefix /usr/local)
+  packaging manifest
+                   Homebrew formula, Scoop manifest or AUR PKGBUILD for this version
+                   (--type homebrew|scoop|aur, --sha256 <hex> or --file <artifact>)
+  doctor           Show detected clipboard backend and other diagnostics
   help             Show this help
 
+Global flags:
+  --read-only      Only allow commands that read local state (also: readonly = yes)
+  --yes            Answer yes to confirmations before destructive actions. Without
+                   a terminal they fail unless answered this way. DDG_ASSUME_YES=1
+                   answers all but a full reset, which needs --yes itself
+  -q, --quiet      Print only the bare value, e.g. the address: EMAIL=$(ddg -q gen).
+                   No banner, colors or "(copied to clipboard)" line; warnings still
+                   go to stderr (also: quiet = yes)
+  -o, --output <plain|color|json|jsonl|template|vim|quiet>
+                   Output format for every command (default: color on a terminal).
+                   vim prints just the value with no trailing newline, for editors:
+                   inoremap <C-g>d <C-r>=system('ddg -o vim gen 2>/dev/null')<CR>
+                   In json and jsonl, a failing command writes one line to stderr:
+                   {"error": {"code": ..., "message": ..., "status": ..., "retry_after": ...}}
+                   with code one of unauthorized, rate_limited, api_error, network,
+                   over_budget, hook_veto, cancelled, read_only, usage, config_error, error.
+  --json           Same as -o json: one JSON object (or array) on stdout, no banner
+                   or colors, e.g. ddg gen --json --for example.com | jq -r .address
+  --template <tmpl>
+                   Go template applied to each result, e.g. '{{.Address}}'
+  --profile <name> Use a separate profile (also: DDG_PROFILE). Each profile has its
+                   own config, API key, history, audit log and daemon
+
+Plugins:
+  Any executable named ddg-<name> on PATH runs as 'ddg <name>'. It gets a
+  JSON object on stdin with the config, history and audit paths, the daemon
+  socket and address, and the active output format.
+
+Development flags:
+  --record-fixtures <dir>  Save every DuckDuckGo API exchange to <dir> (token scrubbed)
+  --replay-fixtures <dir>  Answer API calls from fixtures in <dir>, offline
+
+Tracing:
+  Set OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) and
+  ddg sends a span for each API call and each history, audit log and state
+  read or write to that collector over OTLP/HTTP with JSON, as service
+  OTEL_SERVICE_NAME (default ddg). Under egress = strict, list its host.
+
+Managing the API key:
+  ddg token show [--reveal]            Show the stored key (masked by default)
+  ddg token set [<key>]                Store a new key (prompts if omitted)
+  ddg token validate                   Check the key against DuckDuckGo
+  ddg token delete                     Remove the stored key
+  ddg token migrate --to <file|keychain>
+                                       Move the key to another keystore
+
+Alias history:
+  ddg history dedupe                   Collapse duplicate entries for the same address
+  ddg history merge <file>             Union another machine's history into this one
+  ddg history purge                    Remove deleted aliases for good (the old file
+                                       stays in the trash for 30 days)
+
+Links:
+  After 'ddg register-url-handler', a ddg://gen?label=<label>&tags=<a,b> link (from
+  a bookmarklet, a note or another app) generates an alias, copies it and shows a
+  notification. Any page can link to ddg://, so budgets (max_per_day) still apply.
+
+Editor backend:
+  ddg rpc speaks JSON-RPC 2.0 on stdin/stdout, one message per line or framed
+  with Content-Length headers like LSP. Methods: initialize -> {version, schema},
+  generate {label?, tags?} -> {address, label, created},
+  search {query, limit?} -> [{address, label, tags, created}], shutdown, exit.
+  Generation errors carry data.status with the HTTP status (401, 429, ...).
+
+Completion:
+  ddg completion spec --format fig > ~/.fig/autocomplete/src/ddg.ts
+  ddg completion spec --format carapace > ~/.config/carapace/specs/ddg.yaml
+  The specs call back into 'ddg __complete labels|aliases|deleted [<prefix>]', so
+  'ddg gen --for <TAB>' offers labels from history and 'ddg who <TAB>' your aliases.
+
+Launchers:
+  ddg launcher --protocol alfred|raycast|rofi|walker [<query>]
+  Lists "generate new" plus matching history in the launcher's format; picking a
+  row runs 'ddg launcher --run <arg>', which generates (labelled with the query)
+  or copies the alias. For rofi: rofi -modi 'ddg:ddg launcher --protocol rofi' -show ddg
+
+Review reminders:
+  ddg remind list                      Aliases with a review date, soonest first
+  ddg remind set <address> <when>      when: YYYY-MM-DD, 30d, 6w, 3m, 1y or none
+  ddg remind export [--out <file.ics>] iCalendar file for any calendar app
+  ddg remind push                      Add them to the macOS Reminders app
+
+Contact sheet:
+  ddg sheet [--tag vendors] [--format md|html] [--out <file>]
+                                       Label, alias, created date, tags and notes of
+                                       every alias (with that tag), for printing
+
+Team mode:
+  ddg team join <dir> [--as <name>] [--publish]
+                                       Share through <dir>, a folder every member syncs;
+                                       --publish also shares your existing history
+  ddg team history [<search>]          Everyone's aliases: who, when, for what
+  ddg team status|leave
+  Each member only ever writes <dir>/<name>.jsonl. Notes and tokens are never shared.
+
+Profiles:
+  ddg profile list                     Show profiles; * marks the active one
+  ddg profile export [<name>] [--with-secrets] [--out <file>]
+                                       Pack a profile into a .tar.gz; the API key and
+                                       signing keys are left out unless asked for
+  ddg profile import <file> [--as <name>] [--force]
+                                       Unpack an export as a (new) profile
+
+Audit log:
+  ddg audit export [--out <file>]      Write the audit log to stdout or a file
+  ddg audit export --sign --out <file> Also write <file>.sig, an HMAC-SHA256 keyed
+                                       from the keychain (or ~/.ddg/audit.key)
+  ddg audit verify <file> [--sig <file.sig>]
+                                       Check an export hasn't been altered since
+
+Bulk provisioning:
+  ddg provision sites.csv [--delay 2s] [--label-template "{{.Label}} signup"]
+  Each row is: label,tags,notes (tags separated by ';'). Progress is journaled,
+  so after an interruption rerun with --resume to skip labels already done
+  (or --restart to discard the journal and start over). --parallel <n> runs n
+  requests at a time (each worker still waits --delay between its own); the
+  first error, such as a rejected key, stops all of them.
+
+Local daemon:
+  ddg serve [--addr 127.0.0.1:8765]    POST /generate[?label=...] returns {"address": ...}
+  ddg serve --addr 0.0.0.0:8765 --advertise
+                                       Also announce the daemon via Bonjour/mDNS as
+                                       _ddg._tcp, so phones on the LAN can find it
+  ddg serve --no-sandbox               On Linux the daemon (and the hooks it runs) gets no
+                                       new privileges or capabilities and, with Landlock,
+                                       can only write to ~/.ddg, the team dir and the temp
+                                       dir; this turns that off for hooks that need more
+  ddg serve users add <name>           Add a user (their DuckDuckGo key is stored encrypted)
+  ddg serve users list|remove <name>
+  ddg serve keys add <name> --scope generate|history|events[,...] [--user <name>]
+                                       Issue a scoped key (GET /history needs "history")
+  ddg serve keys list|remove <name>
+  ddg serve bookmarklet                Print a bookmarklet that fills the focused field
+                                       with an alias labelled with the page's site
+  ddg pair [--user <name>] [--addr <ip:port>]
+                                       Show a one-time URL as a QR code; a phone (e.g. an
+                                       iOS Shortcut) POSTs to it within 10 minutes and gets
+                                       a generate key for that user back
+  ddg install-windows-service [--addr <addr>] [--user <account>] [--remove]
+                                       Run the daemon as a Windows service that starts at
+                                       boot and restarts on failure (elevated prompt; asks
+                                       for your password, or DDG_SERVICE_PASSWORD)
+  ddg client gen [--label <label>]     Generate via the daemon (prints only the address)
+  ddg client list|status               Show the daemon's history / health
+  Identical generate requests that arrive together (a mashed hotkey) share
+  one alias, marked "shared": true.
+  GET /account returns the key's DuckDuckGo account (needs "history"), cached
+  like 'ddg whoami'.
+  With weekly_summary set, the daemon sends 'ddg summary' once a week.
+  With imap_server set, it also counts alias mail like 'ddg imap watch'.
+  Sinks set with 'ddg settings --notification' (ntfy, Pushover, Matrix, a webhook) hear
+  about every alias the daemon sees, so a headless server still tells you.
+  GET /stats on the daemon reports request counts and p50/p95 latencies.
+  GET /openapi.json describes every endpoint, for generating clients.
+  GET /events streams server-sent events ("generate") for every new alias,
+  including ones made with 'ddg gen' elsewhere; needs the "events" scope. Browsers'
+  EventSource can pass the key as ?access_token=<key>.
+  On a machine that shouldn't hold a token, set DDG_AGENT_SOCK to a daemon
+  socket forwarded over SSH ('ddg agent ssh <host>' does it for you) and
+  'ddg gen' and 'ddg client' go through it.
+  The client finds the daemon through its Unix socket or port automatically;
+  pass a key with --token or DDG_CLIENT_TOKEN.
+  With no users or keys the daemon serves the local key to loopback clients
+  only; otherwise every request needs "Authorization: Bearer <token>".
+
 Changing settings:
 To change settings, use the following flags:
 
   --apikey <key>          Set the API key
   --clipboard <yes|no>    Enable or disable clipboard copying
   --ddggen <yes|no>       Enable or disable automatic DuckDuckGo generation
+  --clipboard-backends <a,b,...>
+                          Clipboard backends to try in order (pbcopy, wl-copy,
+                          xclip, xsel, clip.exe, powershell, osc52, native);
+                          empty means auto-detect, preferring osc52 over SSH.
+                          native needs no tools: it talks to X11 or Windows
+                          itself, and is only used when listed here
+  --selection <clipboard|primary|both>
+                          Also (or instead) set the X11/Wayland primary selection
+                          so middle-click pastes the address
+  --clipboard-clear-seconds <n>
+                          Wipe a generated address off the clipboard after n
+                          seconds (or 30s, 2m), unless something else was copied
+                          since; 0 never does (the default)
+  --quiet-mode <yes|no>   Make -q the default output (an explicit -o still wins)
+  --readonly <yes|no>     Enable read-only mode (must be disabled by editing the config)
+  --pre-generate-hook <cmd>
+                          Shell command run before every generation with the label
+                          as $1 (and in DDG_LABEL); a non-zero exit refuses it.
+                          Pass "" to remove it
+  --max-per-day <n>       Refuse to generate more than n aliases a day (0: no limit)
+  --max-per-week <n>      Same per week, Monday to Sunday. 'ddg gen --override'
+                          goes over the limit once
+  --weekly-summary <notify|email|no>
+                          Have 'ddg serve' send 'ddg summary' once a week, as a
+                          notification or to --mail-to
+  --mail-to <address>     Also mail every generated alias to this address, an
+                          off-machine record. Pass "" to stop
+  --smtp-server <host:port>
+                          Send through this server (STARTTLS) instead of sendmail
+  --smtp-user <name>      Log in to it as name; the password is read from
+                          DDG_SMTP_PASSWORD
+  --imap-server <host[:port]>
+                          Mailbox 'ddg imap watch' and the daemon count alias mail
+                          in, over TLS (port 993 by default)
+  --imap-user <name>      Log in to it as name; 'ddg imap login' keeps the password
+                          in the keychain, or set DDG_IMAP_PASSWORD
+  --imap-mailbox <name>   Folder to watch (default INBOX)
+  --alert-per-day <n>     Alert (notification and every --notification sink) when
+                          one alias gets n messages in a day: it has probably
+                          leaked to spam lists (0: never)
+  --notification <sink>=<target>
+                          Have 'ddg serve' push its events to a sink (repeatable;
+                          an empty target removes it):
+                            ntfy=https://ntfy.sh/<topic> (or https://<token>@host/<topic>)
+                            pushover=<user key>:<application token>
+                            matrix=https://<access token>@<homeserver>/<room id>
+                            webhook=https://example.com/hook (POSTs JSON title,
+                            message and time)
+  --alias <name>=<command line>
+                          Make 'ddg <name>' run that command line, with anything
+                          after it appended, like a git alias (repeatable; an
+                          empty command line removes it):
+                            --alias 'am=gen --label "amazon signup" --json'
+                          Built-in commands can't be aliased
+  --egress <open|strict>  strict makes every connection ddg opens fail unless it
+                          goes to the API or an --egress-allow host; blocked ones
+                          are audited
+  --egress-allow <a,b,...>
+                          Hosts strict mode also allows (sinks, SMTP, a proxy)
+  --log-target <stderr|journald|syslog>
+                          Where 'ddg serve' logs, with priorities, when it runs
+                          as a service
 
 For example: 
 	ddg settings --apikey myapikey --clipboard yes --ddggen no
 	ddg settings --clipboard no
 
+Prompts never block when stdin is not a terminal. Answer the setup wizard with
+DDG_API_KEY, DDG_CLIPBOARD, DDG_DDGGEN and DDG_SETUP_TEST instead.
+
+If ddg crashes it saves a report to ~/.ddg/crashes with your API key and
+aliases taken out (DDG_CRASH_ALIASES=1 keeps aliases) for you to attach to an
+issue.
+
 Examples:
   ddg gen
   ddg settings`)
+	if names := listPlugins(); len(names) > 0 {
+		fmt.Println("\nInstalled plugins: " + strings.Join(names, ", "))
+	}
 }
 
 func doGenerate() {
+	var e historyEntry
+	showQR, count, clear := false, 1, ""
+	resume, restart := false, false
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--override" {
+			overrideBudget = true
+		} else if args[i] == "--resume" {
+			resume = true
+		} else if args[i] == "--restart" {
+			restart = true
+		} else if (args[i] == "-n" || args[i] == "--count") && i+1 < len(args) {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 || n > maxBatch {
+				exitErr(fmt.Errorf("%s wants a number from 1 to %d; 'ddg provision' takes a CSV for more", args[i], maxBatch))
+			}
+			count = n
+			i++
+		} else if args[i] == "--qr" {
+			showQR = true
+		} else if args[i] == "--namespace" && i+1 < len(args) {
+			tag, err := namespaceTag(args[i+1])
+			if err != nil {
+				exitErr(err)
+			}
+			e.Tags = unionTags(e.Tags, []string{tag})
+			i++
+		} else if args[i] == "--clear-after" && i+1 < len(args) {
+			if _, err := parseClearAfter(args[i+1]); err != nil {
+				exitErr(fmt.Errorf("--clear-after: %w", err))
+			}
+			clear = args[i+1]
+			i++
+		} else if (args[i] == "--for" || args[i] == "--label") && i+1 < len(args) {
+			e.Label = args[i+1]
+			i++
+		} else if args[i] == "--note" && i+1 < len(args) {
+			e.Notes = joinNotes(e.Notes, strings.TrimSpace(args[i+1]))
+			i++
+		} else if args[i] == "--field" && i+1 < len(args) {
+			k, v, err := parseField(args[i+1])
+			if err != nil {
+				exitErr(err)
+			}
+			if e.Fields == nil {
+				e.Fields = map[string]string{}
+			}
+			if v != "" {
+				e.Fields[k] = v
+			}
+			i++
+		} else if args[i] == "--remind" && i+1 < len(args) {
+			t, err := parseWhen(args[i+1])
+			if err != nil {
+				exitErr(fmt.Errorf("--remind: %w", err))
+			}
+			e.Review = &t
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if count > 1 && (showQR || os.Getenv(agentSockEnv) != "") {
+		exitErr(fmt.Errorf("usage: -n can't be combined with --qr or %s", agentSockEnv))
+	}
+	if count == 1 && (resume || restart) {
+		exitErr(fmt.Errorf("usage: --resume and --restart go with -n <count>"))
+	}
+	if os.Getenv(agentSockEnv) != "" {
+		genViaAgent(e, showQR, clear)
+		return
+	}
+	requireWritable("generate an alias")
 	cfg, err := ensureConfig(false)
 	if err != nil {
 		exitErr(err)
 	}
-	email, _, err := requestEmail(cfg.APIKey)
+	if count > 1 {
+		genBatch(cfg, e, count, resume, restart)
+		return
+	}
+	entry, err := generateAlias(cfg, e)
 	if err != nil {
-		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
-			fmt.Fprintf(os.Stderr, "\033[31mError! Invalid token\033[0m\n")
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 && !jsonErrors() {
+			fmt.Fprintln(os.Stderr, red("Error! Invalid token"))
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exitErr(err)
 	}
-	fmt.Printf("\033[36m%s\033[0m\n", email)
+	res := genResult{Address: entry.Address, Label: entry.Label, Created: entry.Created, Tags: entry.Tags,
+		Notes: entry.Notes, Fields: entry.Fields, Review: entry.Review, Duplicate: entry.Duplicate}
+	human := cyan(hyperlink("mailto:"+res.Address, res.Address))
 	if strings.EqualFold(cfg.Clipboard, "yes") {
-		if err := copyToClipboard(email); err == nil {
-			fmt.Println("(copied to clipboard)")
+		human += copyGenerated(cfg, &res, clearAfter(cfg, clear))
+	}
+	out.Result(res, human)
+	if showQR {
+		// On stderr, so piping the address somewhere stays clean.
+		if err := printQR(os.Stderr, "mailto:"+res.Address); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 	}
 }
 
+// genResult is what gen prints: with --json, the one object on stdout.
+type genResult struct {
+	Address   string            `json:"address"`
+	Label     string            `json:"label,omitempty"`
+	Created   time.Time         `json:"created"`
+	Tags      []string          `json:"tags,omitempty"`
+	Notes     string            `json:"notes,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Review    *time.Time        `json:"review,omitempty"`
+	Clipboard string            `json:"clipboard,omitempty"`
+	// Seconds until the clipboard is wiped, if it will be.
+	ClearAfter int  `json:"clipboard_clear_seconds,omitempty"`
+	Duplicate  bool `json:"duplicate,omitempty"`
+}
+
+func (r genResult) plain() string { return r.Address }
+
+// generateAlias requests a new address and records it in history along with
+// whatever label, tags and notes e already carries.
+func generateAlias(cfg conf, e historyEntry) (historyEntry, error) {
+	return generateAliasContext(context.Background(), cfg, e)
+}
+
+// generateAliasContext is generateAlias with a context that can abandon the
+// request to DuckDuckGo.
+func generateAliasContext(ctx context.Context, cfg conf, e historyEntry) (historyEntry, error) {
+	email, err := generateCounted(ctx, cfg, e)
+	if err != nil {
+		return e, err
+	}
+	e.Address = email
+	e.Created = time.Now()
+
+	// DuckDuckGo should never hand out the same address twice. If it does
+	// (or history was imported from somewhere that already had it), keep
+	// the original record untouched and say so loudly.
+	existing, _ := readHistory()
+	if orig, ok := findHistory(existing, email); ok {
+		e.Duplicate = true
+		audit("generate.duplicate", nil, map[string]string{"address": email, "label": e.Label, "original_label": orig.Label})
+		fmt.Fprintf(os.Stderr, "%s %s is already in your history (created %s, label %q); keeping the original record.\n",
+			sym("⚠️"), email, orig.Created.Local().Format("2006-01-02 15:04"), orig.Label)
+		return e, nil
+	}
+	if err := appendHistory(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save to history: %v\n", err)
+	}
+	if err := publishToTeam(cfg, e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not share with the team: %v\n", err)
+	}
+	if err := mailToSelf(cfg, e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not mail the alias to yourself: %v\n", err)
+	}
+	return e, nil
+}
+
+// generateCounted asks DuckDuckGo for an address once the budget and the
+// pre-generate hook allow it, and records the outcome in the audit log,
+// which is what budgets count. With a budget set, all of that happens under
+// lockBudget.
+func generateCounted(ctx context.Context, cfg conf, e historyEntry) (string, error) {
+	defer lockBudget(cfg)()
+	if err := checkBudget(cfg); err != nil {
+		audit("generate", err, map[string]string{"label": e.Label})
+		return "", err
+	}
+	if err := runPreGenerateHook(cfg, e, "local"); err != nil {
+		audit("generate", err, map[string]string{"label": e.Label})
+		return "", err
+	}
+	email, err := apiFor(cfg.APIKey).Generate(ctx)
+	noteTokenStatus(err)
+	if err != nil {
+		audit("generate", err, map[string]string{"label": e.Label})
+		return "", err
+	}
+	audit("generate", nil, map[string]string{"address": email, "label": e.Label})
+	return email, nil
+}
+
 func doSettings() {
 	// Update settings with flags
 	if len(os.Args) > 2 {
-		cfg, err := ensureConfig(false)
+		requireWritable("change settings")
+		cfg, err := loadSettings(false)
 		if err != nil {
 			exitErr(err)
 		}
+		changed := map[string]string{}
 		for i := 2; i < len(os.Args); i++ {
 			arg := os.Args[i]
+			// The config file has no quoting, and '#' starts a comment
+			// wherever it is: a hook or webhook URL with one would be cut.
+			if strings.HasPrefix(arg, "--") && i+1 < len(os.Args) && strings.ContainsRune(os.Args[i+1], '#') {
+				exitErr(fmt.Errorf("%s: '#' starts a comment in the config file, so settings can't contain it", arg))
+			}
 			if arg == "--apikey" && i+1 < len(os.Args) {
 				cfg.APIKey = os.Args[i+1]
+				changed[apiKey] = "(changed)"
 				i++
 			} else if arg == "--clipboard" && i+1 < len(os.Args) {
 				val := strings.ToLower(os.Args[i+1])
 				if val == "yes" || val == "no" {
 					cfg.Clipboard = val
+					changed[clip] = val
 				}
 				i++
 			} else if arg == "--ddggen" && i+1 < len(os.Args) {
 				val := strings.ToLower(os.Args[i+1])
 				if val == "yes" || val == "no" {
 					cfg.DDGGen = val
+					changed[ddgGen] = val
+				}
+				i++
+			} else if arg == "--clipboard-backends" && i+1 < len(os.Args) {
+				names := splitList(os.Args[i+1])
+				for _, n := range names {
+					if _, ok := clipboardBackendByName(n); !ok {
+						exitErr(fmt.Errorf("unknown clipboard backend %q", n))
+					}
+				}
+				cfg.ClipBackends = formatList(names)
+				changed[clipBackends] = cfg.ClipBackends
+				i++
+			} else if arg == "--selection" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == selClipboard || val == selPrimary || val == selBoth {
+					cfg.Selection = val
+					changed[selection] = val
+				}
+				i++
+			} else if arg == "--clipboard-clear-seconds" && i+1 < len(os.Args) {
+				d, err := parseClearAfter(os.Args[i+1])
+				if err != nil {
+					exitErr(fmt.Errorf("%s: %w", arg, err))
+				}
+				val := strconv.Itoa(int(d / time.Second))
+				if val == "0" {
+					val = ""
+				}
+				cfg.ClipClear = val
+				changed[clipClearKey] = val
+				i++
+			} else if arg == "--pre-generate-hook" && i+1 < len(os.Args) {
+				cfg.PreGenerateHook = strings.TrimSpace(os.Args[i+1])
+				changed[preGenerateHook] = cfg.PreGenerateHook
+				i++
+			} else if (arg == "--max-per-day" || arg == "--max-per-week") && i+1 < len(os.Args) {
+				val := strings.TrimSpace(os.Args[i+1])
+				if n, err := strconv.Atoi(val); err != nil || n < 0 {
+					exitErr(fmt.Errorf("%s needs a number (0 for no limit)", arg))
+				}
+				if val == "0" {
+					val = ""
+				}
+				if arg == "--max-per-day" {
+					cfg.MaxPerDay = val
+					changed[maxPerDay] = val
+				} else {
+					cfg.MaxPerWeek = val
+					changed[maxPerWeek] = val
+				}
+				i++
+			} else if arg == "--weekly-summary" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == summaryNotify || val == summaryEmail || val == "no" {
+					cfg.WeeklySummary = val
+					changed[weeklySummaryKey] = val
+				}
+				i++
+			} else if arg == "--mail-to" && i+1 < len(os.Args) {
+				cfg.MailTo = strings.TrimSpace(os.Args[i+1])
+				changed[mailToKey] = cfg.MailTo
+				i++
+			} else if arg == "--smtp-server" && i+1 < len(os.Args) {
+				cfg.SMTPServer = strings.TrimSpace(os.Args[i+1])
+				if _, _, err := net.SplitHostPort(cfg.SMTPServer); cfg.SMTPServer != "" && err != nil {
+					exitErr(fmt.Errorf("--smtp-server wants host:port, got %q", cfg.SMTPServer))
+				}
+				changed[smtpServerKey] = cfg.SMTPServer
+				i++
+			} else if arg == "--smtp-user" && i+1 < len(os.Args) {
+				cfg.SMTPUser = strings.TrimSpace(os.Args[i+1])
+				changed[smtpUserKey] = cfg.SMTPUser
+				i++
+			} else if arg == "--imap-server" && i+1 < len(os.Args) {
+				cfg.IMAPServer = strings.TrimSpace(os.Args[i+1])
+				changed[imapServerKey] = cfg.IMAPServer
+				i++
+			} else if arg == "--imap-user" && i+1 < len(os.Args) {
+				cfg.IMAPUser = strings.TrimSpace(os.Args[i+1])
+				changed[imapUserKey] = cfg.IMAPUser
+				i++
+			} else if arg == "--imap-mailbox" && i+1 < len(os.Args) {
+				cfg.IMAPMailbox = strings.TrimSpace(os.Args[i+1])
+				changed[imapMailboxKey] = cfg.IMAPMailbox
+				i++
+			} else if arg == "--alert-per-day" && i+1 < len(os.Args) {
+				val := strings.TrimSpace(os.Args[i+1])
+				if n, err := strconv.Atoi(val); err != nil || n < 0 {
+					exitErr(fmt.Errorf("%s needs a number (0 for no alerts)", arg))
+				}
+				if val == "0" {
+					val = ""
+				}
+				cfg.AlertPerDay = val
+				changed[alertPerDayKey] = val
+				i++
+			} else if arg == "--notification" && i+1 < len(os.Args) {
+				name, target, _ := strings.Cut(os.Args[i+1], "=")
+				name = strings.ToLower(strings.TrimSpace(name))
+				target = strings.TrimSpace(target)
+				if target == "" {
+					delete(cfg.Notifications, name)
+				} else if kind, ok := sinkKinds[name]; !ok {
+					exitErr(fmt.Errorf("--notification: unknown sink %q (use %s)", name, strings.Join(sinkNames(), ", ")))
+				} else if _, err := kind(target); err != nil {
+					exitErr(fmt.Errorf("--notification %s: %w", name, err))
+				} else {
+					if cfg.Notifications == nil {
+						cfg.Notifications = map[string]string{}
+					}
+					cfg.Notifications[name] = target
+				}
+				changed[notificationPrefix+name] = "(changed)"
+				i++
+			} else if arg == "--alias" && i+1 < len(os.Args) {
+				name, def, _ := strings.Cut(os.Args[i+1], "=")
+				name = strings.ToLower(strings.TrimSpace(name))
+				def = strings.TrimSpace(def)
+				if name == "" || strings.ContainsAny(name, " \t=#") {
+					exitErr(fmt.Errorf("--alias needs <name>=<command line>"))
+				}
+				if isBuiltinCommand(name) {
+					exitErr(fmt.Errorf("--alias: %q is a built-in command", name))
+				}
+				if def == "" {
+					delete(cfg.Aliases, name)
+				} else if _, err := splitAlias(def); err != nil {
+					exitErr(fmt.Errorf("--alias %s: %w", name, err))
+				} else {
+					if cfg.Aliases == nil {
+						cfg.Aliases = map[string]string{}
+					}
+					cfg.Aliases[name] = def
+				}
+				changed[aliasPrefix+name] = def
+				i++
+			} else if arg == "--log-target" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == logStderr || val == logJournald || val == logSyslog {
+					cfg.LogTarget = val
+					changed[logTargetKey] = val
+				}
+				i++
+			} else if arg == "--egress" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == egressOpen || val == egressStrict {
+					cfg.Egress = val
+					changed[egressKey] = val
+				}
+				i++
+			} else if arg == "--egress-allow" && i+1 < len(os.Args) {
+				cfg.EgressAllow = formatList(splitList(strings.ToLower(os.Args[i+1])))
+				changed[egressAllowKey] = cfg.EgressAllow
+				i++
+			} else if arg == "--quiet-mode" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == "yes" || val == "no" {
+					cfg.Quiet = val
+					changed[quietKey] = val
+				}
+				i++
+			} else if arg == "--readonly" && i+1 < len(os.Args) {
+				val := strings.ToLower(os.Args[i+1])
+				if val == "yes" || val == "no" {
+					cfg.ReadOnly = val
+					changed[readOnlyKey] = val
 				}
 				i++
 			} else {
@@ -161,15 +1107,22 @@ func doSettings() {
 				break
 			}
 		}
-		if err := writeConfig(cfg); err != nil {
+		// saveToken also pushes a changed --apikey into an external keystore.
+		if _, ok := changed[apiKey]; ok {
+			err = saveToken(&cfg, cfg.APIKey)
+		} else {
+			err = writeConfig(cfg)
+		}
+		audit("settings", err, changed)
+		if err != nil {
 			exitErr(err)
 		}
-		fmt.Println("✅ Settings updated.")
+		out.Done("Settings updated.", changed)
 		return
 	}
 
 	// Show current settings
-	cfg, err := ensureConfig(false)
+	cfg, err := loadSettings(false)
 	if err != nil {
 		exitErr(err)
 	}
@@ -180,41 +1133,112 @@ func doSettings() {
 		cfg.DDGGen = defaultDDGGen
 	}
 
-	fmt.Printf(
-		"Current settings:\n- API key: %s\n- Clipboard copy: %s\n- Run ddg auto-generate: %s\n\nUse 'ddg help' to learn how to change these.\n",
-		emptyToDash(cfg.APIKey),
-		cfg.Clipboard,
-		cfg.DDGGen,
-	)
-}
-
-func doReset() {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Print("⚠️ Are you sure you want to completely reset this application? (yes/no): ")
-	answer := strings.ToLower(strings.TrimSpace(readLine(reader)))
-	if answer != "yes" {
-		fmt.Println("❌ Reset cancelled.")
-		return
+	backends := strings.Join(splitList(cfg.ClipBackends), ", ")
+	if backends == "" {
+		backends = "auto"
 	}
 
-	fmt.Print("Type 'Reset' to reset the application. This is your final chance to go back: ")
-	confirm := strings.TrimSpace(readLine(reader))
-	if confirm != "Reset" {
-		fmt.Println("❌ Reset cancelled.")
-		return
+	view := settingsView{
+		APIKey:            cfg.APIKey,
+		Keystore:          keystoreName(cfg),
+		Clipboard:         cfg.Clipboard,
+		ClipboardBackends: append([]string{}, splitList(cfg.ClipBackends)...),
+		Selection:         orDefault(cfg.Selection, selClipboard),
+		ClipboardClear:    orDefault(cfg.ClipClear, "never"),
+		DDGGen:            cfg.DDGGen,
+		ReadOnly:          yesNo(isReadOnly()),
+		Quiet:             orDefault(cfg.Quiet, "no"),
+		PreGenerateHook:   cfg.PreGenerateHook,
+		MaxPerDay:         orDefault(cfg.MaxPerDay, "none"),
+		MaxPerWeek:        orDefault(cfg.MaxPerWeek, "none"),
+		WeeklySummary:     orDefault(cfg.WeeklySummary, "no"),
+		MailTo:            cfg.MailTo,
+		SMTPServer:        orDefault(cfg.SMTPServer, "sendmail"),
+		IMAPServer:        cfg.IMAPServer,
+		IMAPUser:          cfg.IMAPUser,
+		IMAPMailbox:       imapMailbox(cfg),
+		AlertPerDay:       orDefault(cfg.AlertPerDay, "none"),
+		Notifications:     sortedKeys(cfg.Notifications),
+		Aliases:           cfg.Aliases,
+		LogTarget:         orDefault(cfg.LogTarget, logStderr),
+		Egress:            orDefault(cfg.Egress, egressOpen),
+		EgressAllow:       egressHosts(cfg),
 	}
-
-	// Overwrite config with setupComplete = false
-	cfg := conf{SetupComplete: "false"}
-	if err := writeConfig(cfg); err != nil {
-		exitErr(err)
+	// An external keystore isn't unlocked just to show settings.
+	shownKey := emptyToDash(view.APIKey)
+	if view.APIKey == "" && view.Keystore != keystoreFile {
+		shownKey = "(in " + view.Keystore + "; 'ddg token show' reads it)"
 	}
-	fmt.Println("✅ Application reset. Run 'ddg' again to set up.")
+	out.Result(view, fmt.Sprintf(
+		"Current settings:\n- API key: %s\n- Keystore: %s\n- Clipboard copy: %s\n- Clipboard backends: %s\n- Selection: %s\n- Clipboard cleared after: %s\n- Run ddg auto-generate: %s\n- Read-only: %s\n- Quiet by default: %s\n- Pre-generate hook: %s\n- Budget: %s per day, %s per week\n- Weekly summary: %s\n- Email-to-self: %s (via %s)\n- IMAP watcher: %s (alert at %s messages a day)\n- Notifications: %s\n- Daemon log: %s\n- Egress: %s\n- Aliases: %s\n\nUse 'ddg help' to learn how to change these.",
+		shownKey,
+		view.Keystore,
+		view.Clipboard,
+		backends,
+		view.Selection,
+		clearSummary(view),
+		view.DDGGen,
+		view.ReadOnly,
+		view.Quiet,
+		emptyToDash(view.PreGenerateHook),
+		view.MaxPerDay,
+		view.MaxPerWeek,
+		view.WeeklySummary,
+		emptyToDash(view.MailTo),
+		view.SMTPServer,
+		imapSummary(view),
+		view.AlertPerDay,
+		emptyToDash(strings.Join(view.Notifications, ", ")),
+		view.LogTarget,
+		egressSummary(view),
+		commandAliases(view.Aliases),
+	))
+}
+
+// settingsView is what 'ddg settings' prints.
+type settingsView struct {
+	APIKey            string            `json:"api_key"`
+	Keystore          string            `json:"keystore"`
+	Clipboard         string            `json:"clipboard"`
+	ClipboardBackends []string          `json:"clipboard_backends"`
+	Selection         string            `json:"selection"`
+	ClipboardClear    string            `json:"clipboard_clear_seconds"`
+	DDGGen            string            `json:"ddggen"`
+	ReadOnly          string            `json:"readonly"`
+	Quiet             string            `json:"quiet"`
+	PreGenerateHook   string            `json:"pre_generate_hook,omitempty"`
+	MaxPerDay         string            `json:"max_per_day"`
+	MaxPerWeek        string            `json:"max_per_week"`
+	WeeklySummary     string            `json:"weekly_summary"`
+	MailTo            string            `json:"mail_to,omitempty"`
+	SMTPServer        string            `json:"smtp_server"`
+	IMAPServer        string            `json:"imap_server,omitempty"`
+	IMAPUser          string            `json:"imap_user,omitempty"`
+	IMAPMailbox       string            `json:"imap_mailbox"`
+	AlertPerDay       string            `json:"alert_per_day"`
+	Notifications     []string          `json:"notifications"` // sink names; targets hold credentials
+	LogTarget         string            `json:"log_target"`
+	Egress            string            `json:"egress"`
+	EgressAllow       []string          `json:"egress_allow"` // in strict mode, including the API host
+	Aliases           map[string]string `json:"aliases,omitempty"`
 }
 
 func showVersion() {
-	fmt.Printf("ddg version %s\n", version)
+	out.Result(map[string]string{"version": version}, "ddg version "+version)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
 }
 
 func emptyToDash(s string) string {
@@ -224,106 +1248,194 @@ func emptyToDash(s string) string {
 	return s
 }
 
-type httpError struct {
-	StatusCode int
-	Err        error
-}
+var errCancelled = errors.New("cancelled by user")
 
-func (h *httpError) Error() string {
-	return h.Err.Error()
+// httpError is an error carrying the HTTP status it came with, from either
+// DuckDuckGo or a daemon.
+type httpError = ddg.APIError
+
+// apiFor returns a library client for apiKey using the shared transport.
+func apiFor(apiKey string) *ddg.Client {
+	return &ddg.Client{Token: apiKey, HTTPClient: apiClient, Tracer: tracer}
 }
 
-func requestEmail(apiKey string) (string, []byte, error) {
-	req, err := http.NewRequest("POST", endpoint, nil)
-	if err != nil {
-		return "", nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+func requestEmail(apiKey string) (string, error) {
+	return apiFor(apiKey).Generate(context.Background())
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// ensureConfig returns the config with the API key loaded, running setup
+// first if allowed and needed. From then on the key is redacted from errors
+// and the process doesn't dump core.
+func ensureConfig(allowSetup bool) (conf, error) {
+	cfg, err := loadSettings(allowSetup)
 	if err != nil {
-		return "", nil, err
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode == 401 {
-		// Only print invalid token, no response
-		return "", body, &httpError{StatusCode: 401, Err: fmt.Errorf("invalid token")}
+		return cfg, err
 	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", body, fmt.Errorf("HTTP %d", resp.StatusCode)
+	if cfg.APIKey == "" {
+		if err := loadToken(&cfg); err != nil {
+			return conf{}, err
+		}
+		if cfg.APIKey == "" {
+			return conf{}, fmt.Errorf("no API key in %s; set one with 'ddg token set'", keystoreName(cfg))
+		}
 	}
+	registerSecret(cfg.APIKey)
+	disableCoreDumps()
+	return cfg, nil
+}
 
-	var parsed ddgResp
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return "", body, fmt.Errorf("decode error: %w", err)
+// String keeps the API key out of anything that formats a conf.
+func (c conf) String() string {
+	type plain conf // without this method
+	if c.APIKey != "" {
+		c.APIKey = "[redacted]"
 	}
-	if parsed.Address == "" {
-		return "", body, fmt.Errorf("no address in response")
-	}
-	return parsed.Address + "@duck.com", body, nil
+	return fmt.Sprintf("%+v", plain(c))
 }
 
-func ensureConfig(allowSetup bool) (conf, error) {
+func (c conf) GoString() string { return c.String() }
+
+// loadSettings is ensureConfig without reading the API key from an external
+// keystore, for commands that don't talk to DuckDuckGo: they shouldn't
+// unlock the keychain (and prompt for it) just to read a setting. A key kept
+// in the config file is still there.
+func loadSettings(allowSetup bool) (conf, error) {
 	cfg, err := readConfig()
-	if err == nil && cfg.APIKey != "" && strings.EqualFold(cfg.SetupComplete, "true") {
+	var broken *configError
+	if errors.As(err, &broken) {
+		cfg, err = repairConfig(cfg, broken)
+		if err != nil {
+			return conf{}, err
+		}
+	}
+	hasKey := cfg.APIKey != "" || keystoreName(cfg) != keystoreFile
+	if err == nil && hasKey && strings.EqualFold(cfg.SetupComplete, "true") {
 		if cfg.Clipboard == "" {
 			cfg.Clipboard = defaultClip
 		}
 		if cfg.DDGGen == "" {
 			cfg.DDGGen = defaultDDGGen
 		}
-		_ = writeConfig(cfg)
+		if !isReadOnly() {
+			_ = writeConfig(cfg)
+		}
 		return cfg, nil
 	}
 
 	if !allowSetup {
-		fmt.Println("It looks like you haven't finished setting up DuckDuckGone! Please run ddg to get started.")
-		os.Exit(1)
+		path, _ := confPath()
+		return conf{}, &configError{Path: path, Text: "DuckDuckGone isn't set up yet; run ddg to get started"}
 	}
 
+	requireWritable("run the setup wizard")
+
 	// Setup wizard
-	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("Hi! Looks like you haven't used DuckDuckGone before!")
 
-	fmt.Print("Enter your API key: ")
-	api := strings.TrimSpace(readLine(reader))
-	if api == "" {
-		return conf{}, fmt.Errorf("no API key provided")
-	}
-
-	fmt.Printf("Copy emails to clipboard automatically? (yes/no) [yes]: ")
-	clip := strings.TrimSpace(readLine(reader))
-	if clip == "" {
-		clip = defaultClip
-	}
-
-	fmt.Printf("Run 'ddg' to generate an email automatically? (yes/no) [yes]: ")
-	ddggen := strings.TrimSpace(readLine(reader))
-	if ddggen == "" {
-		ddggen = defaultDDGGen
+	var api string
+	for {
+		api = ask(prompt{
+			Label:    "Enter your API key",
+			Env:      "DDG_API_KEY",
+			Secret:   true,
+			Validate: validNotEmpty,
+		})
+		err := checkToken(api)
+		if err == nil {
+			fmt.Println(sym("✅") + " API key accepted.")
+			break
+		}
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
+			fmt.Fprintln(os.Stderr, red("DuckDuckGo rejected that API key (401). Check it and try again."))
+			if _, fromEnv := os.LookupEnv("DDG_API_KEY"); fromEnv {
+				return conf{}, fmt.Errorf("DDG_API_KEY: invalid token")
+			}
+			continue
+		}
+		// Offline or DuckDuckGo is having a bad day; don't hold setup hostage.
+		fmt.Fprintf(os.Stderr, "Warning: could not verify the API key (%v); continuing anyway.\n", err)
+		break
 	}
+	clipAnswer := ask(prompt{
+		Label:    "Copy emails to clipboard automatically? (yes/no)",
+		Default:  defaultClip,
+		Env:      "DDG_CLIPBOARD",
+		Validate: validYesNo,
+	})
+	ddggen := ask(prompt{
+		Label:    "Run 'ddg' to generate an email automatically? (yes/no)",
+		Default:  defaultDDGGen,
+		Env:      "DDG_DDGGEN",
+		Validate: validYesNo,
+	})
 
 	cfg = conf{
 		APIKey:        api,
-		Clipboard:     strings.ToLower(clip),
+		Clipboard:     strings.ToLower(clipAnswer),
 		DDGGen:        strings.ToLower(ddggen),
 		SetupComplete: "true",
 	}
 	if err := writeConfig(cfg); err != nil {
 		return conf{}, err
 	}
+	setupTest(cfg)
 	return cfg, nil
 }
 
+// setupTestLabel marks the alias generated by the first-run test.
+const setupTestLabel = "setup-test"
+
+// setupTested is set once the first-run test generated an alias, so the
+// same run doesn't immediately generate another one.
+var setupTested bool
+
+// setupTest offers to generate one alias right after setup, to prove the
+// key, history and clipboard all work end to end.
+func setupTest(cfg conf) {
+	answer := ask(prompt{
+		Label:    "Generate a test alias now to check everything works? This uses up one real address. (yes/no)",
+		Default:  "no",
+		Env:      "DDG_SETUP_TEST",
+		Validate: validYesNo,
+	})
+	if !strings.EqualFold(answer, "yes") {
+		return
+	}
+	entry, err := generateAlias(cfg, historyEntry{Label: setupTestLabel})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Test generation failed: %v\n", err)
+		return
+	}
+	setupTested = true
+	fmt.Printf("%s Generated %s (saved in history as %q).\n", sym("✅"), cyan(entry.Address), setupTestLabel)
+	if !strings.EqualFold(cfg.Clipboard, "yes") {
+		return
+	}
+	if backend, err := copyToClipboard(cfg, entry.Address); err == nil {
+		fmt.Printf("%s Copied to clipboard via %s; try pasting it.\n", sym("✅"), backend)
+	} else {
+		fmt.Fprintf(os.Stderr, "Clipboard copy failed: %v\nRun 'ddg doctor' to see which backends were found.\n", err)
+	}
+}
+
 func writeConfig(c conf) error {
 	path, err := confPath()
 	if err != nil {
 		return err
 	}
-	data := fmt.Sprintf("api = %s\nclipboard = %s\nddggen = %s\nsetupcomplete = %s\n",
-		c.APIKey, c.Clipboard, c.DDGGen, c.SetupComplete)
+	if err := writeConfigFile(path, formatConfig(c)); err != nil {
+		return err
+	}
+	scrubConfigBackups(path)
+	return nil
+}
+
+// writeConfigFile writes data to the config at path, first rotating the
+// current version into the backups if it differs.
+func writeConfigFile(path, data string) error {
+	if err := rotateConfigBackups(path, data); err != nil {
+		return fmt.Errorf("backing up the config: %w", err)
+	}
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err != nil {
 		return err
@@ -336,6 +1448,84 @@ func writeConfig(c conf) error {
 	return nil
 }
 
+// formatConfig renders c in the config file format.
+func formatConfig(c conf) string {
+	api := c.APIKey
+	if keystoreName(c) != keystoreFile {
+		// The key lives in an external keystore; never leave a copy on disk.
+		api = ""
+	}
+	data := fmt.Sprintf("api = %s\nclipboard = %s\nddggen = %s\nsetupcomplete = %s\n",
+		api, c.Clipboard, c.DDGGen, c.SetupComplete)
+	if c.Keystore != "" {
+		data += fmt.Sprintf("keystore = %s\n", c.Keystore)
+	}
+	if c.ReadOnly != "" {
+		data += fmt.Sprintf("readonly = %s\n", c.ReadOnly)
+	}
+	if c.Quiet != "" {
+		data += fmt.Sprintf("quiet = %s\n", c.Quiet)
+	}
+	if c.ClipBackends != "" {
+		data += fmt.Sprintf("clipboard_backends = %s\n", c.ClipBackends)
+	}
+	if c.Selection != "" {
+		data += fmt.Sprintf("selection = %s\n", c.Selection)
+	}
+	if c.ClipClear != "" {
+		data += fmt.Sprintf("%s = %s\n", clipClearKey, c.ClipClear)
+	}
+	if c.PreGenerateHook != "" {
+		data += fmt.Sprintf("pre_generate_hook = %s\n", c.PreGenerateHook)
+	}
+	if c.MaxPerDay != "" {
+		data += fmt.Sprintf("max_per_day = %s\n", c.MaxPerDay)
+	}
+	if c.MaxPerWeek != "" {
+		data += fmt.Sprintf("max_per_week = %s\n", c.MaxPerWeek)
+	}
+	if c.TeamDir != "" {
+		data += fmt.Sprintf("team_dir = %s\nteam_member = %s\n", c.TeamDir, c.TeamMember)
+	}
+	if c.WeeklySummary != "" {
+		data += fmt.Sprintf("weekly_summary = %s\n", c.WeeklySummary)
+	}
+	if c.MailTo != "" {
+		data += fmt.Sprintf("mail_to = %s\n", c.MailTo)
+	}
+	if c.SMTPServer != "" {
+		data += fmt.Sprintf("smtp_server = %s\n", c.SMTPServer)
+	}
+	if c.SMTPUser != "" {
+		data += fmt.Sprintf("smtp_user = %s\n", c.SMTPUser)
+	}
+	if c.IMAPServer != "" {
+		data += fmt.Sprintf("imap_server = %s\nimap_user = %s\n", c.IMAPServer, c.IMAPUser)
+	}
+	if c.IMAPMailbox != "" {
+		data += fmt.Sprintf("imap_mailbox = %s\n", c.IMAPMailbox)
+	}
+	if c.AlertPerDay != "" {
+		data += fmt.Sprintf("alert_per_day = %s\n", c.AlertPerDay)
+	}
+	if c.LogTarget != "" {
+		data += fmt.Sprintf("log_target = %s\n", c.LogTarget)
+	}
+	if c.Egress != "" {
+		data += fmt.Sprintf("egress = %s\n", c.Egress)
+	}
+	if c.EgressAllow != "" {
+		data += fmt.Sprintf("egress_allow = %s\n", c.EgressAllow)
+	}
+	for _, name := range sortedKeys(c.Notifications) {
+		data += fmt.Sprintf("%s%s = %s\n", notificationPrefix, name, c.Notifications[name])
+	}
+	for _, name := range sortedKeys(c.Aliases) {
+		data += fmt.Sprintf("%s%s = %s\n", aliasPrefix, name, c.Aliases[name])
+	}
+	return data
+}
+
 func readConfig() (conf, error) {
 	path, err := confPath()
 	if err != nil {
@@ -345,9 +1535,15 @@ func readConfig() (conf, error) {
 	if err != nil {
 		return conf{}, err
 	}
+	return parseConfig(path, b)
+}
+
+// parseConfig parses config file contents; path only labels errors.
+func parseConfig(path string, b []byte) (conf, error) {
 	var c conf
+	var bad *configError
 	sc := bufio.NewScanner(bytes.NewReader(b))
-	for sc.Scan() {
+	for n := 1; sc.Scan(); n++ {
 		line := sc.Text()
 		if idx := strings.Index(line, "#"); idx >= 0 {
 			line = line[:idx]
@@ -357,11 +1553,16 @@ func readConfig() (conf, error) {
 			continue
 		}
 		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || !utf8.ValidString(line) || strings.ContainsRune(line, 0) {
+			// Keep going: whatever does parse may be worth saving.
+			if bad == nil {
+				bad = &configError{Path: path, Line: n, Text: sc.Text()}
+			}
 			continue
 		}
 		key := strings.ToLower(strings.TrimSpace(parts[0]))
-		val := strings.ToLower(strings.TrimSpace(parts[1]))
+		raw := strings.TrimSpace(parts[1])
+		val := strings.ToLower(raw)
 		switch key {
 		case apiKey:
 			c.APIKey = trimQuotes(val)
@@ -369,14 +1570,83 @@ func readConfig() (conf, error) {
 			c.Clipboard = trimQuotes(val)
 		case ddgGen:
 			c.DDGGen = trimQuotes(val)
+		case keyStore:
+			c.Keystore = trimQuotes(val)
+		case readOnlyKey:
+			c.ReadOnly = trimQuotes(val)
+		case quietKey:
+			c.Quiet = trimQuotes(val)
+		case clipBackends, "clipboard_backend":
+			c.ClipBackends = val
+		case selection:
+			c.Selection = trimQuotes(val)
+		case clipClearKey:
+			c.ClipClear = trimQuotes(val)
+		case preGenerateHook:
+			c.PreGenerateHook = raw
+		case maxPerDay:
+			c.MaxPerDay = trimQuotes(val)
+		case maxPerWeek:
+			c.MaxPerWeek = trimQuotes(val)
+		case teamDirKey:
+			c.TeamDir = trimQuotes(raw)
+		case teamMemberKey:
+			c.TeamMember = trimQuotes(val)
+		case weeklySummaryKey:
+			c.WeeklySummary = trimQuotes(val)
+		case mailToKey:
+			c.MailTo = trimQuotes(raw)
+		case smtpServerKey:
+			c.SMTPServer = trimQuotes(raw)
+		case smtpUserKey:
+			c.SMTPUser = trimQuotes(raw)
+		case imapServerKey:
+			c.IMAPServer = trimQuotes(raw)
+		case imapUserKey:
+			c.IMAPUser = trimQuotes(raw)
+		case imapMailboxKey:
+			c.IMAPMailbox = trimQuotes(raw)
+		case alertPerDayKey:
+			c.AlertPerDay = trimQuotes(val)
+		case logTargetKey:
+			c.LogTarget = trimQuotes(val)
+		case egressKey:
+			c.Egress = trimQuotes(val)
+		case egressAllowKey:
+			c.EgressAllow = val
 		case "setupcomplete":
 			c.SetupComplete = trimQuotes(val)
+		default:
+			if name, ok := strings.CutPrefix(key, notificationPrefix); ok && name != "" {
+				if c.Notifications == nil {
+					c.Notifications = map[string]string{}
+				}
+				c.Notifications[name] = trimQuotes(raw)
+			} else if name, ok := strings.CutPrefix(key, aliasPrefix); ok && name != "" {
+				if c.Aliases == nil {
+					c.Aliases = map[string]string{}
+				}
+				c.Aliases[name] = raw
+			}
 		}
 	}
+	if err := sc.Err(); err != nil && bad == nil {
+		bad = &configError{Path: path, Text: err.Error()}
+	}
+	if bad != nil {
+		return c, bad
+	}
 	return c, nil
 }
 
 func confPath() (string, error) {
+	if profileName != "" {
+		dir, err := dataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, profileConfName), nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -384,6 +1654,28 @@ func confPath() (string, error) {
 	return filepath.Join(home, confFileName), nil
 }
 
+// splitList parses list values such as ["a","b"] or a, b.
+func splitList(s string) []string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "[")
+	s = strings.TrimSuffix(s, "]")
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = trimQuotes(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// formatList is the inverse of splitList.
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return `["` + strings.Join(items, `","`) + `"]`
+}
+
 func trimQuotes(s string) string {
 	s = strings.TrimSpace(s)
 	s = strings.Trim(s, `"'`)
@@ -395,27 +1687,10 @@ func readLine(r *bufio.Reader) string {
 	return strings.TrimRight(text, "\r\n")
 }
 
-func copyToClipboard(text string) error {
-	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("clipboard not supported on %s", runtime.GOOS)
-	}
-	cmd := exec.Command("pbcopy")
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-	if err := cmd.Start(); err != nil {
-		return err
-	}
-	if _, err := io.WriteString(stdin, text); err != nil {
-		return err
-	}
-	_ = stdin.Close()
-	return cmd.Wait()
-}
-
 func exitErr(err error) {
-	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if !writeErrorReport(err) {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", redactSecrets(err.Error()))
+	}
 	if ee, ok := err.(*exec.ExitError); ok {
 		if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
 			os.Exit(ws.ExitStatus())
@@ -0,0 +1,49 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// mdnsService is the DNS-SD type the daemon advertises itself as.
+const mdnsService = "_ddg._tcp"
+
+// advertise announces the daemon on the LAN via Bonjour/mDNS so phones and
+// other machines can find it, using the system's responder: dns-sd on macOS
+// (and Windows with Bonjour), avahi-publish-service on Linux. It returns a
+// func that withdraws the announcement.
+func advertise(addr string) (func(), error) {
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	name := "ddg on " + orDefault(host, "this machine")
+	txt := []string{"version=" + version, "auth=bearer", "generate=/generate", "openapi=/openapi.json"}
+
+	var cmd *exec.Cmd
+	switch {
+	case hasCommand("dns-sd"):
+		cmd = exec.Command("dns-sd", append([]string{"-R", name, mdnsService, "local", strconv.Itoa(port)}, txt...)...)
+	case hasCommand("avahi-publish-service"):
+		cmd = exec.Command("avahi-publish-service", append([]string{name, mdnsService, strconv.Itoa(port)}, txt...)...)
+	default:
+		return nil, fmt.Errorf("advertising needs dns-sd (macOS, Bonjour for Windows) or avahi-publish-service (Linux)")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// doMuttQuery implements mutt's query_command protocol over the alias
+// history: a status line, then address<TAB>name<TAB>other per match, and
+// exit status 1 when nothing matched. aerc's address-book-cmd wants the
+// matches without the status line; pass --no-header for it.
+//
+//	set query_command = "ddg mutt-query '%s'"             # mutt, neomutt
+//	address-book-cmd = ddg mutt-query --no-header "%s"    # aerc
+func doMuttQuery(args []string) {
+	header := true
+	var words []string
+	for _, a := range args {
+		if a == "--no-header" {
+			header = false
+		} else {
+			words = append(words, a)
+		}
+	}
+	q := strings.ToLower(strings.TrimSpace(strings.Join(words, " ")))
+
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	entries = mergeHistory(entries, nil)
+	var lines []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if hasTag(e.Tags, burnedTag) {
+			continue
+		}
+		if q != "" && !strings.Contains(strings.ToLower(e.Address+" "+e.Label+" "+strings.Join(e.Tags, " ")), q) {
+			continue
+		}
+		name := muttField(e.Label)
+		other := e.Created.Local().Format("2006-01-02")
+		if len(e.Tags) > 0 {
+			other += " " + strings.Join(e.Tags, ",")
+		}
+		lines = append(lines, e.Address+"\t"+name+"\t"+muttField(other))
+	}
+
+	if header {
+		fmt.Printf("ddg: %d matching aliases\n", len(lines))
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	if len(lines) == 0 {
+		os.Exit(1)
+	}
+}
+
+// muttField keeps tabs and newlines from breaking the query format.
+func muttField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// namespaceTagPrefix starts the tag a namespace puts on every alias made in
+// it. QA runs generate their test accounts with
+//
+//	ddg gen -n 20 --namespace qa-run-42
+//
+// which tags each one ns:qa-run-42, so the run's aliases can be listed,
+// exported and burned together once it's over.
+const namespaceTagPrefix = "ns:"
+
+// namespaceTag checks a namespace name and returns its tag.
+func namespaceTag(ns string) (string, error) {
+	bad := strings.IndexFunc(ns, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	})
+	if ns == "" || len(ns) > 64 || bad >= 0 {
+		return "", fmt.Errorf("namespace %q: use up to 64 letters, digits, '-', '_' and '.'", ns)
+	}
+	return namespaceTagPrefix + ns, nil
+}
+
+// namespaceOf returns the namespace among tags, if there is one.
+func namespaceOf(tags []string) string {
+	for _, t := range tags {
+		if len(t) > len(namespaceTagPrefix) && strings.EqualFold(t[:len(namespaceTagPrefix)], namespaceTagPrefix) {
+			return t[len(namespaceTagPrefix):]
+		}
+	}
+	return ""
+}
+
+// completeNamespaces returns the namespaces in history, most recent first.
+func completeNamespaces(entries []historyEntry) []string {
+	var names []string
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		ns := namespaceOf(entries[i].Tags)
+		if ns != "" && entries[i].Deleted == nil && !seen[strings.ToLower(ns)] {
+			seen[strings.ToLower(ns)] = true
+			names = append(names, ns)
+		}
+	}
+	return names
+}
+
+// burnNamespace marks every alias in ns burned that isn't already, after
+// one confirmation for the lot.
+func burnNamespace(ns, reason string) {
+	tag, err := namespaceTag(ns)
+	if err != nil {
+		exitErr(err)
+	}
+	requireWritable("burn aliases")
+	rows, err := numberedHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var addresses []string
+	for _, r := range rows {
+		if hasTag(r.Tags, tag) && !hasTag(r.Tags, burnedTag) {
+			addresses = append(addresses, r.Address)
+		}
+	}
+	if len(addresses) == 0 {
+		exitErr(fmt.Errorf("no aliases in namespace %s are left to burn", ns))
+	}
+	if !confirm(confirmation{Question: fmt.Sprintf("Mark %d aliases in namespace %s burned?", len(addresses), ns), Risk: riskHigh}) {
+		audit("history.burn", errCancelled, map[string]string{"namespace": ns})
+		fmt.Println(sym("❌") + " Not marked burned.")
+		return
+	}
+	if reason == "" && stdinIsTerminal() {
+		reason = ask(prompt{Label: "Why burn them? (optional)"})
+	}
+
+	today, err := markBurned(addresses, reason)
+	audit("history.burn", err, map[string]string{"namespace": ns, "count": strconv.Itoa(len(addresses)), "reason": reason})
+	if err != nil {
+		exitErr(err)
+	}
+	res := map[string]interface{}{"namespace": ns, "addresses": addresses, fieldBurned: today}
+	if reason != "" {
+		res["reason"] = reason
+	}
+	out.Done(fmt.Sprintf("%d aliases in namespace %s marked burned. Deactivate them at %s; 'ddg list --namespace %s' lists them.",
+		len(addresses), ns, dashboardURL, ns), res)
+}
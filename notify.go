@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// notify shows a desktop notification, best effort: osascript on macOS,
+// notify-send on Linux and the BSDs, a PowerShell balloon on Windows. It
+// reports whether one was shown.
+func notify(title, msg string) bool {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e",
+			"display notification "+appleScriptString(msg)+" with title "+appleScriptString(title))
+	case "windows":
+		ps := `Add-Type -AssemblyName System.Windows.Forms;` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon;` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information;` +
+			`$n.Visible = $true;` +
+			`$n.ShowBalloonTip(5000, ` + psString(title) + `, ` + psString(msg) + `, 'None');` +
+			`Start-Sleep -Seconds 6; $n.Dispose()`
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", ps)
+	default:
+		if !hasCommand("notify-send") {
+			return false
+		}
+		cmd = exec.Command("notify-send", "--app-name=ddg", title, msg)
+	}
+	return cmd.Run() == nil
+}
+
+// psString quotes s as a PowerShell single-quoted string.
+func psString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
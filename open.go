@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// doOpen copies the alias generated for a site and opens the site's login
+// (or signup) page, which is what you do right after looking one up anyway.
+func doOpen(args []string) {
+	label, page := "", "login"
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--signup" {
+			page = "signup"
+		} else if strings.HasPrefix(args[i], "-") || label != "" {
+			unknownArg(args[i])
+		} else {
+			label = args[i]
+		}
+	}
+	if label == "" {
+		exitErr(fmt.Errorf("usage: ddg open <label> [--signup]"))
+	}
+	cfg, err := readConfig()
+	if err != nil && !os.IsNotExist(err) {
+		exitErr(err)
+	}
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	e, ok := findLabel(entries, label)
+	if !ok {
+		exitErr(fmt.Errorf("no alias labelled %q; generate one with 'ddg gen --for %s'", label, label))
+	}
+
+	res := map[string]string{"address": e.Address, "label": e.Label}
+	human := cyan(e.Address)
+	if backend, err := copyToClipboard(cfg, e.Address); err == nil {
+		res["clipboard"] = backend
+		human += fmt.Sprintf("\n(copied to clipboard via %s)", backend)
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: could not copy: %v\n", err)
+	}
+	if url, ok := siteURL(e.Label, page); ok {
+		if err := openBrowser(url); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not open %s: %v\n", url, err)
+		} else {
+			res["url"] = url
+			human += "\nOpened " + url
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%q doesn't look like a site, so there's nothing to open.\n", e.Label)
+	}
+	out.Result(res, human)
+}
+
+// findLabel returns the newest entry whose label is label, ignoring case and
+// a leading "www.".
+func findLabel(entries []historyEntry, label string) (historyEntry, bool) {
+	want := siteKey(label)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if siteKey(entries[i].Label) == want && !hasTag(entries[i].Tags, burnedTag) {
+			return entries[i], true
+		}
+	}
+	return historyEntry{}, false
+}
+
+func siteKey(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	label = strings.TrimPrefix(strings.TrimPrefix(label, "https://"), "http://")
+	return strings.TrimSuffix(strings.TrimPrefix(label, "www."), "/")
+}
+
+// siteURL guesses the login or signup page of the site a label names. A
+// label that is already a URL is used as is.
+func siteURL(label, page string) (string, bool) {
+	label = strings.TrimSpace(label)
+	if strings.HasPrefix(label, "https://") || strings.HasPrefix(label, "http://") {
+		return label, true
+	}
+	if !strings.Contains(label, ".") || strings.ContainsAny(label, " /") {
+		return "", false
+	}
+	return "https://" + strings.ToLower(label) + "/" + page, true
+}
+
+// openBrowser opens url in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
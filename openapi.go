@@ -0,0 +1,163 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// route is one daemon endpoint. The mux and /openapi.json are both built
+// from serveRoutes, so the spec can't drift from what is actually served.
+type route struct {
+	Path    string
+	Method  string
+	Summary string
+	Scope   string // required key scope; empty for open endpoints
+	Query   []apiParam
+	Body    schema // JSON request body, if any
+	Result  schema // 200 response
+	Stream  bool   // the response is text/event-stream
+	Handler func(*server, http.ResponseWriter, *http.Request)
+}
+
+type apiParam struct {
+	Name, Desc string
+}
+
+// schema is a JSON Schema fragment.
+type schema = map[string]interface{}
+
+func object(props schema, required ...string) schema {
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func str(desc string) schema { return schema{"type": "string", "description": desc} }
+
+func ref(name string) schema { return schema{"$ref": "#/components/schemas/" + name} }
+
+var serveRoutes = []route{
+	{Path: "/health", Method: http.MethodGet, Summary: "Liveness and version",
+		Result:  object(schema{"status": str(`Always "ok"`), "version": str("ddg version")}),
+		Handler: (*server).handleHealth},
+	{Path: "/generate", Method: http.MethodPost, Summary: "Generate an alias", Scope: scopeGenerate,
+		Query:   []apiParam{{"label", "What the alias is for (alternative to the body)"}},
+		Body:    object(schema{"label": str("What the alias is for")}),
+		Result:  ref("Alias"),
+		Handler: (*server).handleGenerate},
+	{Path: "/history", Method: http.MethodGet, Summary: "The local alias history", Scope: scopeHistory,
+		Result:  schema{"type": "array", "items": ref("HistoryEntry")},
+		Handler: (*server).handleHistory},
+	{Path: "/account", Method: http.MethodGet, Summary: "The DuckDuckGo account behind the key, cached for 10 minutes", Scope: scopeHistory,
+		Result:  ref("Account"),
+		Handler: (*server).handleAccount},
+	{Path: "/stats", Method: http.MethodGet, Summary: "Uptime, request counts and latencies",
+		Result:  schema{"type": "object"},
+		Handler: (*server).handleStats},
+	{Path: "/events", Method: http.MethodGet, Summary: "Server-sent events for new aliases", Scope: scopeEvents,
+		Query:   []apiParam{{"access_token", "The key, for clients that can't set headers (EventSource)"}},
+		Result:  ref("Event"),
+		Stream:  true,
+		Handler: (*server).handleEvents},
+	{Path: "/bookmarklet", Method: http.MethodPost, Summary: "Generate an alias for the page the bookmarklet runs on", Scope: scopeBookmarklet,
+		Body:    object(schema{"url": str("The page's URL")}, "url"),
+		Result:  ref("Alias"),
+		Handler: (*server).handleBookmarklet},
+	{Path: "/pair", Method: http.MethodPost, Summary: "Redeem a one-time code from 'ddg pair' for a generate key",
+		Query:   []apiParam{{"code", "The pairing code"}},
+		Result:  object(schema{"key": str("A new key with the generate scope"), "user": str("Who it generates as"), "generate": str("Where to POST for an alias")}, "key"),
+		Handler: (*server).handlePair},
+}
+
+// openAPIRoute is served outside serveRoutes: its handler reads the table,
+// which would otherwise refer to itself.
+var openAPIRoute = route{Path: "/openapi.json", Method: http.MethodGet, Summary: "This document",
+	Result: schema{"type": "object"}}
+
+var apiSchemas = schema{
+	"Alias": object(schema{
+		"address": str("The new alias"),
+		"label":   str("Its label"),
+		"shared":  schema{"type": "boolean", "description": "Set when identical requests arrived together and all got this alias"},
+	}, "address"),
+	"HistoryEntry": object(schema{
+		"address": str("The alias"),
+		"created": schema{"type": "string", "format": "date-time"},
+		"label":   str("What it's for"),
+		"tags":    schema{"type": "array", "items": schema{"type": "string"}},
+		"notes":   str("Free-form notes"),
+		"fields":  schema{"type": "object", "additionalProperties": schema{"type": "string"}},
+		"review":  schema{"type": "string", "format": "date-time"},
+	}, "address", "created"),
+	"Event": object(schema{
+		"type":    str(`"generate"`),
+		"user":    str("Whose alias it is"),
+		"address": str("The alias"),
+		"label":   str("Its label"),
+		"time":    schema{"type": "string", "format": "date-time"},
+		"source":  str(`"daemon", or "cli" for aliases made outside the daemon`),
+	}),
+	"Account": object(schema{
+		"username":            str("The personal address is <username>@duck.com"),
+		"forwarding_email":    str("Where mail to every alias goes"),
+		"addresses_generated": schema{"type": "integer"},
+		"fetched":             schema{"type": "string", "format": "date-time"},
+		"stale":               schema{"type": "boolean", "description": "DuckDuckGo couldn't be reached; these are older than 10 minutes"},
+	}),
+	"Error": object(schema{"error": str("What went wrong")}, "error"),
+}
+
+func (s *server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec("http://"+r.Host))
+}
+
+// openAPISpec describes serveRoutes as an OpenAPI 3.0 document.
+func openAPISpec(base string) schema {
+	paths := schema{}
+	for _, rt := range append(serveRoutes, openAPIRoute) {
+		errResp := schema{"description": "Error", "content": schema{"application/json": schema{"schema": ref("Error")}}}
+		ok := schema{"description": "OK", "content": schema{"application/json": schema{"schema": rt.Result}}}
+		if rt.Stream {
+			ok = schema{"description": "A text/event-stream of events, each a JSON object",
+				"content": schema{"text/event-stream": schema{"schema": rt.Result}}}
+		}
+		op := schema{
+			"summary":   rt.Summary,
+			"responses": schema{"200": ok, "default": errResp},
+		}
+		if rt.Scope != "" {
+			op["security"] = []schema{{"bearer": []string{}}}
+			op["description"] = "Needs a key with the " + rt.Scope + " scope, except in single-user mode with no keys issued."
+		} else {
+			op["security"] = []schema{}
+		}
+		var params []schema
+		for _, p := range rt.Query {
+			params = append(params, schema{"name": p.Name, "in": "query", "description": p.Desc, "schema": schema{"type": "string"}})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.Body != nil {
+			op["requestBody"] = schema{"content": schema{"application/json": schema{"schema": rt.Body}}}
+		}
+		paths[rt.Path] = schema{strings.ToLower(rt.Method): op}
+	}
+	return schema{
+		"openapi": "3.0.3",
+		"info":    schema{"title": "DuckDuckGone daemon", "version": version},
+		"servers": []schema{{"url": base}},
+		"paths":   paths,
+		"components": schema{
+			"schemas": apiSchemas,
+			"securitySchemes": schema{
+				"bearer": schema{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []schema{{"bearer": []string{}}},
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// termCaps is what the terminal on stdout can display.
+type termCaps struct {
+	Color      bool
+	Emoji      bool
+	Hyperlinks bool   // OSC 8 links
+	Graphics   string // inline image protocol: graphicsKitty, graphicsITerm or ""
+}
+
+// Inline image protocols.
+const (
+	graphicsKitty = "kitty"
+	graphicsITerm = "iterm"
+)
+
+// caps is detected once at startup; everything that prints colors or emoji
+// goes through colorize and sym below instead of embedding escape codes.
+var caps = detectCaps()
+
+// ciEnvVars are set by common CI systems, whose log viewers mangle ANSI
+// escapes more often than not.
+var ciEnvVars = []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION"}
+
+func detectCaps() termCaps {
+	term := os.Getenv("TERM")
+	if term == "dumb" {
+		return termCaps{}
+	}
+	c := termCaps{Color: isTerminal(), Emoji: true}
+	if os.Getenv("NO_COLOR") != "" {
+		c.Color = false
+	}
+	for _, v := range ciEnvVars {
+		if os.Getenv(v) != "" {
+			c.Color = false
+		}
+	}
+	if c.Color && !enableVT() {
+		// Legacy Windows console: no escape sequences, and its fonts lack
+		// most emoji too.
+		c.Color = false
+		c.Emoji = false
+	}
+	if os.Getenv("FORCE_COLOR") != "" {
+		c.Color = true
+	}
+	if c.Color && isTerminal() {
+		c.Hyperlinks, c.Graphics = detectTermFeatures()
+	}
+	return c
+}
+
+// detectTermFeatures recognizes terminals known to support hyperlinks and
+// inline images. Inside tmux images would need passthrough, so only links
+// are used there.
+func detectTermFeatures() (links bool, graphics string) {
+	prog := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty":
+		links, graphics = true, graphicsKitty
+	case prog == "iTerm.app" || prog == "WezTerm":
+		links, graphics = true, graphicsITerm
+	case prog == "vscode" || os.Getenv("WT_SESSION") != "" || os.Getenv("VTE_VERSION") != "":
+		links = true
+	}
+	if os.Getenv("TMUX") != "" {
+		graphics = ""
+	}
+	return links, graphics
+}
+
+// hyperlink makes text a clickable link to url where the terminal supports
+// it, so e.g. an alias can be cmd-clicked into a new mail.
+func hyperlink(url, text string) string {
+	if !caps.Hyperlinks {
+		return text
+	}
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// ANSI SGR codes used by ddg.
+const (
+	colorRed    = "31"
+	colorCyan   = "36"
+	colorOrange = "38;5;214"
+)
+
+func colorize(code, s string) string {
+	if !caps.Color {
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+func cyan(s string) string { return colorize(colorCyan, s) }
+func red(s string) string  { return colorize(colorRed, s) }
+
+// symFallbacks are plain-text stand-ins for terminals that can't show emoji.
+var symFallbacks = map[string]string{
+	"✅":  "[ok]",
+	"❌":  "[cancelled]",
+	"⚠️": "[!]",
+	"🔒":  "[read-only]",
+	"✓":  "+",
+	"✗":  "-",
+}
+
+// sym returns emoji e, or its plain fallback where emoji aren't supported.
+func sym(e string) string {
+	if caps.Emoji {
+		return e
+	}
+	if f, ok := symFallbacks[e]; ok {
+		return f
+	}
+	return strings.TrimSpace(e)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Package metadata, the one place downstream manifests take it from.
+const (
+	projectName    = "duckduckgone"
+	projectDesc    = "DuckDuckGo email alias generator"
+	projectLicense = "MIT"
+	repoURL        = "https://github.com/mikkmer/duckduckgone"
+)
+
+// Homebrew and the AUR build from the tagged source; Scoop installs the
+// windows/amd64 release bundle ('ddg bundle'), since Windows users don't
+// have Go.
+var manifestTypes = map[string]func(io.Writer, string) error{
+	"homebrew": writeHomebrewFormula,
+	"scoop":    writeScoopManifest,
+	"aur":      writePKGBUILD,
+}
+
+// sourceURL is the tarball GitHub serves for the release tag.
+func sourceURL() string {
+	return repoURL + "/archive/refs/tags/v" + version + ".tar.gz"
+}
+
+func releaseURL(file string) string {
+	return repoURL + "/releases/download/v" + version + "/" + file
+}
+
+func doPackaging(args []string) {
+	if len(args) == 0 || args[0] != "manifest" {
+		exitErr(fmt.Errorf("usage: ddg packaging manifest --type homebrew|scoop|aur --sha256 <hex>|--file <path> [--out <file>]"))
+	}
+	typ, sum, file, outPath := "", "", "", ""
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--type" && i+1 < len(args) {
+			typ = strings.ToLower(args[i+1])
+			i++
+		} else if args[i] == "--sha256" && i+1 < len(args) {
+			sum = strings.ToLower(args[i+1])
+			i++
+		} else if args[i] == "--file" && i+1 < len(args) {
+			file = args[i+1]
+			i++
+		} else if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	write, ok := manifestTypes[typ]
+	if !ok {
+		exitErr(fmt.Errorf("usage: unknown --type %q (use homebrew, scoop or aur)", typ))
+	}
+	// The checksum is of what the manifest downloads, which this binary
+	// can't know: pass it, or the file to hash.
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			exitErr(err)
+		}
+		sum = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		artifact := "the v" + version + " source tarball (" + sourceURL() + ")"
+		if typ == "scoop" {
+			artifact = bundleName("windows", "amd64") + ".tar.gz from 'ddg bundle'"
+		}
+		exitErr(fmt.Errorf("usage: --sha256 <hex> or --file <path> of %s is required", artifact))
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, sum); err != nil {
+		exitErr(err)
+	}
+	if outPath == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(outPath, buf.Bytes(), 0644); err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Wrote the %s manifest for %s to %s.", typ, version, outPath),
+		map[string]interface{}{"type": typ, "version": version, "file": outPath, "sha256": sum})
+}
+
+type manifestData struct {
+	Name, Desc, License, Homepage, Version, URL, SHA256 string
+}
+
+func newManifestData(url, sum string) manifestData {
+	return manifestData{Name: projectName, Desc: projectDesc, License: projectLicense,
+		Homepage: repoURL, Version: version, URL: url, SHA256: sum}
+}
+
+var homebrewFormula = template.Must(template.New("homebrew").Parse(`class Duckduckgone < Formula
+  desc "{{.Desc}}"
+  homepage "{{.Homepage}}"
+  url "{{.URL}}"
+  sha256 "{{.SHA256}}"
+  license "{{.License}}"
+
+  depends_on "go" => :build
+
+  def install
+    ENV["CGO_ENABLED"] = "0"
+    system "go", "build", *std_go_args(ldflags: "-s -w", output: bin/"ddg")
+  end
+
+  test do
+    assert_match version.to_s, shell_output("#{bin}/ddg version")
+  end
+end
+`))
+
+func writeHomebrewFormula(w io.Writer, sum string) error {
+	return homebrewFormula.Execute(w, newManifestData(sourceURL(), sum))
+}
+
+var pkgbuild = template.Must(template.New("aur").Parse(`pkgname={{.Name}}
+pkgver={{.Version}}
+pkgrel=1
+pkgdesc="{{.Desc}}"
+arch=('x86_64' 'aarch64')
+url="{{.Homepage}}"
+license=('{{.License}}')
+makedepends=('go')
+source=("$pkgname-$pkgver.tar.gz::$url/archive/refs/tags/v$pkgver.tar.gz")
+sha256sums=('{{.SHA256}}')
+
+build() {
+  cd "$pkgname-$pkgver"
+  export CGO_ENABLED=0
+  export GOFLAGS="-trimpath -mod=readonly -modcacherw"
+  go build -ldflags "-s -w" -o ddg .
+}
+
+package() {
+  cd "$pkgname-$pkgver"
+  install -Dm755 ddg "$pkgdir/usr/bin/ddg"
+  install -Dm644 LICENSE "$pkgdir/usr/share/licenses/$pkgname/LICENSE"
+}
+`))
+
+func writePKGBUILD(w io.Writer, sum string) error {
+	return pkgbuild.Execute(w, newManifestData(sourceURL(), sum))
+}
+
+type scoopManifest struct {
+	Version      string              `json:"version"`
+	Description  string              `json:"description"`
+	Homepage     string              `json:"homepage"`
+	License      string              `json:"license"`
+	Architecture map[string]scoopArc `json:"architecture"`
+	ExtractDir   string              `json:"extract_dir"`
+	Bin          string              `json:"bin"`
+	Checkver     string              `json:"checkver"`
+	Autoupdate   scoopAutoupdate     `json:"autoupdate"`
+}
+
+type scoopArc struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash,omitempty"`
+}
+
+type scoopAutoupdate struct {
+	Architecture map[string]scoopArc `json:"architecture"`
+	ExtractDir   string              `json:"extract_dir"`
+}
+
+func writeScoopManifest(w io.Writer, sum string) error {
+	name := bundleName("windows", "amd64")
+	// Scoop fills in $version when it updates the manifest itself.
+	next := strings.ReplaceAll(name, version, "$version")
+	m := scoopManifest{
+		Version:      version,
+		Description:  projectDesc,
+		Homepage:     repoURL,
+		License:      projectLicense,
+		Architecture: map[string]scoopArc{"64bit": {URL: releaseURL(name + ".tar.gz"), Hash: sum}},
+		ExtractDir:   name,
+		Bin:          `bin\ddg.exe`,
+		Checkver:     "github",
+		Autoupdate: scoopAutoupdate{
+			Architecture: map[string]scoopArc{"64bit": {URL: repoURL + "/releases/download/v$version/" + next + ".tar.gz"}},
+			ExtractDir:   next,
+		},
+	}
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
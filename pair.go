@@ -0,0 +1,231 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pairingsFileName = "pairings.json"
+	pairingTTL       = 10 * time.Minute
+)
+
+// pairing is an outstanding one-time code from 'ddg pair'. Redeeming it at
+// POST /pair issues a generate key for User.
+type pairing struct {
+	Hash    string    `json:"hash"`
+	User    string    `json:"user"`
+	Expires time.Time `json:"expires"`
+}
+
+// doPair shows a QR code a phone can scan to get its own key for the
+// daemon, e.g. from an iOS Shortcut: scan, then POST to the scanned URL.
+func doPair(args []string) {
+	user, addr := "", ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--user" && i+1 < len(args) {
+			user = args[i+1]
+			i++
+		} else if args[i] == "--addr" && i+1 < len(args) {
+			addr = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	requireWritable("pair a device")
+	users, err := readUsers()
+	if err != nil {
+		exitErr(err)
+	}
+	if len(users) == 0 {
+		exitErr(fmt.Errorf("a phone reaches the daemon over the LAN, which needs users; add one with 'ddg serve users add <name>'"))
+	}
+	if user == "" && len(users) == 1 {
+		user = users[0].Name
+	}
+	found := false
+	for _, u := range users {
+		found = found || u.Name == user
+	}
+	if !found {
+		exitErr(fmt.Errorf("usage: ddg pair --user <name> (one of the daemon's users)"))
+	}
+	if addr == "" {
+		if addr, err = lanAddr(); err != nil {
+			exitErr(err)
+		}
+	}
+
+	code, err := randomHex(16)
+	if err != nil {
+		exitErr(err)
+	}
+	p := pairing{Hash: hashSecret(code), User: user, Expires: time.Now().Add(pairingTTL)}
+	err = updatePairings(func(ps []pairing) []pairing { return append(ps, p) })
+	audit("pair.start", err, map[string]string{"user": user})
+	if err != nil {
+		exitErr(err)
+	}
+
+	pairURL := fmt.Sprintf("http://%s/pair?code=%s", addr, code)
+	out.Result(map[string]string{"url": pairURL, "user": user, "expires": p.Expires.Format(time.RFC3339)}, fmt.Sprintf(
+		"Scan this with your phone and POST to the URL within %d minutes to get a key\nthat generates aliases as %s:\n\n%s",
+		int(pairingTTL.Minutes()), user, pairURL))
+	if !out.machine() {
+		if err := printQR(os.Stdout, pairURL); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// lanAddr is the running daemon's address as other devices on the LAN see
+// it: its port on this machine's first non-loopback IPv4 address.
+func lanAddr() (string, error) {
+	st, err := readDaemonState()
+	if err != nil || st.Addr == "" {
+		return "", fmt.Errorf("start the daemon first: ddg serve --addr 0.0.0.0:8765")
+	}
+	host, port, err := net.SplitHostPort(st.Addr)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		if ip.IsLoopback() {
+			return "", fmt.Errorf("the daemon only listens on %s; restart it with --addr 0.0.0.0:%s", host, port)
+		}
+		return st.Addr, nil
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLoopback() && ipn.IP.To4() != nil {
+			return net.JoinHostPort(ipn.IP.String(), port), nil
+		}
+	}
+	return "", fmt.Errorf("no LAN address found; pass --addr <ip:port>")
+}
+
+// handlePair redeems a pairing code for a new generate key. Codes work
+// once and expire; the key is only ever shown in this response.
+func (s *server) handlePair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	hash := hashSecret(r.URL.Query().Get("code"))
+	var p *pairing
+	err := updatePairings(func(ps []pairing) []pairing {
+		kept := ps[:0]
+		for i := range ps {
+			switch {
+			case time.Now().After(ps[i].Expires):
+			case p == nil && subtle.ConstantTimeCompare([]byte(ps[i].Hash), []byte(hash)) == 1:
+				match := ps[i]
+				p = &match
+			default:
+				kept = append(kept, ps[i])
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if p == nil {
+		audit("serve.pair", errPairing, nil)
+		writeJSONError(w, http.StatusUnauthorized, errPairing.Error())
+		return
+	}
+
+	secret, err := randomHex(24)
+	if err == nil {
+		k := serveKey{
+			Name:   "phone-" + time.Now().Format("20060102-150405"),
+			User:   p.User,
+			Scopes: []string{scopeGenerate},
+			Hash:   hashSecret(secret),
+		}
+		// Re-read the file: 'ddg serve keys' may have changed it since
+		// startup. currentKeys picks the new key up from there.
+		var keys []serveKey
+		if keys, err = readKeys(); err == nil {
+			err = writeKeys(append(keys, k))
+		}
+		audit("serve.pair", err, map[string]string{"user": p.User, "key": k.Name})
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"key":      secret,
+		"user":     p.User,
+		"generate": "http://" + r.Host + "/generate",
+	})
+}
+
+var errPairing = fmt.Errorf("unknown or expired pairing code; run 'ddg pair' again")
+
+// updatePairings rewrites the pairings file through f under a lock, since
+// 'ddg pair' and the daemon both change it.
+func updatePairings(f func([]pairing) []pairing) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, pairingsFileName)
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var ps []pairing
+	if b, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &ps); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	ps = f(ps)
+	b, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// currentKeys returns the issued keys, reloading keys.json whenever it has
+// changed, so 'ddg serve keys remove' revokes a key in a running daemon
+// too. A file caught half-written keeps the previous keys until the next
+// request.
+func (s *server) currentKeys() []serveKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := keysPath()
+	if err != nil {
+		return s.keys
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		s.keys, s.keysMod, s.keysSize = nil, time.Time{}, 0
+	case err == nil && (!fi.ModTime().Equal(s.keysMod) || fi.Size() != s.keysSize):
+		if keys, err := readKeys(); err == nil {
+			s.keys, s.keysMod, s.keysSize = keys, fi.ModTime(), fi.Size()
+		}
+	}
+	return s.keys
+}
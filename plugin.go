@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const pluginPrefix = "ddg-"
+
+// builtinCommands can't be replaced by plugins or aliases; see
+// isBuiltinCommand.
+var builtinCommands = map[string]bool{
+	"provision": true, "client": true, "serve": true, "history": true,
+	"token": true, "reset": true, "audit": true, "profile": true, "team": true, "sheet": true, "remind": true, "launcher": true, "rpc": true, "mutt-query": true, "register-url-handler": true, "handle-url": true, "completion": true, "__complete": true, "__clipboard-owner": true, "__clipboard-clear": true, "who": true, "whoami": true, "open": true, "field": true, "delete": true, "undelete": true, "restore-trash": true, "config": true, "insights": true, "doctor": true, "pair": true, "remote": true, "agent": true, "bench": true, "prompt-segment": true, "summary": true, "install-windows-service": true, "bundle": true, "packaging": true, "import": true, "scan-browser": true, "scan-mail": true, "imap": true, "noisiest": true, "report": true, "list": true, "ls": true, "search": true, "show": true, "burn": true, "export": true, "exec": true, "curlfill": true,
+	"version": true, "help": true,
+}
+
+// pluginContext is written to a plugin's stdin as JSON so it can find ddg's
+// files and daemon without guessing.
+type pluginContext struct {
+	Version  string   `json:"version"`
+	Command  string   `json:"command"`
+	Profile  string   `json:"profile"`
+	Args     []string `json:"args"`
+	Config   string   `json:"config"`
+	DataDir  string   `json:"data_dir"`
+	History  string   `json:"history"`
+	Audit    string   `json:"audit"`
+	Socket   string   `json:"socket,omitempty"`
+	Daemon   string   `json:"daemon,omitempty"`
+	ReadOnly bool     `json:"read_only"`
+	Output   string   `json:"output"`
+}
+
+// findPlugin returns the ddg-<cmd> executable on PATH, if cmd isn't a
+// built-in command.
+func findPlugin(cmd string) (string, bool) {
+	if cmd == "" || isBuiltinCommand(cmd) {
+		return "", false
+	}
+	if strings.ContainsAny(cmd, `/\`) {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + cmd)
+	return path, err == nil
+}
+
+// runPlugin runs a plugin with the terminal's stdout and stderr, passing
+// its context on stdin, and exits with the plugin's status.
+func runPlugin(path, name string, args []string) {
+	ctx := pluginContext{
+		Version:  version,
+		Command:  name,
+		Profile:  orDefault(profileName, defaultProfile),
+		Args:     args,
+		ReadOnly: isReadOnly(),
+		Output:   out.format,
+	}
+	if ctx.Args == nil {
+		ctx.Args = []string{}
+	}
+	ctx.Config, _ = confPath()
+	ctx.DataDir, _ = dataDir()
+	ctx.History, _ = historyPath()
+	ctx.Audit, _ = auditPath()
+	if st, err := readDaemonState(); err == nil {
+		ctx.Socket, ctx.Daemon = st.Socket, st.Addr
+	}
+	b, err := json.Marshal(ctx)
+	if err != nil {
+		exitErr(err)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(string(b) + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// The plugin has already said what went wrong.
+		if ee, ok := err.(*exec.ExitError); ok {
+			os.Exit(ee.ExitCode())
+		}
+		exitErr(err)
+	}
+}
+
+// listPlugins returns the names of the plugins on PATH.
+func listPlugins() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, pluginPrefix) || e.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			name = strings.TrimPrefix(name, pluginPrefix)
+			if _, ok := findPlugin(name); ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProfile     = "default"
+	profilesDirName    = "profiles"
+	profileConfName    = "ddg.conf"
+	profileArchiveConf = "config"
+	profileManifest    = "manifest.json"
+)
+
+// profileName is the active profile, from --profile or DDG_PROFILE. Empty
+// means the default profile, which keeps the original ~/.ddg.conf and
+// ~/.ddg layout. Every other profile lives entirely under
+// ~/.ddg/profiles/<name>: config, history, audit log, daemon files and
+// keys, and its keychain entries carry the profile name.
+var profileName string
+
+var validProfileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+func checkProfileName(name string) error {
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use lowercase letters, digits, - and _)", name)
+	}
+	return nil
+}
+
+// setProfile selects the active profile.
+func setProfile(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == defaultProfile {
+		profileName = ""
+		return nil
+	}
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	profileName = name
+	return nil
+}
+
+// profileDir returns the data directory of a named profile.
+func profileDir(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, dataDirName, profilesDirName, name), nil
+}
+
+// profileFiles are the files that make up a profile's data directory.
+// Runtime files (daemon.json, the socket) are never exported; the ones
+// marked secret only with --with-secrets.
+var profileFiles = []struct {
+	name   string
+	secret bool
+}{
+	{historyFileName, false},
+	{auditFileName, false},
+	{stateFileName, false},
+	{keysFileName, false},
+	{usersFileName, true}, // tokens are only as safe as server.key
+	{serverKeyFileName, true},
+	{auditKeyFileName, true},
+}
+
+// profileInfo is the manifest of an exported profile.
+type profileInfo struct {
+	Profile string    `json:"profile"`
+	Version string    `json:"version"`
+	Created time.Time `json:"created"`
+	Secrets bool      `json:"secrets"`
+}
+
+func doProfile(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg profile list | export [<name>] [--with-secrets] [--out <file>] | import <file> [--as <name>] [--force]"))
+	}
+	switch args[0] {
+	case "list":
+		profileList()
+	case "export":
+		profileExport(args[1:])
+	case "import":
+		profileImport(args[1:])
+	default:
+		exitErr(fmt.Errorf("usage: unknown profile command %s", args[0]))
+	}
+}
+
+type profileListItem struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+func profileList() {
+	items := []profileListItem{{Name: defaultProfile, Active: profileName == ""}}
+	if dir, err := profileDir(""); err == nil {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if e.IsDir() && checkProfileName(e.Name()) == nil {
+				items = append(items, profileListItem{Name: e.Name(), Active: e.Name() == profileName})
+			}
+		}
+	}
+	sort.Slice(items[1:], func(i, j int) bool { return items[i+1].Name < items[j+1].Name })
+	printList(items, func(p profileListItem) string {
+		if p.Active {
+			return "* " + cyan(p.Name)
+		}
+		return "  " + p.Name
+	})
+}
+
+func profileExport(args []string) {
+	name, outPath, secrets := "", "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--with-secrets" {
+			secrets = true
+		} else if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else if name == "" && !strings.HasPrefix(args[i], "--") {
+			name = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if name != "" {
+		if err := setProfile(name); err != nil {
+			exitErr(err)
+		}
+	}
+	name = orDefault(profileName, defaultProfile)
+	if outPath == "" {
+		outPath = "ddg-profile-" + name + ".tar.gz"
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		exitErr(fmt.Errorf("profile %s has no config: %w", name, err))
+	}
+	if secrets {
+		if err := loadToken(&cfg); err != nil {
+			exitErr(err)
+		}
+		// The importing machine may not share our keychain.
+		cfg.Keystore = ""
+	} else {
+		cfg.APIKey = ""
+	}
+	dir, err := dataDir()
+	if err != nil {
+		exitErr(err)
+	}
+
+	f, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		exitErr(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	manifest, _ := json.MarshalIndent(profileInfo{Profile: name, Version: version, Created: time.Now().UTC(), Secrets: secrets}, "", "  ")
+	err = write(profileManifest, manifest)
+	if err == nil {
+		err = write(profileArchiveConf, []byte(formatConfig(cfg)))
+	}
+	for _, pf := range profileFiles {
+		if err != nil {
+			break
+		}
+		if pf.secret && !secrets {
+			continue
+		}
+		var data []byte
+		if pf.name == auditKeyFileName {
+			// May live in the keychain; export whichever key signs for
+			// this profile.
+			key, kerr := auditSigningKey(false)
+			if kerr != nil {
+				continue
+			}
+			data = []byte(fmt.Sprintf("%x\n", key))
+		} else if data, err = os.ReadFile(filepath.Join(dir, pf.name)); os.IsNotExist(err) {
+			err = nil
+			continue
+		}
+		if err == nil {
+			err = write(pf.name, data)
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	audit("profile.export", err, map[string]string{"profile": name, "file": outPath, "secrets": yesNo(secrets)})
+	if err != nil {
+		os.Remove(outPath)
+		exitErr(err)
+	}
+	msg := fmt.Sprintf("Profile %s exported to %s", name, outPath)
+	if secrets {
+		msg += " " + red("(contains secrets: keep it safe)")
+	} else {
+		msg += " (without secrets)"
+	}
+	out.Done(msg+".", map[string]interface{}{"profile": name, "file": outPath, "secrets": secrets})
+}
+
+func profileImport(args []string) {
+	file, as, force := "", "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--as" && i+1 < len(args) {
+			as = args[i+1]
+			i++
+		} else if args[i] == "--force" {
+			force = true
+		} else if file == "" && !strings.HasPrefix(args[i], "--") {
+			file = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if file == "" {
+		exitErr(fmt.Errorf("usage: ddg profile import <file> [--as <name>] [--force]"))
+	}
+	requireWritable("import a profile")
+
+	files, err := readProfileArchive(file)
+	if err != nil {
+		exitErr(err)
+	}
+	var info profileInfo
+	if err := json.Unmarshal(files[profileManifest], &info); err != nil {
+		exitErr(fmt.Errorf("%s is not a ddg profile export", file))
+	}
+	data, ok := files[profileArchiveConf]
+	if !ok {
+		exitErr(fmt.Errorf("%s has no config, so it is not a complete ddg profile export", file))
+	}
+	c, err := parseConfig(file+":"+profileArchiveConf, data)
+	if err != nil {
+		exitErr(err)
+	}
+	if as == "" {
+		as = info.Profile
+	}
+	if err := setProfile(as); err != nil {
+		exitErr(err)
+	}
+	name := orDefault(profileName, defaultProfile)
+
+	if _, err := readConfig(); err == nil && !force {
+		exitErr(fmt.Errorf("profile %s already exists; import with --as <other name>, or --force to overwrite it", name))
+	}
+	dir, err := dataDir()
+	if err != nil {
+		exitErr(err)
+	}
+	known := map[string]bool{}
+	for _, pf := range profileFiles {
+		known[pf.name] = true
+	}
+	for n, data := range files {
+		if !known[n] {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, n), data, 0600); err != nil {
+			exitErr(err)
+		}
+	}
+	err = writeConfig(c)
+	audit("profile.import", err, map[string]string{"profile": name, "file": file, "secrets": yesNo(info.Secrets)})
+	if err != nil {
+		exitErr(err)
+	}
+	msg := fmt.Sprintf("Imported profile %s from %s.", name, file)
+	if !info.Secrets {
+		msg += " It has no API key yet: set one with 'ddg --profile " + name + " token set'."
+	}
+	out.Done(msg, map[string]interface{}{"profile": name, "secrets": info.Secrets})
+}
+
+// readProfileArchive returns the regular files in an export by name.
+func readProfileArchive(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// Flat archive: anything with a path in it isn't ours.
+		if h.Typeflag != tar.TypeReg || strings.ContainsAny(h.Name, `/\`) {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, 256<<20))
+		if err != nil {
+			return nil, err
+		}
+		files[h.Name] = data
+	}
+	return files, nil
+}
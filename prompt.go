@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// stdin is shared by every prompt so buffered input is never lost between
+// questions.
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt describes one question to the user.
+type prompt struct {
+	Label    string
+	Default  string
+	Env      string // answers the prompt when set, e.g. for scripted setups
+	Hint     string // how else to provide the answer, shown when stdin isn't a terminal
+	Secret   bool   // don't echo the answer
+	Validate func(string) error
+}
+
+// ask asks p and returns the (trimmed) answer, or the default for an empty
+// answer. Without a terminal on stdin it never blocks: the answer must come
+// from p.Env, otherwise ask exits with a message saying how to supply it.
+func ask(p prompt) string {
+	if p.Env != "" {
+		if v, ok := os.LookupEnv(p.Env); ok {
+			v = strings.TrimSpace(v)
+			if p.Validate != nil {
+				if err := p.Validate(v); err != nil {
+					exitErr(fmt.Errorf("%s: %w", p.Env, err))
+				}
+			}
+			return v
+		}
+	}
+	if !stdinIsTerminal() {
+		how := []string{}
+		if p.Env != "" {
+			how = append(how, "set "+p.Env)
+		}
+		if p.Hint != "" {
+			how = append(how, p.Hint)
+		}
+		msg := fmt.Sprintf("%q needs an answer but stdin is not a terminal", strings.TrimSuffix(p.Label, "?"))
+		if len(how) > 0 {
+			msg += "; " + strings.Join(how, " or ")
+		}
+		exitErr(fmt.Errorf("%s", msg))
+	}
+
+	for {
+		fmt.Print(p.Label)
+		if p.Default != "" {
+			fmt.Printf(" [%s]", colorize("1;"+colorCyan, p.Default))
+		}
+		fmt.Print(": ")
+
+		var answer string
+		if p.Secret {
+			answer = readSecret()
+		} else {
+			answer = readLine(stdin)
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = p.Default
+		}
+		if p.Validate != nil {
+			if err := p.Validate(answer); err != nil {
+				fmt.Fprintln(os.Stderr, red(err.Error()))
+				continue
+			}
+		}
+		return answer
+	}
+}
+
+// validYesNo accepts yes or no, in any case.
+func validYesNo(s string) error {
+	switch strings.ToLower(s) {
+	case "yes", "no":
+		return nil
+	}
+	return fmt.Errorf("please answer yes or no")
+}
+
+// validNotEmpty rejects empty answers.
+func validNotEmpty(s string) error {
+	if s == "" {
+		return fmt.Errorf("an answer is required")
+	}
+	return nil
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// readSecret reads a line without echoing it, so secrets don't end up in
+// scrollback or screen recordings, and cleans up what pasting tends to add.
+// If echo can't be turned off the line is read normally, with a warning.
+func readSecret() string {
+	restore, err := disableEcho()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(input will be visible: %v) ", err)
+		return cleanSecret(readLine(stdin))
+	}
+
+	// Put echo back even if the user hits Ctrl-C at the prompt.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigs:
+			restore()
+			fmt.Println()
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	line := readLine(stdin)
+	close(done)
+	signal.Stop(sigs)
+	restore()
+	fmt.Println()
+	return cleanSecret(line)
+}
+
+// cleanSecret strips what copy/paste typically drags along with a token:
+// bracketed-paste markers, surrounding whitespace and quotes, and a
+// "Bearer " prefix copied from a request header.
+func cleanSecret(s string) string {
+	s = strings.ReplaceAll(s, "\x1b[200~", "")
+	s = strings.ReplaceAll(s, "\x1b[201~", "")
+	s = strings.TrimSpace(s)
+	s = strings.Trim(s, `"'`)
+	if len(s) > 7 && strings.EqualFold(s[:7], "bearer ") {
+		s = s[7:]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// stateToken remembers whether DuckDuckGo last accepted the API key
+// ("ok") or rejected it ("invalid"), so prompt-segment can say so without
+// asking.
+const stateToken = "token_status"
+
+// noteTokenStatus records what err says about the API key. Errors other
+// than a 401 say nothing about it. The state file is only written when the
+// status changes.
+func noteTokenStatus(err error) {
+	status := "ok"
+	if ddg.Unauthorized(err) {
+		status = "invalid"
+	} else if err != nil {
+		return
+	}
+	if getState(stateToken) != status {
+		_ = setState(stateToken, status)
+	}
+}
+
+// doPromptSegment prints a short status for shell prompts (starship,
+// powerlevel10k): the profile, today's alias count and a warning if the key
+// was last rejected. It runs on every prompt, so it only reads local files
+// and never the keystore or the network. It exits 1 when the key is known to
+// be bad, for prompts that color or show segments by exit code.
+func doPromptSegment(args []string) {
+	for _, a := range args {
+		unknownArg(a)
+	}
+	today := 0
+	entries, _ := liveHistory()
+	y, m, d := time.Now().Date()
+	for _, e := range entries {
+		if ey, em, ed := e.Created.Local().Date(); ey == y && em == m && ed == d {
+			today++
+		}
+	}
+	token := orDefault(getState(stateToken), "unknown")
+
+	seg := fmt.Sprintf("%d today", today)
+	if profileName != "" {
+		seg = profileName + " " + seg
+	}
+	if token == "invalid" {
+		seg += " " + sym("⚠️") + " key rejected"
+	}
+	out.Result(map[string]interface{}{"profile": profileName, "today": today, "token": token}, seg)
+	if token == "invalid" {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const defaultProvisionDelay = time.Second
+
+// provisionRow is one line of a provisioning CSV: label,tags,notes.
+type provisionRow struct {
+	Row   int
+	Label string
+	Tags  []string
+	Notes string
+	Date  string
+}
+
+func doProvision(args []string) {
+	path := ""
+	delay := defaultProvisionDelay
+	parallel := 1
+	labelTmpl := ""
+	resume, restart := false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--delay" && i+1 < len(args) {
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				exitErr(fmt.Errorf("invalid --delay: %w", err))
+			}
+			delay = d
+			i++
+		} else if arg == "--parallel" && i+1 < len(args) {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				exitErr(fmt.Errorf("--parallel: want a positive number, got %q", args[i+1]))
+			}
+			parallel = n
+			i++
+		} else if arg == "--label-template" && i+1 < len(args) {
+			labelTmpl = args[i+1]
+			i++
+		} else if arg == "--resume" {
+			resume = true
+		} else if arg == "--restart" {
+			restart = true
+		} else if arg == "--override" {
+			overrideBudget = true
+		} else if path == "" && !strings.HasPrefix(arg, "--") {
+			path = arg
+		} else {
+			unknownArg(arg)
+		}
+	}
+	if path == "" {
+		exitErr(fmt.Errorf("usage: ddg provision <file.csv> [--delay 2s] [--parallel <n>] [--label-template <tmpl>] [--resume|--restart] [--override]"))
+	}
+
+	requireWritable("provision aliases")
+
+	var tmpl *template.Template
+	if labelTmpl != "" {
+		t, err := template.New("label").Parse(labelTmpl)
+		if err != nil {
+			exitErr(fmt.Errorf("invalid --label-template: %w", err))
+		}
+		tmpl = t
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		exitErr(err)
+	}
+	rows, err := readProvisionCSV(f)
+	f.Close()
+	if err != nil {
+		exitErr(fmt.Errorf("%s: %w", path, err))
+	}
+
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		exitErr(err)
+	}
+	j, err := openJournal("provision", abs, resume, restart)
+	if err != nil {
+		exitErr(err)
+	}
+	defer j.Close()
+	if j.Len() > 0 {
+		out.Info("Resuming: %d of %d rows already provisioned.", j.Len(), len(rows))
+	}
+
+	var jobs []provisionJob
+	for _, row := range rows {
+		label := row.Label
+		if tmpl != nil {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, row); err != nil {
+				exitErr(fmt.Errorf("row %d: label template: %w", row.Row, err))
+			}
+			label = buf.String()
+		}
+		key := label
+		if key == "" {
+			key = "#" + strconv.Itoa(row.Row)
+		}
+		if _, ok := j.Done(key); !ok {
+			jobs = append(jobs, provisionJob{row, label, key})
+		}
+	}
+
+	// The first failure stops everything: workers start no new rows and
+	// requests in flight are abandoned, so a rejected key shows up as one
+	// error rather than one per remaining row.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	todo := make(chan provisionJob)
+	var (
+		mu        sync.Mutex // guards the journal, output and everything below
+		generated int
+		firstErr  error
+		failed    provisionJob
+		wg        sync.WaitGroup
+	)
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer recoverCrash()
+			defer wg.Done()
+			first := true
+			for job := range todo {
+				if !first && delay > 0 {
+					select {
+					case <-time.After(delay):
+					case <-ctx.Done():
+					}
+				}
+				first = false
+				if ctx.Err() != nil {
+					continue
+				}
+				entry, err := generateAliasContext(ctx, cfg, historyEntry{Label: job.label, Tags: job.row.Tags, Notes: job.row.Notes})
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil && ctx.Err() == nil {
+						firstErr, failed = err, job
+						cancel()
+					}
+				} else if err := j.Record(job.key, entry.Address); err != nil && firstErr == nil {
+					firstErr, failed = err, job
+					cancel()
+				} else {
+					generated++
+					out.Item(entry, fmt.Sprintf("%s\t%s", job.label, cyan(entry.Address)))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, job := range jobs {
+		select {
+		case todo <- job:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(todo)
+	wg.Wait()
+
+	if firstErr != nil {
+		out.Flush()
+		if ddg.Unauthorized(firstErr) {
+			fmt.Fprintln(os.Stderr, red("Error! DuckDuckGo rejected the API key; stopped without trying the remaining rows."))
+		} else {
+			fmt.Fprintf(os.Stderr, "Error on row %d (%s): %v\n", failed.row.Row, failed.label, firstErr)
+		}
+		fmt.Fprintf(os.Stderr, "%d aliases generated this run. Rerun with --resume to continue.\n", generated)
+		os.Exit(1)
+	}
+
+	_ = j.Finish()
+	out.Flush()
+	out.Info(sym("✅")+" Provisioned %d aliases from %s.", generated, path)
+}
+
+// provisionJob is a row still to provision and its computed label and
+// journal key.
+type provisionJob struct {
+	row   provisionRow
+	label string
+	key   string
+}
+
+// readProvisionCSV parses label,tags,notes rows. A first row whose first
+// column is "label" is treated as a header and skipped.
+func readProvisionCSV(r io.Reader) ([]provisionRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Format("2006-01-02")
+	var rows []provisionRow
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "label") {
+			continue
+		}
+		row := provisionRow{Row: i + 1, Date: today}
+		if len(rec) > 0 {
+			row.Label = strings.TrimSpace(rec[0])
+		}
+		if len(rec) > 1 {
+			for _, t := range strings.Split(rec[1], ";") {
+				if t = strings.TrimSpace(t); t != "" {
+					row.Tags = append(row.Tags, t)
+				}
+			}
+		}
+		if len(rec) > 2 {
+			row.Notes = strings.TrimSpace(rec[2])
+		}
+		if row.Label == "" && row.Tags == nil && row.Notes == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
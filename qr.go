@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// qrPNG renders text as a QR code PNG with qrencode.
+func qrPNG(text string) ([]byte, error) {
+	if !hasCommand("qrencode") {
+		return nil, fmt.Errorf("QR codes need qrencode (e.g. brew install qrencode, apt install qrencode)")
+	}
+	cmd := exec.Command("qrencode", "-t", "PNG", "-s", "6", "-m", "2", "-o", "-", text)
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("qrencode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// printQR shows text as a QR code: as an inline image on terminals with a
+// graphics protocol, as block characters everywhere else.
+func printQR(w io.Writer, text string) error {
+	if caps.Graphics == "" {
+		if !hasCommand("qrencode") {
+			_, err := qrPNG(text)
+			return err
+		}
+		cmd := exec.Command("qrencode", "-t", "UTF8", "-m", "2", text)
+		cmd.Stdout = w
+		return cmd.Run()
+	}
+	png, err := qrPNG(text)
+	if err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(png)
+	switch caps.Graphics {
+	case graphicsITerm:
+		fmt.Fprintf(w, "\033]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a\n", len(png), data)
+	case graphicsKitty:
+		// The kitty protocol takes the payload in chunks of at most 4096.
+		for first := true; len(data) > 0; first = false {
+			n := min(len(data), 4096)
+			more := 0
+			if n < len(data) {
+				more = 1
+			}
+			ctrl := fmt.Sprintf("m=%d", more)
+			if first {
+				ctrl = "f=100,a=T," + ctrl
+			}
+			fmt.Fprintf(w, "\033_G%s;%s\033\\", ctrl, data[:n])
+			data = data[n:]
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
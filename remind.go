@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseWhen turns a review date given as YYYY-MM-DD or as an offset from
+// now (90d, 6w, 3m, 1y) into a date.
+func parseWhen(s string) (time.Time, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if len(s) >= 2 {
+		if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n > 0 {
+			now := time.Now()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+			switch s[len(s)-1] {
+			case 'd':
+				return today.AddDate(0, 0, n), nil
+			case 'w':
+				return today.AddDate(0, 0, 7*n), nil
+			case 'm':
+				return today.AddDate(0, n, 0), nil
+			case 'y':
+				return today.AddDate(n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or e.g. 30d, 6w, 3m, 1y)", s)
+}
+
+func doRemind(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg remind list | set <address> <when> | export [--out <file.ics>] | push"))
+	}
+	switch args[0] {
+	case "list":
+		remindList()
+	case "set":
+		if len(args) != 3 {
+			exitErr(fmt.Errorf("usage: ddg remind set <address> <YYYY-MM-DD|30d|6w|3m|1y|none>"))
+		}
+		remindSet(args[1], args[2])
+	case "export":
+		remindExport(args[1:])
+	case "push":
+		remindPush()
+	default:
+		exitErr(fmt.Errorf("usage: unknown remind command %s", args[0]))
+	}
+}
+
+// reminders returns the aliases with a review date, soonest first.
+func reminders() []historyEntry {
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var due []historyEntry
+	for _, e := range mergeHistory(entries, nil) {
+		if e.Review != nil {
+			due = append(due, e)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Review.Before(*due[j].Review) })
+	return due
+}
+
+func remindList() {
+	today := time.Now().Format("2006-01-02")
+	printList(reminders(), func(e historyEntry) string {
+		day := e.Review.Format("2006-01-02")
+		if day <= today {
+			day = red(day)
+		}
+		return fmt.Sprintf("%s  %s\t%s", day, cyan(e.Address), e.Label)
+	})
+}
+
+func remindSet(address, when string) {
+	requireWritable("change a review date")
+	var review *time.Time
+	if when != "none" {
+		t, err := parseWhen(when)
+		if err != nil {
+			exitErr(err)
+		}
+		review = &t
+	}
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	seen := historyAddresses(entries)
+	found := false
+	for i := range entries {
+		if strings.EqualFold(entries[i].Address, address) {
+			entries[i].Review = review
+			found = true
+		}
+	}
+	if !found {
+		exitErr(fmt.Errorf("%s is not in your history", address))
+	}
+	err = writeHistory(entries, seen)
+	audit("remind.set", err, map[string]string{"address": address, "review": when})
+	if err != nil {
+		exitErr(err)
+	}
+	if review == nil {
+		out.Done("Review date removed.", map[string]string{"address": address})
+		return
+	}
+	out.Done("Review on "+review.Format("2006-01-02")+".", map[string]string{"address": address, "review": review.Format("2006-01-02")})
+}
+
+func remindExport(args []string) {
+	outPath := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	due := reminders()
+	ics := formatICS(due, time.Now().UTC())
+	if outPath == "" {
+		os.Stdout.WriteString(ics)
+		return
+	}
+	if err := os.WriteFile(outPath, []byte(ics), 0600); err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Wrote %d reminders to %s; open it with your calendar app.", len(due), outPath),
+		map[string]interface{}{"file": outPath, "count": len(due)})
+}
+
+// formatICS renders review dates as all-day events with a morning alarm.
+// UIDs are stable, so re-importing updates events instead of doubling them.
+func formatICS(due []historyEntry, stamp time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		// Fold at 75 octets as RFC 5545 requires.
+		for len(s) > 75 {
+			cut := 75
+			for cut > 0 && s[cut]&0xC0 == 0x80 {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//duckduckgone//ddg " + version + "//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, e := range due {
+		line("BEGIN:VEVENT")
+		line("UID:review-" + strings.ToLower(e.Address) + "@duckduckgone")
+		line("DTSTAMP:" + stamp.Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + e.Review.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + e.Review.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsEscape(reminderTitle(e)))
+		line("DESCRIPTION:" + icsEscape(reminderBody(e)))
+		line("BEGIN:VALARM")
+		line("ACTION:DISPLAY")
+		line("DESCRIPTION:" + icsEscape(reminderTitle(e)))
+		line("TRIGGER:PT9H")
+		line("END:VALARM")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+func reminderTitle(e historyEntry) string {
+	if e.Label != "" {
+		return "Review alias for " + e.Label
+	}
+	return "Review alias " + e.Address
+}
+
+func reminderBody(e historyEntry) string {
+	s := e.Address + "\nCreated " + e.Created.Local().Format("2006-01-02")
+	if e.Notes != "" {
+		s += "\n" + e.Notes
+	}
+	return s + "\nStill needed? Keep it, or deactivate it on duckduckgo.com."
+}
+
+// remindPush adds every review date to the macOS Reminders app.
+func remindPush() {
+	if runtime.GOOS != "darwin" {
+		exitErr(fmt.Errorf("the Reminders app is only on macOS; use 'ddg remind export' for other calendars"))
+	}
+	due := reminders()
+	for _, e := range due {
+		cmd := exec.Command("osascript")
+		cmd.Stdin = bytes.NewBufferString(reminderScript(e))
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			exitErr(fmt.Errorf("osascript: %v: %s", err, strings.TrimSpace(string(msg))))
+		}
+	}
+	out.Done(fmt.Sprintf("Added %d reminders to the Reminders app.", len(due)), map[string]int{"count": len(due)})
+}
+
+// reminderScript builds the due date field by field; AppleScript's own
+// date parsing depends on the user's locale.
+func reminderScript(e historyEntry) string {
+	r := e.Review
+	return fmt.Sprintf(`set d to current date
+set day of d to 1
+set year of d to %d
+set month of d to %d
+set day of d to %d
+set time of d to 9 * hours
+tell application "Reminders"
+	make new reminder with properties {name:%s, body:%s, due date:d}
+end tell
+`, r.Year(), int(r.Month()), r.Day(), appleScriptString(reminderTitle(e)), appleScriptString(reminderBody(e)))
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
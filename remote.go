@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// doRemote generates an alias on another machine over SSH, for people who
+// keep their token on one trusted box: the token and history stay there and
+// only the address comes back, to this machine's clipboard.
+func doRemote(args []string) {
+	remoteDDG := orDefault(os.Getenv("DDG_REMOTE_COMMAND"), "ddg")
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--ddg" && i+1 < len(args) {
+			remoteDDG = args[i+1]
+			i++
+		} else {
+			rest = append(rest, args[i])
+		}
+	}
+	if len(rest) < 2 || (rest[1] != "gen" && rest[1] != "generate") {
+		exitErr(fmt.Errorf("usage: ddg remote [--ddg <path>] <[user@]host> gen [gen flags...]"))
+	}
+	if !hasCommand("ssh") {
+		exitErr(fmt.Errorf("ddg remote needs ssh on your PATH"))
+	}
+	host := rest[0]
+
+	// ssh hands the remote shell one string, so quote every word.
+	words := []string{remoteDDG, "-o", "json", "gen"}
+	for _, a := range rest[2:] {
+		if a == "--qr" {
+			continue // drawn on this terminal instead
+		}
+		words = append(words, a)
+	}
+	for i := range words[1:] {
+		words[i+1] = shellQuote(words[i+1])
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command("ssh", host, "--", strings.Join(words, " "))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &stdout, os.Stderr
+	err := cmd.Run()
+	var res genResult
+	if err == nil {
+		if err = json.Unmarshal(stdout.Bytes(), &res); err == nil && res.Address == "" {
+			err = fmt.Errorf("no address in the output")
+		}
+		if err != nil {
+			err = fmt.Errorf("unexpected output from ddg on %s (%v): %s", host, err, strings.TrimSpace(stdout.String()))
+		}
+	}
+	audit("remote.generate", err, map[string]string{"host": host, "address": res.Address, "label": res.Label})
+	if err != nil {
+		exitErr(fmt.Errorf("ssh %s: %w", host, err))
+	}
+
+	showForwarded(res, host, hasTag(rest[2:], "--qr"), "")
+}
+
+// showForwarded prints an alias generated on another machine. That machine
+// can't reach this clipboard, so it's copied here, unless the local config
+// (which may not exist at all) turns copying off. clear is gen's
+// --clear-after, if given.
+func showForwarded(res genResult, where string, showQR bool, clear string) {
+	res.Clipboard, res.ClearAfter = "", 0
+	human := cyan(hyperlink("mailto:"+res.Address, res.Address))
+	if cfg, _ := readConfig(); !strings.EqualFold(cfg.Clipboard, "no") {
+		human += copyGenerated(cfg, &res, clearAfter(cfg, clear))
+	}
+	out.Result(res, human+"\n(generated on "+where+")")
+	if showQR {
+		if err := printQR(os.Stderr, "mailto:"+res.Address); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
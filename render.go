@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Output formats selectable with --output.
+const (
+	outputPlain    = "plain"
+	outputColor    = "color"
+	outputJSON     = "json"
+	outputJSONL    = "jsonl"
+	outputTemplate = "template"
+	outputVim      = "vim"
+	outputQuiet    = "quiet" // -q: the bare value of each result, for $(ddg gen)
+)
+
+// plainer is implemented by results with one obvious bare value, such as
+// the address of a generated alias.
+type plainer interface {
+	plain() string
+}
+
+// printer is the single place command results are written. Commands hand it
+// a value plus the human-readable rendering of that value; the printer picks
+// which one to emit, so every command gets every output format for free.
+type printer struct {
+	format  string
+	tmpl    *template.Template
+	w       io.Writer
+	pending []interface{} // items buffered for a single JSON array
+
+	vimCount int // items written in vim format
+}
+
+var out = &printer{format: outputColor, w: os.Stdout}
+
+// setOutput configures the printer from the --output and --template flags.
+// An empty format means color when the terminal supports it, plain otherwise.
+func setOutput(format, tmpl string) error {
+	if format == "" && tmpl != "" {
+		format = outputTemplate
+	}
+	switch format {
+	case "":
+		if caps.Color {
+			format = outputColor
+		} else {
+			format = outputPlain
+		}
+	case outputPlain:
+		caps.Color = false
+		caps.Hyperlinks, caps.Graphics = false, ""
+	case outputColor:
+		caps.Color = true
+	case outputJSON, outputJSONL:
+		caps.Color = false
+		caps.Hyperlinks, caps.Graphics = false, ""
+	case outputTemplate:
+		if tmpl == "" {
+			return fmt.Errorf("--output template needs --template '<go template>'")
+		}
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("invalid --template: %w", err)
+		}
+		out.tmpl = t
+		caps.Color = false
+		caps.Hyperlinks, caps.Graphics = false, ""
+	case outputVim:
+		caps.Color = false
+		caps.Emoji = false
+		caps.Hyperlinks, caps.Graphics = false, ""
+	case outputQuiet:
+		caps.Color = false
+		caps.Hyperlinks, caps.Graphics = false, ""
+	default:
+		return fmt.Errorf("unknown output format %q (use plain, color, json, jsonl, template, vim or quiet)", format)
+	}
+	out.format = format
+	return nil
+}
+
+// machine reports whether output is meant for programs rather than people.
+func (p *printer) machine() bool {
+	return p.format == outputJSON || p.format == outputJSONL || p.format == outputTemplate || p.format == outputVim || p.format == outputQuiet
+}
+
+// Result prints a single result.
+func (p *printer) Result(v interface{}, human string) {
+	p.Item(v, human)
+	p.Flush()
+}
+
+// Item prints one element of a list as soon as it is available. In JSON mode
+// items are collected and written as one array by Flush.
+func (p *printer) Item(v interface{}, human string) {
+	switch p.format {
+	case outputJSON:
+		p.pending = append(p.pending, v)
+	case outputJSONL:
+		b, err := json.Marshal(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		fmt.Fprintln(p.w, string(b))
+	case outputTemplate:
+		var buf bytes.Buffer
+		if err := p.tmpl.Execute(&buf, v); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: template: %v\n", err)
+			return
+		}
+		fmt.Fprintln(p.w, strings.TrimRight(buf.String(), "\n"))
+	case outputVim:
+		// For editors inserting at the cursor: the bare value, no newline
+		// after a single result, one per line for lists.
+		s := strings.SplitN(human, "\n", 2)[0]
+		if pl, ok := v.(plainer); ok {
+			s = pl.plain()
+		}
+		if p.vimCount > 0 {
+			fmt.Fprintln(p.w)
+		}
+		fmt.Fprint(p.w, s)
+		p.vimCount++
+	case outputQuiet:
+		// Like vim, but every value ends its line, as shell scripts expect.
+		s := strings.SplitN(human, "\n", 2)[0]
+		if pl, ok := v.(plainer); ok {
+			s = pl.plain()
+		}
+		fmt.Fprintln(p.w, s)
+	default:
+		if human != "" {
+			fmt.Fprintln(p.w, human)
+		}
+	}
+}
+
+// Flush writes items buffered by Item. A single item is written as an
+// object, anything else as an array.
+func (p *printer) Flush() {
+	if p.format != outputJSON {
+		return
+	}
+	var v interface{} = p.pending
+	if len(p.pending) == 1 {
+		v = p.pending[0]
+	} else if p.pending == nil {
+		v = []interface{}{}
+	}
+	p.pending = nil
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintln(p.w, string(b))
+}
+
+// printList prints every item and flushes; an empty list is [] in JSON mode.
+func printList[T any](items []T, human func(T) string) {
+	if out.format == outputJSON {
+		all := make([]interface{}, 0, len(items))
+		for _, it := range items {
+			all = append(all, it)
+		}
+		b, err := json.MarshalIndent(all, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		fmt.Fprintln(out.w, string(b))
+		return
+	}
+	for _, it := range items {
+		out.Item(it, human(it))
+	}
+}
+
+// Done confirms a completed action: msg for people, v for programs.
+func (p *printer) Done(msg string, v interface{}) {
+	p.Result(v, sym("✅")+" "+msg)
+}
+
+// Info prints progress chatter. It goes to stderr in machine formats so it
+// never corrupts the data on stdout.
+func (p *printer) Info(format string, args ...interface{}) {
+	w := p.w
+	if p.machine() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+}
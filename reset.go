@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resetTargets are what 'ddg reset' can clear. --all (the default) is
+// settings, token and history together.
+type resetTargets struct {
+	settings, token, history bool
+}
+
+func (t resetTargets) all() bool { return t.settings && t.token && t.history }
+
+func (t resetTargets) names() []string {
+	var n []string
+	if t.settings {
+		n = append(n, "settings")
+	}
+	if t.token {
+		n = append(n, "token")
+	}
+	if t.history {
+		n = append(n, "history")
+	}
+	return n
+}
+
+func doReset(args []string) {
+	var t resetTargets
+	for _, a := range args {
+		switch a {
+		case "--settings":
+			t.settings = true
+		case "--token":
+			t.token = true
+		case "--history":
+			t.history = true
+		case "--all":
+			t = resetTargets{true, true, true}
+		default:
+			unknownArg(a)
+		}
+	}
+	if t == (resetTargets{}) {
+		t = resetTargets{true, true, true}
+	}
+	requireWritable("reset the application")
+	details := map[string]string{"targets": strings.Join(t.names(), ",")}
+
+	if !confirmReset(t) {
+		audit("reset", errCancelled, details)
+		fmt.Println(sym("❌") + " Reset cancelled.")
+		return
+	}
+
+	// Reset is a way out of a damaged config, so take whatever parses.
+	cfg, err := readConfig()
+	var broken *configError
+	if err != nil && !os.IsNotExist(err) && !errors.As(err, &broken) {
+		exitErr(err)
+	}
+	confFile, err := confPath()
+	if err != nil {
+		exitErr(err)
+	}
+	histFile, err := historyPath()
+	if err != nil {
+		exitErr(err)
+	}
+	id, err := saveToTrash("reset "+strings.Join(t.names(), "+"), confFile, histFile)
+	if err != nil {
+		audit("reset", err, details)
+		exitErr(fmt.Errorf("saving the current files to the trash: %w", err))
+	}
+	details["trash"] = id
+
+	err = applyReset(t, cfg, histFile)
+	audit("reset", err, details)
+	if err != nil {
+		exitErr(err)
+	}
+	msg := "Application reset. Run 'ddg' again to set up."
+	if !t.all() {
+		msg = "Reset " + strings.Join(t.names(), " and ") + "."
+	}
+	out.Done(msg+" 'ddg restore-trash "+id+"' undoes it.",
+		map[string]interface{}{"reset": t.names(), "trash": id})
+}
+
+// confirmReset asks once per target; a full reset is critical.
+func confirmReset(t resetTargets) bool {
+	yes := func(question string) bool {
+		return confirm(confirmation{Question: question, Risk: riskHigh})
+	}
+	if t.all() {
+		return confirm(confirmation{Question: "Are you sure you want to completely reset this application?", Risk: riskCritical, Word: "Reset"})
+	}
+	if t.settings && !yes("Reset all settings to their defaults? The API key is kept.") {
+		return false
+	}
+	if t.token && !yes("Remove the API key (from the keychain too, where it can't be restored)?") {
+		return false
+	}
+	if t.history && !yes("Clear the alias history?") {
+		return false
+	}
+	return true
+}
+
+// applyReset clears the chosen targets. Settings are rewritten from
+// scratch rather than edited, so no stale keys survive in the file.
+func applyReset(t resetTargets, cfg conf, histFile string) error {
+	if t.token {
+		_ = setState(stateAccount, "")
+		_ = setState(stateToken, "")
+		if name := keystoreName(cfg); name != keystoreFile {
+			if ks, err := openKeystore(name); err == nil {
+				if err := ks.Delete(); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: could not remove API key from %s: %v\n", name, err)
+				}
+			}
+		}
+	}
+	if t.history {
+		if err := os.Remove(histFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	switch {
+	case t.all():
+		return writeConfig(conf{SetupComplete: "false"})
+	case t.settings:
+		fresh := conf{Clipboard: defaultClip, DDGGen: defaultDDGGen, SetupComplete: cfg.SetupComplete}
+		if !t.token {
+			fresh.APIKey, fresh.Keystore = cfg.APIKey, cfg.Keystore
+		}
+		return writeConfig(fresh)
+	case t.token:
+		cfg.APIKey, cfg.Keystore = "", ""
+		return writeConfig(cfg)
+	}
+	return nil
+}
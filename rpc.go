@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rpcSchemaVersion changes only when a method's params or result change
+// incompatibly; extensions can check it in the initialize result.
+const rpcSchemaVersion = 1
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Method params and results.
+type (
+	rpcGenerateParams struct {
+		Label string   `json:"label,omitempty"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	rpcGenerateResult struct {
+		Address string    `json:"address"`
+		Label   string    `json:"label,omitempty"`
+		Created time.Time `json:"created"`
+	}
+	rpcSearchParams struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit,omitempty"`
+	}
+	rpcSearchResult struct {
+		Address string    `json:"address"`
+		Label   string    `json:"label,omitempty"`
+		Tags    []string  `json:"tags,omitempty"`
+		Created time.Time `json:"created"`
+	}
+	rpcInitializeResult struct {
+		Version string   `json:"version"`
+		Schema  int      `json:"schema"`
+		Methods []string `json:"methods"`
+	}
+)
+
+// doRPC serves JSON-RPC 2.0 on stdin/stdout until stdin closes or "exit" is
+// called, as a backend for editor extensions. Messages are either one JSON
+// object per line or framed with LSP-style Content-Length headers; replies
+// use whichever framing the first message did.
+func doRPC() {
+	r := bufio.NewReader(os.Stdin)
+	w := &rpcWriter{w: os.Stdout}
+	var cfg *conf
+	for {
+		msg, framed, err := readRPCMessage(r)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			exitErr(err)
+		}
+		w.framed = framed
+		if len(strings.TrimSpace(string(msg))) == 0 {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			w.send(rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			continue
+		}
+		if req.Method == "exit" {
+			return
+		}
+		result, rerr := handleRPC(req, &cfg)
+		if len(req.ID) == 0 {
+			continue // notification
+		}
+		resp := rpcResponse{ID: req.ID, Result: result, Error: rerr}
+		if rerr == nil && result == nil {
+			resp.Result = struct{}{}
+		}
+		w.send(resp)
+	}
+}
+
+func handleRPC(req rpcRequest, cfg **conf) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize", "version":
+		return rpcInitializeResult{Version: version, Schema: rpcSchemaVersion, Methods: []string{"initialize", "generate", "search", "shutdown", "exit"}}, nil
+	case "shutdown":
+		return nil, nil
+	case "generate":
+		var p rpcGenerateParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		if isReadOnly() {
+			return nil, &rpcError{Code: rpcServerError, Message: "read-only mode"}
+		}
+		if *cfg == nil {
+			c, err := ensureConfig(false)
+			if err != nil {
+				return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+			}
+			*cfg = &c
+		}
+		e, err := generateAlias(**cfg, historyEntry{Label: p.Label, Tags: p.Tags})
+		if err != nil {
+			return nil, rpcAppError(err)
+		}
+		return rpcGenerateResult{Address: e.Address, Label: e.Label, Created: e.Created}, nil
+	case "search":
+		var p rpcSearchParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		entries, err := liveHistory()
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		q := strings.ToLower(p.Query)
+		res := []rpcSearchResult{}
+		entries = mergeHistory(entries, nil)
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if q != "" && !strings.Contains(strings.ToLower(e.Address+" "+e.Label+" "+strings.Join(e.Tags, " ")), q) {
+				continue
+			}
+			res = append(res, rpcSearchResult{Address: e.Address, Label: e.Label, Tags: e.Tags, Created: e.Created})
+			if p.Limit > 0 && len(res) >= p.Limit {
+				break
+			}
+		}
+		return res, nil
+	case "":
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "missing method"}
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method}
+	}
+}
+
+// rpcAppError maps a generation failure, keeping the HTTP status so an
+// extension can tell a bad token (401) from a rate limit (429).
+func rpcAppError(err error) *rpcError {
+	e := &rpcError{Code: rpcServerError, Message: err.Error()}
+	var apiErr *httpError
+	if errors.As(err, &apiErr) {
+		e.Data = map[string]int{"status": apiErr.StatusCode}
+	}
+	return e
+}
+
+// readRPCMessage reads one message, either Content-Length framed or a line.
+func readRPCMessage(r *bufio.Reader) ([]byte, bool, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, false, err
+	}
+	if !strings.HasPrefix(strings.ToLower(line), "content-length:") {
+		return []byte(line), false, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+	if err != nil || n < 0 || n > 1<<20 {
+		return nil, true, fmt.Errorf("bad Content-Length header: %q", strings.TrimSpace(line))
+	}
+	// Skip any other headers up to the blank line.
+	for {
+		h, err := r.ReadString('\n')
+		if err != nil {
+			return nil, true, err
+		}
+		if strings.TrimSpace(h) == "" {
+			break
+		}
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return buf, true, err
+}
+
+type rpcWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	framed bool
+}
+
+func (w *rpcWriter) send(resp rpcResponse) {
+	resp.JSONRPC = "2.0"
+	b, err := json.Marshal(resp)
+	if err != nil {
+		b, _ = json.Marshal(rpcResponse{JSONRPC: "2.0", ID: resp.ID, Error: &rpcError{Code: rpcServerError, Message: err.Error()}})
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.framed {
+		fmt.Fprintf(w.w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+		return
+	}
+	fmt.Fprintf(w.w, "%s\n", b)
+}
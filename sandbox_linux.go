@@ -0,0 +1,133 @@
+//go:build linux && !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock, from linux/landlock.h. The syscall numbers are the same on
+// every architecture Go supports.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+
+	llExecute    = 1 << 0
+	llWriteFile  = 1 << 1
+	llReadFile   = 1 << 2
+	llReadDir    = 1 << 3
+	llRefer      = 1 << 13 // ABI 2
+	llTruncate   = 1 << 14 // ABI 3
+	llFileRights = llExecute | llWriteFile | llReadFile | llTruncate
+
+	oPath         = 0x200000 // O_PATH, which package syscall doesn't define
+	prSetNoNewPrv = 38
+	capVersion3   = 0x20080522
+)
+
+type landlockPathBeneath struct {
+	allowed  uint64
+	parentFD int32
+	_        [4]byte // the kernel's struct is packed; it reads 12 bytes
+}
+
+// sandbox confines the daemon once it is listening: no new privileges (so
+// no setuid helpers), no capabilities, and with Landlock (Linux 5.13+) a
+// filesystem that's read-only outside ddg's own directories. Hooks and
+// helpers it runs inherit all of that; 'ddg serve --no-sandbox' is for
+// hooks that need more. It reports what it managed to apply.
+func sandbox(cfg conf) (string, error) {
+	// Each of these is per thread, and the Go runtime already has several.
+	if _, _, e := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrv, 1, 0); e != 0 {
+		if e == syscall.ENOTSUP {
+			return "", fmt.Errorf("this build links cgo, which can't restrict every thread; build with CGO_ENABLED=0")
+		}
+		return "", fmt.Errorf("no_new_privs: %w", e)
+	}
+	hdr := struct{ version, pid uint32 }{capVersion3, 0}
+	var none [2]struct{ effective, permitted, inheritable uint32 }
+	if _, _, e := syscall.AllThreadsSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&none[0])), 0); e != 0 {
+		return "", fmt.Errorf("dropping capabilities: %w", e)
+	}
+
+	abi, _, e := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if e != 0 {
+		return "no new privileges, no capabilities (Landlock unavailable)", nil
+	}
+	handled := uint64(1<<13 - 1) // every ABI 1 right
+	if abi >= 2 {
+		handled |= llRefer
+	}
+	if abi >= 3 {
+		handled |= llTruncate
+	}
+	attr := struct{ handledFS uint64 }{handled}
+	fd, _, e := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if e != 0 {
+		return "", fmt.Errorf("landlock: %w", e)
+	}
+	defer syscall.Close(int(fd))
+
+	readExec := uint64(llExecute | llReadFile | llReadDir)
+	rules := map[string]uint64{}
+	// The system: binaries and libraries for hooks and helpers, TLS roots,
+	// resolver configuration.
+	for _, p := range []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc", "/opt", "/nix", "/run", "/proc", "/sys"} {
+		rules[p] = readExec
+	}
+	rules["/dev"] = readExec | llWriteFile
+	rules[os.TempDir()] = handled
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	rules[filepath.Join(home, dataDirName)] = handled
+	if path, err := confPath(); err == nil {
+		rules[path] = llReadFile
+	}
+	if cfg.TeamDir != "" {
+		rules[cfg.TeamDir] = handled
+	}
+	for path, allowed := range rules {
+		if err := landlockAllow(int(fd), path, allowed&handled); err != nil {
+			return "", err
+		}
+	}
+	if _, _, e := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); e != 0 {
+		return "", fmt.Errorf("landlock: %w", e)
+	}
+	return fmt.Sprintf("no new privileges, no capabilities, Landlock ABI %d", abi), nil
+}
+
+// landlockAllow grants allowed beneath path; paths that don't exist here
+// are skipped.
+func landlockAllow(ruleset int, path string, allowed uint64) error {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		allowed &= llFileRights
+	}
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("landlock: %s: %w", path, err)
+	}
+	defer syscall.Close(fd)
+	rule := landlockPathBeneath{allowed: allowed, parentFD: int32(fd)}
+	if _, _, e := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); e != 0 {
+		return fmt.Errorf("landlock: %s: %w", path, e)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !minimal
+
+package main
+
+// sandbox has nothing to apply outside Linux. macOS's App Sandbox comes
+// from entitlements in a signed app bundle, which a command-line binary
+// doesn't have, and OpenBSD's pledge/unveil need x/sys.
+func sandbox(cfg conf) (string, error) { return "", nil }
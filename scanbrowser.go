@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// scan-browser reads Chrome's and Firefox's saved logins in place for
+// usernames that are Duck aliases ddg doesn't know about. Only usernames
+// and sites are read: Chrome stores usernames in the clear, and in Firefox
+// only the usernames are decrypted, never the passwords.
+const (
+	chromeLogins  = "Login Data"
+	firefoxLogins = "logins.json"
+	firefoxKeys   = "key4.db"
+)
+
+type browserLogin struct {
+	Address string    `json:"address"`
+	Site    string    `json:"site"`
+	Browser string    `json:"browser"`
+	Profile string    `json:"profile"`
+	Created time.Time `json:"created,omitempty"`
+}
+
+func doScanBrowser(args []string) {
+	var profiles []string
+	for i := 0; i < len(args); i++ {
+		// Not --profile: that's ddg's own, taken by parseGlobalFlags.
+		if args[i] == "--browser-profile" && i+1 < len(args) {
+			profiles = append(profiles, args[i+1])
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if len(profiles) == 0 {
+		profiles = browserProfiles()
+		if len(profiles) == 0 {
+			exitErr(fmt.Errorf("no Chrome, Chromium or Firefox profiles found; pass --browser-profile <dir>"))
+		}
+	}
+
+	if !yesAssumed(riskLow) {
+		out.Info("ddg will read the saved logins in:")
+		for _, p := range profiles {
+			out.Info("  %s", p)
+		}
+		if !confirm(confirmation{Question: "Only usernames and sites are read, and nothing leaves this machine. Go ahead?"}) {
+			audit("scan-browser", errCancelled, nil)
+			fmt.Println(sym("❌") + " Scan cancelled.")
+			return
+		}
+	}
+
+	var found []browserLogin
+	for _, p := range profiles {
+		logins, err := scanProfile(p)
+		if err != nil {
+			exitErr(fmt.Errorf("%s: %w", p, err))
+		}
+		found = append(found, logins...)
+	}
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	inHistory := historyAddresses(entries)
+	var missing []browserLogin
+	seen := map[string]bool{}
+	for _, l := range found {
+		if _, ok := findHistory(entries, l.Address); !ok && !seen[l.Address+"\x00"+l.Site] {
+			seen[l.Address+"\x00"+l.Site] = true
+			missing = append(missing, l)
+		}
+	}
+	audit("scan-browser", nil, map[string]string{"profiles": strconv.Itoa(len(profiles)), "found": strconv.Itoa(len(found)), "missing": strconv.Itoa(len(missing))})
+	if len(missing) == 0 {
+		out.Done(fmt.Sprintf("Found %d saved logins with a Duck alias, all already in history.", len(found)),
+			map[string]interface{}{"found": len(found), "missing": []browserLogin{}})
+		return
+	}
+	line := func(l browserLogin) string {
+		return fmt.Sprintf("  %s  %s (%s)", cyan(l.Address), orDefault(importLabel(l.Site), l.Site), l.Browser)
+	}
+	// Programs get the list, and an import only when asked for one.
+	if out.machine() && !yesAssumed(riskLow) {
+		printList(missing, line)
+		return
+	}
+	if !out.machine() {
+		fmt.Printf("%d aliases in saved logins aren't in history:\n", len(missing))
+		for _, l := range missing {
+			fmt.Println(line(l))
+		}
+	}
+	if !confirm(confirmation{Question: "Add them to history?"}) {
+		fmt.Println("Nothing imported.")
+		return
+	}
+	requireWritable("rewrite history")
+	var add []historyEntry
+	now := time.Now().UTC()
+	for _, l := range missing {
+		label := importLabel(l.Site)
+		if l.Created.IsZero() {
+			l.Created = now
+		}
+		add = append(add, historyEntry{Address: l.Address, Created: l.Created, Label: label,
+			Notes: "imported from " + l.Browser + " (" + orDefault(label, l.Site) + ")"})
+	}
+	before := len(mergeHistory(entries, nil))
+	merged := mergeHistory(entries, add)
+	err = writeHistory(merged, inHistory)
+	audit("history.import", err, map[string]string{"format": "browser", "found": strconv.Itoa(len(add)), "added": strconv.Itoa(len(merged) - before)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Added %d aliases to history.", len(merged)-before),
+		map[string]interface{}{"added": len(merged) - before, "aliases": missing})
+}
+
+// browserProfiles finds Chrome, Chromium and Firefox profiles in their
+// default places.
+func browserProfiles() []string {
+	home, _ := os.UserHomeDir()
+	var chrome, firefox []string
+	switch runtime.GOOS {
+	case "darwin":
+		support := filepath.Join(home, "Library", "Application Support")
+		chrome = []string{filepath.Join(support, "Google", "Chrome"), filepath.Join(support, "Chromium")}
+		firefox = []string{filepath.Join(support, "Firefox", "Profiles")}
+	case "windows":
+		local, roaming := os.Getenv("LOCALAPPDATA"), os.Getenv("APPDATA")
+		chrome = []string{filepath.Join(local, "Google", "Chrome", "User Data"), filepath.Join(local, "Chromium", "User Data")}
+		firefox = []string{filepath.Join(roaming, "Mozilla", "Firefox", "Profiles")}
+	default:
+		config := filepath.Join(home, ".config")
+		if x := os.Getenv("XDG_CONFIG_HOME"); x != "" {
+			config = x
+		}
+		chrome = []string{filepath.Join(config, "google-chrome"), filepath.Join(config, "chromium")}
+		firefox = []string{filepath.Join(home, ".mozilla", "firefox")}
+	}
+	var out []string
+	for _, dir := range chrome {
+		m, _ := filepath.Glob(filepath.Join(dir, "*", chromeLogins))
+		for _, f := range m {
+			out = append(out, filepath.Dir(f))
+		}
+	}
+	for _, dir := range firefox {
+		m, _ := filepath.Glob(filepath.Join(dir, "*", firefoxLogins))
+		for _, f := range m {
+			out = append(out, filepath.Dir(f))
+		}
+	}
+	return out
+}
+
+func scanProfile(dir string) ([]browserLogin, error) {
+	if _, err := os.Stat(filepath.Join(dir, chromeLogins)); err == nil {
+		return scanChrome(dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, firefoxLogins)); err == nil {
+		return scanFirefox(dir)
+	}
+	return nil, fmt.Errorf("neither a Chrome (%s) nor a Firefox (%s) profile", chromeLogins, firefoxLogins)
+}
+
+// Chrome's timestamps count microseconds from 1601, this many before 1970.
+const chromeEpochOffset = 11644473600 * 1000000
+
+func scanChrome(dir string) ([]browserLogin, error) {
+	db, err := openSQLite(filepath.Join(dir, chromeLogins))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.rows("logins")
+	if err != nil {
+		return nil, err
+	}
+	var out []browserLogin
+	for _, r := range rows {
+		user, _ := r["username_value"].(string)
+		addr := ddg.NormalizeAlias(user)
+		if !ddg.IsAlias(addr) {
+			continue
+		}
+		site, _ := r["origin_url"].(string)
+		l := browserLogin{Address: addr, Site: site, Browser: "Chrome", Profile: dir}
+		if us, ok := r["date_created"].(int64); ok && us > chromeEpochOffset {
+			l.Created = time.UnixMicro(us - chromeEpochOffset).UTC()
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func scanFirefox(dir string) ([]browserLogin, error) {
+	b, err := os.ReadFile(filepath.Join(dir, firefoxLogins))
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Logins []struct {
+			Hostname          string `json:"hostname"`
+			EncryptedUsername string `json:"encryptedUsername"`
+			TimeCreated       int64  `json:"timeCreated"`
+		} `json:"logins"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", firefoxLogins, err)
+	}
+	if len(file.Logins) == 0 {
+		return nil, nil
+	}
+	key, err := firefoxKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []browserLogin
+	for _, l := range file.Logins {
+		user, err := firefoxDecrypt(key, l.EncryptedUsername)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting a username: %w", err)
+		}
+		addr := ddg.NormalizeAlias(user)
+		if !ddg.IsAlias(addr) {
+			continue
+		}
+		bl := browserLogin{Address: addr, Site: l.Hostname, Browser: "Firefox", Profile: dir}
+		if l.TimeCreated > 0 {
+			bl.Created = time.UnixMilli(l.TimeCreated).UTC()
+		}
+		out = append(out, bl)
+	}
+	return out, nil
+}
+
+// Firefox keeps its login key in key4.db, encrypted under the primary
+// password (empty unless the user set one) with PBES2.
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+
+	errPrimaryPassword = errors.New("wrong primary password")
+	passwordCheck      = []byte("password-check")
+)
+
+type nssPBE struct {
+	Algo struct {
+		OID    asn1.ObjectIdentifier
+		Params asn1.RawValue
+	}
+	Data []byte
+}
+
+type nssPBES2Params struct {
+	KDF struct {
+		OID    asn1.ObjectIdentifier
+		Params struct {
+			Salt       []byte
+			Iterations int
+			KeyLength  int `asn1:"optional"`
+			PRF        struct {
+				OID    asn1.ObjectIdentifier
+				Params asn1.RawValue `asn1:"optional"`
+			} `asn1:"optional"`
+		}
+	}
+	Cipher struct {
+		OID asn1.ObjectIdentifier
+		IV  []byte
+	}
+}
+
+// nssLogin is how a logins.json field is encrypted with the login key.
+type nssLogin struct {
+	KeyID []byte
+	Algo  struct {
+		OID asn1.ObjectIdentifier
+		IV  []byte
+	}
+	Data []byte
+}
+
+func firefoxKey(dir string) ([]byte, error) {
+	db, err := openSQLite(filepath.Join(dir, firefoxKeys))
+	if err != nil {
+		return nil, err
+	}
+	meta, err := db.rows("metaData")
+	if err != nil {
+		return nil, err
+	}
+	var salt, check []byte
+	for _, r := range meta {
+		if r["id"] == "password" {
+			salt, _ = r["item1"].([]byte)
+			check, _ = r["item2"].([]byte)
+		}
+	}
+	if salt == nil || check == nil {
+		return nil, fmt.Errorf("%s has no password entry", firefoxKeys)
+	}
+
+	password := ""
+	if ok, err := nssCheck(salt, password, check); err != nil {
+		return nil, err
+	} else if !ok {
+		password = ask(prompt{Label: "Firefox primary password", Secret: true, Env: "DDG_FIREFOX_PASSWORD"})
+		if ok, err = nssCheck(salt, password, check); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errPrimaryPassword
+		}
+	}
+
+	keys, err := db.rows("nssPrivate")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range keys {
+		enc, _ := r["a11"].([]byte)
+		if key, err := nssDecryptPBE(salt, password, enc); err == nil && len(key) >= 24 {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no usable login key in %s", firefoxKeys)
+}
+
+func nssCheck(salt []byte, password string, check []byte) (bool, error) {
+	plain, err := nssDecryptPBE(salt, password, check)
+	if errors.Is(err, errPrimaryPassword) {
+		return false, nil
+	}
+	return err == nil && bytes.HasPrefix(plain, passwordCheck), err
+}
+
+func nssDecryptPBE(globalSalt []byte, password string, der []byte) ([]byte, error) {
+	var pbe nssPBE
+	if _, err := asn1.Unmarshal(der, &pbe); err != nil {
+		return nil, err
+	}
+	if !pbe.Algo.OID.Equal(oidPBES2) {
+		return nil, fmt.Errorf("%s uses an older key format (%v); open the profile in a current Firefox first", firefoxKeys, pbe.Algo.OID)
+	}
+	var p nssPBES2Params
+	if _, err := asn1.Unmarshal(pbe.Algo.Params.FullBytes, &p); err != nil {
+		return nil, err
+	}
+	if !p.KDF.OID.Equal(oidPBKDF2) || !p.Cipher.OID.Equal(oidAES256CBC) ||
+		(len(p.KDF.Params.PRF.OID) > 0 && !p.KDF.Params.PRF.OID.Equal(oidHMACSHA256)) {
+		return nil, fmt.Errorf("unsupported key encryption in %s", firefoxKeys)
+	}
+	keyLen := p.KDF.Params.KeyLength
+	if keyLen == 0 {
+		keyLen = 32
+	}
+	pw := sha1.Sum(append(append([]byte{}, globalSalt...), password...))
+	key := pbkdf2SHA256(pw[:], p.KDF.Params.Salt, p.KDF.Params.Iterations, keyLen)
+	iv := p.Cipher.IV
+	if len(iv) == 14 {
+		// NSS keeps the IV's own DER header as its first two bytes.
+		iv = append([]byte{0x04, 0x0e}, iv...)
+	}
+	plain, err := cbcDecrypt(aes.NewCipher, key, iv, pbe.Data)
+	if err != nil {
+		// With the wrong password, the padding is what doesn't check out.
+		return nil, errPrimaryPassword
+	}
+	return plain, nil
+}
+
+func firefoxDecrypt(key []byte, b64 string) (string, error) {
+	der, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", err
+	}
+	var l nssLogin
+	if _, err := asn1.Unmarshal(der, &l); err != nil {
+		return "", err
+	}
+	var plain []byte
+	switch {
+	case l.Algo.OID.Equal(oidDESEDE3CBC):
+		plain, err = cbcDecrypt(des.NewTripleDESCipher, key[:24], l.Algo.IV, l.Data)
+	case l.Algo.OID.Equal(oidAES256CBC) && len(key) >= 32:
+		plain, err = cbcDecrypt(aes.NewCipher, key[:32], l.Algo.IV, l.Data)
+	default:
+		return "", fmt.Errorf("unsupported cipher %v", l.Algo.OID)
+	}
+	return string(plain), err
+}
+
+// cbcDecrypt decrypts and unpads (PKCS #7) data.
+func cbcDecrypt(newCipher func([]byte) (cipher.Block, error), key, iv, data []byte) ([]byte, error) {
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(iv) != bs || len(data) == 0 || len(data)%bs != 0 {
+		return nil, errors.New("malformed ciphertext")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > bs || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("bad padding")
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var out []byte
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
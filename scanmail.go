@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// scan-mail reads the headers of a local mailbox to see which aliases
+// actually get mail and from whom, and notes it on their history entries
+// as the fields below. Bodies are never read.
+const (
+	fieldLastSeen   = "last_seen"
+	fieldMailCount  = "mail_count"
+	fieldTopSenders = "top_senders" // space-separated, most frequent first
+
+	topSenders = 3
+
+	// mailHeaderMax bounds how much of one message is read looking for the
+	// end of its headers.
+	mailHeaderMax = 256 << 10
+)
+
+// duckReplyAddr matches the From address Duck rewrites forwarded mail to,
+// which encodes the sender and the alias: sender_at_example.com_alias@duck.com.
+var duckReplyAddr = regexp.MustCompile(`^(.+)_at_([^_]+)_([a-z0-9-]+)@duck\.com$`)
+
+// recipientHeaders are where an alias shows up on mail sent to it, in the
+// canonical form net/mail keys headers by.
+var recipientHeaders = []string{"To", "Cc", "Delivered-To", "X-Original-To", "Envelope-To"}
+
+type aliasMail struct {
+	Address  string         `json:"address"`
+	Label    string         `json:"label,omitempty"`
+	Known    bool           `json:"in_history"`
+	Count    int            `json:"messages"`
+	LastSeen time.Time      `json:"last_seen"`
+	Senders  map[string]int `json:"-"`
+	Top      []string       `json:"top_senders"`
+}
+
+func doScanMail(args []string) {
+	path, dryRun := "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--dry-run" {
+			dryRun = true
+		} else if path == "" && !strings.HasPrefix(args[i], "--") {
+			path = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if path == "" {
+		exitErr(fmt.Errorf("usage: ddg scan-mail <maildir|mbox> [--dry-run]"))
+	}
+	if !dryRun {
+		requireWritable("annotate history")
+	}
+
+	byAlias := map[string]*aliasMail{}
+	seen := func(h mail.Header, fallback time.Time) {
+		date, err := h.Date()
+		if err != nil {
+			date = fallback
+		}
+		sender, aliases := mailParties(h)
+		for _, a := range aliases {
+			m := byAlias[a]
+			if m == nil {
+				m = &aliasMail{Address: a, Senders: map[string]int{}}
+				byAlias[a] = m
+			}
+			m.Count++
+			if date.After(m.LastSeen) {
+				m.LastSeen = date
+			}
+			if sender != "" {
+				m.Senders[sender]++
+			}
+		}
+	}
+	n, err := readMailbox(path, seen)
+	if err != nil {
+		exitErr(err)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	inHistory := historyAddresses(entries)
+	var found []*aliasMail
+	for _, m := range byAlias {
+		m.Top = topKeys(m.Senders, topSenders)
+		if e, ok := findHistory(entries, m.Address); ok {
+			m.Known, m.Label = true, e.Label
+		}
+		found = append(found, m)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].LastSeen.After(found[j].LastSeen) })
+
+	annotated := 0
+	if !dryRun {
+		for i := range entries {
+			m := byAlias[ddg.NormalizeAlias(entries[i].Address)]
+			if m == nil {
+				continue
+			}
+			if entries[i].Fields == nil {
+				entries[i].Fields = map[string]string{}
+			}
+			// A mailbox scanned earlier may hold newer mail than this one.
+			if prev, err := time.Parse("2006-01-02", entries[i].Fields[fieldLastSeen]); err == nil && prev.After(m.LastSeen) {
+				continue
+			}
+			entries[i].Fields[fieldLastSeen] = m.LastSeen.Local().Format("2006-01-02")
+			entries[i].Fields[fieldMailCount] = strconv.Itoa(m.Count)
+			entries[i].Fields[fieldTopSenders] = strings.Join(m.Top, " ")
+			annotated++
+		}
+		err = writeHistory(entries, inHistory)
+		audit("scan-mail", err, map[string]string{"messages": strconv.Itoa(n), "aliases": strconv.Itoa(len(found)), "annotated": strconv.Itoa(annotated)})
+		if err != nil {
+			exitErr(err)
+		}
+	}
+
+	printList(found, func(m *aliasMail) string {
+		label := m.Label
+		if !m.Known {
+			label = colorize(colorOrange, "not in history")
+		}
+		return fmt.Sprintf("%s  %s  %d messages, last %s, from %s", cyan(m.Address), orDefault(label, "-"),
+			m.Count, m.LastSeen.Local().Format("2006-01-02"), orDefault(strings.Join(m.Top, ", "), "-"))
+	})
+	if !out.machine() {
+		msg := fmt.Sprintf("%d messages, %d aliases.", n, len(found))
+		if !dryRun {
+			msg += fmt.Sprintf(" Noted %s, %s and %s on %d history entries.", fieldLastSeen, fieldMailCount, fieldTopSenders, annotated)
+		}
+		fmt.Println(msg)
+	}
+}
+
+// mailParties returns who sent a message and which aliases received it.
+func mailParties(h mail.Header) (string, []string) {
+	var aliases []string
+	add := func(a string) {
+		a = ddg.NormalizeAlias(a)
+		if !ddg.IsAlias(a) {
+			return
+		}
+		for _, have := range aliases {
+			if have == a {
+				return
+			}
+		}
+		aliases = append(aliases, a)
+	}
+	for _, name := range recipientHeaders {
+		for _, v := range h[name] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				// Delivered-To and friends are often a bare address.
+				add(v)
+				continue
+			}
+			for _, a := range list {
+				add(a.Address)
+			}
+		}
+	}
+	sender := ""
+	if from, err := mail.ParseAddress(h.Get("From")); err == nil {
+		sender = strings.ToLower(from.Address)
+		if m := duckReplyAddr.FindStringSubmatch(sender); m != nil {
+			sender = m[1] + "@" + m[2]
+			add(m[3] + "@" + ddg.Domain)
+		}
+	}
+	return sender, aliases
+}
+
+// readMailbox calls fn with the headers of every message in a Maildir (any
+// directory: every file under a cur or new directory) or an mbox file, and
+// returns how many there were.
+func readMailbox(path string, fn func(mail.Header, time.Time)) (int, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !st.IsDir() {
+		return readMbox(path, fn)
+	}
+	n := 0
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (filepath.Base(filepath.Dir(p)) != "cur" && filepath.Base(filepath.Dir(p)) != "new") {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		msg, err := mail.ReadMessage(io.LimitReader(f, mailHeaderMax))
+		if err != nil {
+			return nil // not a message
+		}
+		info, _ := d.Info()
+		var mtime time.Time
+		if info != nil {
+			mtime = info.ModTime()
+		}
+		fn(msg.Header, mtime)
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// readMbox splits an mbox on its "From " lines and parses each message's
+// headers.
+func readMbox(path string, fn func(mail.Header, time.Time)) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64<<10)
+	var hdr bytes.Buffer
+	inHeaders, n := false, 0
+	flush := func() {
+		if hdr.Len() == 0 {
+			return
+		}
+		hdr.WriteString("\r\n")
+		if msg, err := mail.ReadMessage(&hdr); err == nil {
+			fn(msg.Header, time.Time{})
+			n++
+		}
+		hdr.Reset()
+	}
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// An overlong body line; headers are never this long.
+			for err == bufio.ErrBufferFull {
+				_, err = r.ReadSlice('\n')
+			}
+			continue
+		}
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				flush()
+				inHeaders = true
+			case inHeaders && len(bytes.TrimRight(line, "\r\n")) == 0:
+				flush()
+				inHeaders = false
+			case inHeaders && hdr.Len() < mailHeaderMax:
+				hdr.Write(line)
+			}
+		}
+		if err == io.EOF {
+			flush()
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// topKeys returns up to n keys of counts, most frequent first.
+func topKeys(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// lockedSecret holds a secret that lives as long as the process, such as
+// the daemon's tokens, outside the Go heap where the OS allows it: in its
+// own mapping, locked into RAM so it isn't swapped out, left out of core
+// dumps, and zeroed by destroy. Go strings can't be wiped, so callers
+// reveal it only for the request at hand.
+type lockedSecret struct {
+	b      []byte
+	mapped bool
+}
+
+func newLockedSecret(s string) *lockedSecret {
+	l := &lockedSecret{}
+	if b, err := lockedAlloc(len(s)); err == nil {
+		l.b, l.mapped = b, true
+	} else {
+		l.b = make([]byte, len(s))
+	}
+	copy(l.b, s)
+	registerLockedSecret(l)
+	return l
+}
+
+func (l *lockedSecret) reveal() string { return string(l.b) }
+
+// String keeps the secret out of anything formatted with %v or %s.
+func (l *lockedSecret) String() string { return "[redacted]" }
+
+func (l *lockedSecret) destroy() {
+	secrets.mu.Lock()
+	delete(secrets.locked, l)
+	secrets.mu.Unlock()
+	for i := range l.b {
+		l.b[i] = 0
+	}
+	if l.mapped {
+		lockedFree(l.b)
+	}
+	l.b, l.mapped = nil, false
+}
+
+// secrets are the values redactSecrets removes. They're registered as
+// they're loaded, whichever keystore they come from. Locked secrets are
+// matched against their own bytes, so registering one leaves no copy of it
+// on the heap.
+var secrets struct {
+	mu     sync.Mutex
+	values []string
+	locked map[*lockedSecret]bool
+}
+
+// minSecretLen is the shortest secret redacted: shorter ones can't be
+// replaced without mangling ordinary text.
+const minSecretLen = 8
+
+func registerSecret(s string) {
+	if len(s) < minSecretLen {
+		return
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, v := range secrets.values {
+		if v == s {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, s)
+}
+
+// registerLockedSecret registers l, dropping any string registered with
+// the same value, which would be a heap copy of it kept for good.
+func registerLockedSecret(l *lockedSecret) {
+	if len(l.b) < minSecretLen {
+		return
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	kept := secrets.values[:0]
+	for _, v := range secrets.values {
+		if v != string(l.b) {
+			kept = append(kept, v)
+		}
+	}
+	secrets.values = kept
+	if secrets.locked == nil {
+		secrets.locked = map[*lockedSecret]bool{}
+	}
+	secrets.locked[l] = true
+}
+
+// redactSecrets replaces every registered secret in s, for text that leaves
+// the process: error messages, crash reports.
+func redactSecrets(s string) string {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, "[redacted]")
+	}
+	for l := range secrets.locked {
+		if len(l.b) >= minSecretLen {
+			s = string(bytes.ReplaceAll([]byte(s), l.b, []byte("[redacted]")))
+		}
+	}
+	return s
+}
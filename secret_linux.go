@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+const madvDontDump = 16 // MADV_DONTDUMP
+
+// lockedAlloc maps n bytes (at least one) of their own, locks them and
+// keeps them out of core dumps. Locking fails beyond RLIMIT_MEMLOCK, which
+// leaves a mapping that's still private and undumped.
+func lockedAlloc(n int) ([]byte, error) {
+	size := n
+	if size == 0 {
+		size = 1
+	}
+	b, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	_ = syscall.Mlock(b)
+	_ = syscall.Madvise(b, madvDontDump)
+	return b[:n], nil
+}
+
+func lockedFree(b []byte) {
+	b = b[:cap(b)]
+	_ = syscall.Munlock(b)
+	_ = syscall.Munmap(b)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Elsewhere secrets stay on the heap; destroy still zeroes them.
+func lockedAlloc(n int) ([]byte, error) { return nil, errors.New("not supported") }
+
+func lockedFree(b []byte) {}
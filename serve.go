@@ -0,0 +1,837 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveUser is one row of the daemon's user table. The DuckDuckGo token is
+// kept encrypted with the server key; the user's access secret is only kept
+// as a hash.
+type serveUser struct {
+	Name       string `json:"name"`
+	Token      string `json:"token"`
+	SecretHash string `json:"secret_hash"`
+}
+
+// serveKey is a scoped local API key, so e.g. a browser extension can be
+// given generate rights while a dashboard only reads history. Keys act as
+// User, or as the local user when User is empty.
+type serveKey struct {
+	Name   string   `json:"name"`
+	User   string   `json:"user,omitempty"`
+	Scopes []string `json:"scopes"`
+	Hash   string   `json:"hash"`
+}
+
+func doServe(args []string) {
+	if len(args) > 0 && args[0] == "users" {
+		doServeUsers(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "keys" {
+		doServeKeys(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "bookmarklet" {
+		doServeBookmarklet(args[1:])
+		return
+	}
+
+	addr := defaultServeAddr
+	mdns, asService, sandboxed := false, false, true
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--addr" && i+1 < len(args) {
+			addr = args[i+1]
+			i++
+		} else if args[i] == "--advertise" {
+			mdns = true
+		} else if args[i] == "--no-sandbox" {
+			sandboxed = false
+		} else if args[i] == "--windows-service" {
+			asService = true
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if asService {
+		err := runWindowsService(func(stop <-chan struct{}) { serve(addr, mdns, sandboxed, stop) })
+		if err != nil {
+			exitErr(err)
+		}
+		return
+	}
+	serve(addr, mdns, sandboxed, nil)
+}
+
+// serve runs the daemon until it gets a signal, a listener fails or, when
+// a service manager runs it, quit is closed.
+func serve(addr string, mdns, sandboxed bool, quit <-chan struct{}) {
+	// Logging and notification sinks are the host's, whichever users the
+	// daemon serves.
+	hostCfg, _ := readConfig()
+	logger, err := openDaemonLog(hostCfg.LogTarget)
+	if err != nil {
+		exitErr(err)
+	}
+	dlog = logger
+	sinks, err := configuredSinks(hostCfg)
+	if err != nil {
+		exitErr(err)
+	}
+
+	users, err := readUsers()
+	if err != nil {
+		exitErr(err)
+	}
+	if len(users) == 0 && !isLoopback(addr) {
+		exitErr(fmt.Errorf("refusing to serve the local token on %s without users; add one with 'ddg serve users add <name>' or bind to 127.0.0.1", addr))
+	}
+	if mdns && isLoopback(addr) {
+		exitErr(fmt.Errorf("--advertise needs an --addr other devices can reach, e.g. 0.0.0.0:8765"))
+	}
+
+	keys, err := readKeys()
+	if err != nil {
+		exitErr(err)
+	}
+	srv := &server{users: users, keys: keys}
+	if len(users) == 0 {
+		cfg, err := ensureConfig(false)
+		if err != nil {
+			exitErr(err)
+		}
+		srv.localToken = newLockedSecret(cfg.APIKey)
+		cfg.APIKey = "" // requests get it from localToken
+		srv.local = &cfg
+	} else if srv.key, err = serverKey(); err != nil {
+		exitErr(err)
+	}
+	// Decrypt every user's token up front so requests never touch the
+	// key file or config again.
+	srv.tokens = map[string]*lockedSecret{}
+	for _, u := range users {
+		token, err := decryptToken(srv.key, u.Token)
+		if err != nil {
+			exitErr(fmt.Errorf("user %s: stored token unreadable: %w", u.Name, err))
+		}
+		srv.tokens[u.Name] = newLockedSecret(token)
+	}
+	if len(srv.tokens) > 0 {
+		disableCoreDumps()
+	}
+	defer srv.destroySecrets()
+	srv.started = time.Now()
+	srv.events = newEventHub()
+
+	mux := http.NewServeMux()
+	for _, rt := range serveRoutes {
+		h := rt.Handler
+		mux.HandleFunc(rt.Path, func(w http.ResponseWriter, r *http.Request) {
+			defer recoverRequest(w)
+			h(srv, w, r)
+		})
+	}
+	mux.HandleFunc(openAPIRoute.Path, srv.handleOpenAPI)
+
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		exitErr(err)
+	}
+	listeners := []net.Listener{tcp}
+	sock := ""
+	if runtime.GOOS != "windows" {
+		if sock, err = socketPath(); err == nil {
+			_ = os.Remove(sock)
+			if l, err := net.Listen("unix", sock); err == nil {
+				_ = os.Chmod(sock, 0600)
+				listeners = append(listeners, l)
+			} else {
+				logf(priWarning, "not listening on %s: %v", sock, err)
+				sock = ""
+			}
+		}
+	}
+	if err := writeDaemonState(daemonState{Addr: tcp.Addr().String(), Socket: sock, PID: os.Getpid()}); err != nil {
+		logf(priWarning, "clients will not find this daemon: %v", err)
+	}
+	defer removeDaemonState()
+
+	if srv.local != nil {
+		logf(priInfo, "Serving on http://%s (single-user, local token)", tcp.Addr())
+	} else {
+		logf(priInfo, "Serving on http://%s (%d users)", tcp.Addr(), len(users))
+	}
+	if sock != "" {
+		logf(priInfo, "Also listening on %s", sock)
+	}
+	if mdns {
+		withdraw, err := advertise(tcp.Addr().String())
+		if err != nil {
+			logf(priWarning, "not advertising on the LAN: %v", err)
+		} else {
+			defer withdraw()
+			logf(priInfo, "Advertising as %s.local", mdnsService)
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	if srv.local != nil {
+		go srv.watchHistory(stop)
+	}
+	go weeklySummaries(stop)
+	if hostCfg.IMAPServer != "" {
+		go watchIMAP(hostCfg, defaultIMAPEvery, stop, func(msg string) { logf(priInfo, "%s", msg) })
+		logf(priInfo, "Counting alias mail in %s on %s", imapMailbox(hostCfg), hostCfg.IMAPServer)
+	}
+	if len(sinks) > 0 {
+		go srv.forwardEvents(sinks, stop)
+		logf(priInfo, "Forwarding events to %s", strings.Join(sortedKeys(hostCfg.Notifications), ", "))
+	}
+
+	// The daemon holds a live token and listens on a socket; once everything
+	// is open, give up what serving doesn't need.
+	if sandboxed {
+		if what, err := sandbox(hostCfg); err != nil {
+			logf(priWarning, "not sandboxed: %v", err)
+		} else if what != "" {
+			logf(priInfo, "Sandboxed: %s", what)
+		}
+	}
+
+	hs := &http.Server{Handler: mux}
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) { errc <- hs.Serve(l) }(l)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sigs:
+	case <-quit:
+	case err = <-errc:
+		logf(priErr, "%v", err)
+	}
+	_ = hs.Close()
+	if sock != "" {
+		_ = os.Remove(sock)
+	}
+}
+
+type server struct {
+	users      []serveUser
+	mu         sync.Mutex // guards keys and their file's stamp; see currentKeys
+	keys       []serveKey
+	keysMod    time.Time
+	keysSize   int64
+	local      *conf // set in single-user mode, without the API key
+	localToken *lockedSecret
+	key        []byte
+	tokens     map[string]*lockedSecret // decrypted once at startup, by user name
+
+	started  time.Time
+	generate latencyStats
+	events   *eventHub
+	flights  flightGroup // concurrent generates for one user and label
+	accounts accountCache
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "version": version})
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"uptime_seconds": int(time.Since(s.started).Seconds()),
+		"generate":       s.generate.summary(),
+	})
+}
+
+func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	failed := true
+	defer func() { s.generate.observe(time.Since(start), failed) }()
+
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	who, status, err := s.authenticate(r, scopeGenerate)
+	if err != nil {
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" && r.Body != nil {
+		var body struct {
+			Label string `json:"label"`
+		}
+		_ = json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body)
+		label = body.Label
+	}
+
+	// A mashed hotkey or a double-submitted form sends the same request
+	// several times at once; they all get the one alias.
+	addr, err, shared := s.flights.do(who.Name+"\x00"+label, func() (string, error) {
+		return s.generateFor(who, label)
+	})
+	if err != nil {
+		status := http.StatusBadGateway
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
+			status = http.StatusUnauthorized
+		} else if errors.Is(err, errHookVeto) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, errOverBudget) {
+			status = http.StatusTooManyRequests
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	failed = false
+	s.events.publish(serveEvent{Type: "generate", User: who.Name, Address: addr, Label: label, Time: time.Now(), Source: "daemon"})
+	res := map[string]interface{}{"address": addr, "label": label}
+	if shared {
+		res["shared"] = true
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// recoverRequest answers a request that panicked with a 500 and keeps the
+// daemon up. net/http would recover too, but log the raw stack trace.
+func recoverRequest(w http.ResponseWriter) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	path, err := writeCrashReport(v, debug.Stack())
+	if err != nil {
+		path = "not saved: " + err.Error()
+	}
+	logf(priErr, "a request crashed: %s (crash report: %s)", sanitizeCrash(fmt.Sprint(v)), path)
+	writeJSONError(w, http.StatusInternalServerError, "internal error")
+}
+
+func (s *server) generateFor(who principal, label string) (string, error) {
+	if who.Name == "local" {
+		entry, err := generateAlias(s.localConfig(who), historyEntry{Label: label})
+		return entry.Address, err
+	}
+	// Other people's aliases stay out of the host's history; the audit log
+	// still records who generated what. The host's policy hook applies to
+	// everyone.
+	var addr string
+	host, _ := readConfig()
+	err := runPreGenerateHook(host, historyEntry{Label: label}, who.Name)
+	if err == nil {
+		addr, err = requestEmail(who.Token)
+	}
+	audit("serve.generate", err, map[string]string{"user": who.Name, "address": addr, "label": label})
+	return addr, err
+}
+
+func (s *server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	who, status, err := s.authenticate(r, scopeHistory)
+	if err != nil {
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	acct, stale, err := s.accounts.get(who.Name, who.Token)
+	if err != nil {
+		status := http.StatusBadGateway
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
+			status = http.StatusUnauthorized
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"username":            acct.Username,
+		"forwarding_email":    acct.ForwardingEmail,
+		"addresses_generated": acct.AddressesGenerated,
+		"fetched":             acct.Fetched,
+		"stale":               stale,
+	})
+}
+
+// principal is who a request acts as: a user name and the DuckDuckGo token
+// to spend.
+type principal struct {
+	Name  string
+	Token string
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	who, status, err := s.authenticate(r, scopeHistory)
+	if err != nil {
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	entries := []historyEntry{}
+	if who.Name == "local" {
+		local, err := liveHistory()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		entries = append(entries, local...)
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// authenticate maps the request's bearer secret to a principal allowed to
+// use scope. User secrets carry every scope; scoped keys only what they were
+// issued for. In single-user mode with no keys issued, every local
+// non-browser request is the local user. On failure the HTTP status to
+// answer with is returned alongside the error.
+func (s *server) authenticate(r *http.Request, scope string) (principal, int, error) {
+	secret := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret == "" {
+		if s.local != nil && !s.requiresKeys() {
+			if !loopbackHost(r) {
+				return principal{}, http.StatusForbidden, errors.New("in single-user mode, requests must be addressed to 127.0.0.1, [::1] or localhost")
+			}
+			// Any web page can POST to localhost; only non-browser clients
+			// get the local token without credentials.
+			if r.Header.Get("Origin") != "" {
+				return principal{}, http.StatusForbidden, errors.New("cross-origin requests are not allowed in single-user mode")
+			}
+			return principal{Name: "local", Token: s.localToken.reveal()}, 0, nil
+		}
+		return principal{}, http.StatusUnauthorized, errors.New("missing bearer token")
+	}
+
+	hash := hashSecret(secret)
+	for _, k := range s.currentKeys() {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if !hasTag(k.Scopes, scope) {
+			return principal{}, http.StatusForbidden, fmt.Errorf("key %q lacks the %s scope", k.Name, scope)
+		}
+		if k.User == "" {
+			if s.local == nil {
+				return principal{}, http.StatusUnauthorized, fmt.Errorf("key %q is not tied to a user", k.Name)
+			}
+			return principal{Name: "local", Token: s.localToken.reveal()}, 0, nil
+		}
+		return s.userPrincipal(k.User)
+	}
+	for _, u := range s.users {
+		if subtle.ConstantTimeCompare([]byte(u.SecretHash), []byte(hash)) == 1 {
+			return s.userPrincipal(u.Name)
+		}
+	}
+	return principal{}, http.StatusUnauthorized, errors.New("unknown bearer token")
+}
+
+// loopbackHost reports whether r was addressed to the daemon's own port by a
+// loopback name, as local clients do. A DNS-rebinding page's requests are
+// same-origin, so they carry no Origin, but their Host is the page's name.
+// The unix socket has no host to check; only its owner can connect to it.
+func loopbackHost(r *http.Request) bool {
+	switch local := r.Context().Value(http.LocalAddrContextKey).(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		host, port, err := net.SplitHostPort(r.Host)
+		if err != nil || port != strconv.Itoa(local.Port) {
+			return false
+		}
+		switch strings.ToLower(host) {
+		case "127.0.0.1", "::1", "localhost":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) userPrincipal(name string) (principal, int, error) {
+	token, ok := s.tokens[name]
+	if !ok {
+		return principal{}, http.StatusUnauthorized, fmt.Errorf("no user named %q", name)
+	}
+	return principal{Name: name, Token: token.reveal()}, 0, nil
+}
+
+// localConfig is the local config with who's token, for generating as the
+// local user.
+func (s *server) localConfig(who principal) conf {
+	cfg := *s.local
+	cfg.APIKey = who.Token
+	return cfg
+}
+
+func (s *server) destroySecrets() {
+	if s.localToken != nil {
+		s.localToken.destroy()
+	}
+	for _, t := range s.tokens {
+		t.destroy()
+	}
+}
+
+func doServeUsers(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg serve users <list|add <name>|remove <name>>"))
+	}
+	users, err := readUsers()
+	if err != nil {
+		exitErr(err)
+	}
+	switch args[0] {
+	case "list":
+		if len(users) == 0 {
+			out.Info("No users; 'ddg serve' runs in single-user mode.")
+		}
+		names := make([]string, 0, len(users))
+		for _, u := range users {
+			names = append(names, u.Name)
+		}
+		printList(names, func(n string) string { return n })
+	case "add":
+		requireWritable("add a server user")
+		if len(args) < 2 {
+			exitErr(fmt.Errorf("usage: ddg serve users add <name>"))
+		}
+		name := args[1]
+		for _, u := range users {
+			if u.Name == name {
+				exitErr(fmt.Errorf("user %q already exists", name))
+			}
+		}
+		token := ask(prompt{
+			Label:    "DuckDuckGo API key for " + name,
+			Env:      "DDG_USER_API_KEY",
+			Secret:   true,
+			Validate: validNotEmpty,
+		})
+		key, err := serverKey()
+		if err != nil {
+			exitErr(err)
+		}
+		enc, err := encryptToken(key, token)
+		if err != nil {
+			exitErr(err)
+		}
+		secret, err := randomHex(24)
+		if err != nil {
+			exitErr(err)
+		}
+		users = append(users, serveUser{Name: name, Token: enc, SecretHash: hashSecret(secret)})
+		err = writeUsers(users)
+		audit("serve.users.add", err, map[string]string{"user": name})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done(fmt.Sprintf("Added %s. Their access token (shown once):\n%s", name, secret), map[string]string{"user": name, "token": secret})
+	case "remove":
+		requireWritable("remove a server user")
+		if len(args) < 2 {
+			exitErr(fmt.Errorf("usage: ddg serve users remove <name>"))
+		}
+		kept := users[:0]
+		for _, u := range users {
+			if u.Name != args[1] {
+				kept = append(kept, u)
+			}
+		}
+		if len(kept) == len(users) {
+			exitErr(fmt.Errorf("no user named %q", args[1]))
+		}
+		err = writeUsers(kept)
+		audit("serve.users.remove", err, map[string]string{"user": args[1]})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done(fmt.Sprintf("Removed %s.", args[1]), map[string]string{"removed": args[1]})
+	default:
+		exitErr(fmt.Errorf("usage: unknown users command %s", args[0]))
+	}
+}
+
+func doServeKeys(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg serve keys <list|add <name> --scope <generate,history,events> [--user <name>]|remove <name>>"))
+	}
+	keys, err := readKeys()
+	if err != nil {
+		exitErr(err)
+	}
+	switch args[0] {
+	case "list":
+		printList(keys, func(k serveKey) string {
+			return fmt.Sprintf("%s\tuser=%s\tscopes=%s", k.Name, emptyToDash(k.User), strings.Join(k.Scopes, ","))
+		})
+	case "add":
+		requireWritable("issue an API key")
+		if len(args) < 2 || strings.HasPrefix(args[1], "--") {
+			exitErr(fmt.Errorf("usage: ddg serve keys add <name> --scope <generate,history,events> [--user <name>]"))
+		}
+		k := serveKey{Name: args[1]}
+		for i := 2; i < len(args); i++ {
+			if args[i] == "--scope" && i+1 < len(args) {
+				for _, sc := range strings.Split(args[i+1], ",") {
+					sc = strings.TrimSpace(strings.ToLower(sc))
+					if sc != scopeGenerate && sc != scopeHistory && sc != scopeEvents {
+						exitErr(fmt.Errorf("unknown scope %q (use generate, history or events)", sc))
+					}
+					k.Scopes = unionTags(k.Scopes, []string{sc})
+				}
+				i++
+			} else if args[i] == "--user" && i+1 < len(args) {
+				k.User = args[i+1]
+				i++
+			} else {
+				unknownArg(args[i])
+			}
+		}
+		if len(k.Scopes) == 0 {
+			exitErr(fmt.Errorf("at least one --scope is required"))
+		}
+		for _, existing := range keys {
+			if existing.Name == k.Name {
+				exitErr(fmt.Errorf("key %q already exists", k.Name))
+			}
+		}
+		secret, err := randomHex(24)
+		if err != nil {
+			exitErr(err)
+		}
+		k.Hash = hashSecret(secret)
+		err = writeKeys(append(keys, k))
+		audit("serve.keys.add", err, map[string]string{"key": k.Name, "user": k.User, "scopes": strings.Join(k.Scopes, ",")})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done(fmt.Sprintf("Issued %s (%s). The key (shown once):\n%s", k.Name, strings.Join(k.Scopes, ","), secret),
+			map[string]interface{}{"name": k.Name, "scopes": k.Scopes, "key": secret})
+	case "remove":
+		requireWritable("revoke an API key")
+		if len(args) < 2 {
+			exitErr(fmt.Errorf("usage: ddg serve keys remove <name>"))
+		}
+		kept := keys[:0]
+		for _, k := range keys {
+			if k.Name != args[1] {
+				kept = append(kept, k)
+			}
+		}
+		if len(kept) == len(keys) {
+			exitErr(fmt.Errorf("no key named %q", args[1]))
+		}
+		err = writeKeys(kept)
+		audit("serve.keys.remove", err, map[string]string{"key": args[1]})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done(fmt.Sprintf("Revoked %s.", args[1]), map[string]string{"revoked": args[1]})
+	default:
+		exitErr(fmt.Errorf("usage: unknown keys command %s", args[0]))
+	}
+}
+
+func keysPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, keysFileName), nil
+}
+
+func readKeys() ([]serveKey, error) {
+	path, err := keysPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []serveKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func writeKeys(keys []serveKey) error {
+	path, err := keysPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
+
+func usersPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, usersFileName), nil
+}
+
+func readUsers() ([]serveUser, error) {
+	path, err := usersPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var users []serveUser
+	if err := json.Unmarshal(b, &users); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return users, nil
+}
+
+func writeUsers(users []serveUser) error {
+	path, err := usersPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// serverKey returns the key used to encrypt user tokens at rest, creating
+// it on first use.
+func serverKey() ([]byte, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, serverKeyFileName)
+	b, err := os.ReadFile(path)
+	if err == nil && len(b) == 32 {
+		return b, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func encryptToken(key []byte, token string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(token), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptToken(key []byte, enc string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// sheetRow is one line of a contact sheet.
+type sheetRow struct {
+	Label   string
+	Address string
+	Created string
+	Tags    string
+	Notes   string
+	Fields  string
+}
+
+var sheetHTML = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+tr:nth-child(even) td { background: #fafafa; }
+td.addr { font-family: monospace; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Rows}} aliases, generated {{.Generated}}.</p>
+<table>
+<tr><th>Label</th><th>Alias</th><th>Created</th><th>Tags</th><th>Notes</th><th>Fields</th></tr>
+{{range .Rows}}<tr><td>{{.Label}}</td><td class="addr">{{.Address}}</td><td>{{.Created}}</td><td>{{.Tags}}</td><td>{{.Notes}}</td><td>{{.Fields}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func doSheet(args []string) {
+	tag, format, outPath := "", "md", ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--tag" && i+1 < len(args) {
+			tag = args[i+1]
+			i++
+		} else if args[i] == "--format" && i+1 < len(args) {
+			format = strings.ToLower(args[i+1])
+			i++
+		} else if args[i] == "--out" && i+1 < len(args) {
+			outPath = args[i+1]
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if format != "md" && format != "html" {
+		exitErr(fmt.Errorf("unknown --format %q (use md or html)", format))
+	}
+
+	entries, err := liveHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	var rows []sheetRow
+	for _, e := range mergeHistory(entries, nil) {
+		if tag != "" && !hasTag(e.Tags, tag) {
+			continue
+		}
+		rows = append(rows, sheetRow{
+			Label:   e.Label,
+			Address: e.Address,
+			Created: e.Created.Local().Format("2006-01-02"),
+			Tags:    strings.Join(e.Tags, ", "),
+			Notes:   e.Notes,
+			Fields:  formatFields(e.Fields),
+		})
+	}
+	title := "Alias contact sheet"
+	if tag != "" {
+		title += ": " + tag
+	}
+
+	var buf bytes.Buffer
+	if format == "html" {
+		err = sheetHTML.Execute(&buf, map[string]interface{}{
+			"Title": title, "Rows": rows, "Generated": time.Now().Format("2006-01-02"),
+		})
+		if err != nil {
+			exitErr(err)
+		}
+	} else {
+		fmt.Fprintf(&buf, "# %s\n\n", title)
+		buf.WriteString("| Label | Alias | Created | Tags | Notes | Fields |\n|---|---|---|---|---|---|\n")
+		for _, r := range rows {
+			fmt.Fprintf(&buf, "| %s | `%s` | %s | %s | %s | %s |\n",
+				mdCell(r.Label), r.Address, r.Created, mdCell(r.Tags), mdCell(r.Notes), mdCell(r.Fields))
+		}
+	}
+
+	if outPath == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(outPath, buf.Bytes(), 0600); err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("Wrote %d aliases to %s.", len(rows), outPath), map[string]interface{}{"file": outPath, "count": len(rows)})
+}
+
+// mdCell makes s safe inside a Markdown table cell.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
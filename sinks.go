@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notificationPrefix marks the config keys for notification sinks, one per
+// sink: "notifications.ntfy = https://ntfy.sh/my-topic". The daemon pushes
+// its events to every sink configured there.
+const notificationPrefix = "notifications."
+
+// notifySink delivers a notification somewhere off this machine.
+type notifySink interface {
+	send(ctx context.Context, title, msg string) error
+}
+
+// sinkKinds parse a sink's target into a sink. Targets carry their own
+// credentials, which is why settings never shows them.
+var sinkKinds = map[string]func(target string) (notifySink, error){
+	// https://ntfy.sh/<topic>, or https://<access token>@host/<topic>
+	"ntfy": newNtfySink,
+	// <user key>:<application token>
+	"pushover": newPushoverSink,
+	// https://<access token>@<homeserver>/<room id>
+	"matrix": newMatrixSink,
+	// Any http(s) URL; user:password@ in it is sent as basic auth
+	"webhook": newWebhookSink,
+}
+
+func sinkNames() []string {
+	names := make([]string, 0, len(sinkKinds))
+	for n := range sinkKinds {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredSinks returns the sinks in cfg, by name.
+func configuredSinks(cfg conf) (map[string]notifySink, error) {
+	sinks := map[string]notifySink{}
+	for name, target := range cfg.Notifications {
+		kind, ok := sinkKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification sink %q (use %s)", name, strings.Join(sinkNames(), ", "))
+		}
+		s, err := kind(target)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", notificationPrefix, name, err)
+		}
+		sinks[name] = s
+	}
+	return sinks, nil
+}
+
+// sinkClient is not apiClient: fixtures would record a sink's requests,
+// credentials and all, and answer them on replay. Egress is checked all the
+// same.
+var sinkClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy:               egressProxy,
+		DialContext:         (&cachingDialer{}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// postSink sends req and turns a non-2xx answer into an error.
+func postSink(req *http.Request) error {
+	resp, err := sinkClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+type ntfySink struct {
+	topic string // the URL without credentials
+	token string
+}
+
+func newNtfySink(target string) (notifySink, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("want a topic URL like https://ntfy.sh/my-topic")
+	}
+	s := &ntfySink{}
+	if u.User != nil {
+		s.token = u.User.Username()
+		u.User = nil
+	}
+	s.topic = u.String()
+	return s, nil
+}
+
+func (s *ntfySink) send(ctx context.Context, title, msg string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.topic, strings.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	req.Header.Set("Tags", "duck")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return postSink(req)
+}
+
+const pushoverURL = "https://api.pushover.net/1/messages.json"
+
+type pushoverSink struct {
+	user, token string
+}
+
+func newPushoverSink(target string) (notifySink, error) {
+	user, token, ok := strings.Cut(target, ":")
+	if !ok || user == "" || token == "" {
+		return nil, fmt.Errorf("want <user key>:<application token>")
+	}
+	return &pushoverSink{user: user, token: token}, nil
+}
+
+func (s *pushoverSink) send(ctx context.Context, title, msg string) error {
+	form := url.Values{"token": {s.token}, "user": {s.user}, "title": {title}, "message": {msg}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return postSink(req)
+}
+
+type matrixSink struct {
+	homeserver string
+	room       string
+	token      string
+}
+
+func newMatrixSink(target string) (notifySink, error) {
+	u, err := url.Parse(target)
+	room := strings.Trim(u.Path, "/")
+	if err != nil || u.User == nil || u.Host == "" || !strings.HasPrefix(room, "!") {
+		return nil, fmt.Errorf("want https://<access token>@<homeserver>/<room id>, the room id starting with '!'")
+	}
+	return &matrixSink{homeserver: u.Scheme + "://" + u.Host, room: room, token: u.User.Username()}, nil
+}
+
+func (s *matrixSink) send(ctx context.Context, title, msg string) error {
+	body, _ := json.Marshal(map[string]string{"msgtype": "m.notice", "body": title + ": " + msg})
+	// The transaction id makes retries of the same message idempotent.
+	txn := strconv.FormatInt(time.Now().UnixNano(), 36)
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		s.homeserver, url.PathEscape(s.room), txn)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	return postSink(req)
+}
+
+// webhookSink POSTs {"title": ..., "message": ..., "time": ...} as JSON, for
+// anything that takes a webhook: Slack-style relays, Home Assistant, n8n.
+type webhookSink struct {
+	url string
+}
+
+func newWebhookSink(target string) (notifySink, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("want an http(s) URL")
+	}
+	return &webhookSink{url: target}, nil
+}
+
+func (s *webhookSink) send(ctx context.Context, title, msg string) error {
+	body, _ := json.Marshal(map[string]string{"title": title, "message": msg, "time": time.Now().UTC().Format(time.RFC3339)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return postSink(req)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB reads whole tables out of an SQLite 3 file: just enough to get
+// saved logins out of a browser profile without cgo or a driver. It reads
+// the file (and any -wal beside it) into memory and never writes or
+// locks, so it's safe on a database the browser has open, though what the
+// browser hasn't written out yet won't be seen.
+type sqliteDB struct {
+	data   []byte
+	page   int // page size
+	usable int
+	wal    map[uint32][]byte // newest committed copy of pages in the WAL
+}
+
+var errSQLiteCorrupt = errors.New("not a readable SQLite database")
+
+const sqliteMagic = "SQLite format 3\x00"
+
+func openSQLite(path string) (*sqliteDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 100 || string(data[:16]) != sqliteMagic {
+		return nil, fmt.Errorf("%s: %w", path, errSQLiteCorrupt)
+	}
+	db := &sqliteDB{data: data, page: int(binary.BigEndian.Uint16(data[16:]))}
+	if db.page == 1 {
+		db.page = 65536
+	}
+	db.usable = db.page - int(data[20])
+	if db.page < 512 || db.usable < 480 {
+		return nil, fmt.Errorf("%s: %w", path, errSQLiteCorrupt)
+	}
+	if wal, err := os.ReadFile(path + "-wal"); err == nil {
+		db.readWAL(wal)
+	}
+	return db, nil
+}
+
+// readWAL keeps the pages of every committed transaction in the log, the
+// way a reader opening the database would see them. An unusable log is
+// ignored, as SQLite would.
+func (db *sqliteDB) readWAL(wal []byte) {
+	if len(wal) < 32 || int(binary.BigEndian.Uint32(wal[8:])) != db.page {
+		return
+	}
+	salt := wal[16:24]
+	pending := map[uint32][]byte{}
+	for off := 32; off+24+db.page <= len(wal); off += 24 + db.page {
+		frame := wal[off : off+24+db.page]
+		if !bytes.Equal(frame[8:16], salt) {
+			break
+		}
+		pending[binary.BigEndian.Uint32(frame)] = frame[24:]
+		if binary.BigEndian.Uint32(frame[4:]) != 0 { // a commit
+			if db.wal == nil {
+				db.wal = map[uint32][]byte{}
+			}
+			for n, p := range pending {
+				db.wal[n] = p
+			}
+			pending = map[uint32][]byte{}
+		}
+	}
+}
+
+func (db *sqliteDB) pageData(n uint32) ([]byte, error) {
+	if p, ok := db.wal[n]; ok {
+		return p, nil
+	}
+	off := int64(n-1) * int64(db.page)
+	if n == 0 || off+int64(db.page) > int64(len(db.data)) {
+		return nil, errSQLiteCorrupt
+	}
+	return db.data[off : off+int64(db.page)], nil
+}
+
+// sqliteRow maps column names to int64, float64, string, []byte or nil.
+type sqliteRow map[string]interface{}
+
+// rows returns every row of table, in rowid order.
+func (db *sqliteDB) rows(table string) (rows []sqliteRow, err error) {
+	// The file is untrusted input; a bounds slip in it is a corrupt
+	// database, not a crash.
+	defer func() {
+		if v := recover(); v != nil {
+			rows, err = nil, errSQLiteCorrupt
+		}
+	}()
+	var root uint32
+	var cols []string
+	rowidCol := -1
+	err = db.walk(1, 0, func(_ int64, rec []interface{}) error {
+		if len(rec) < 5 || rec[0] != "table" || !strings.EqualFold(fmt.Sprint(rec[1]), table) {
+			return nil
+		}
+		n, _ := rec[3].(int64)
+		sql, _ := rec[4].(string)
+		root = uint32(n)
+		cols, rowidCol = sqliteColumns(sql)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, fmt.Errorf("no %s table", table)
+	}
+	err = db.walk(root, 0, func(rowid int64, rec []interface{}) error {
+		row := sqliteRow{}
+		for i, c := range cols {
+			if i < len(rec) {
+				row[c] = rec[i]
+			}
+		}
+		if rowidCol >= 0 {
+			row[cols[rowidCol]] = rowid
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// walk calls fn for each record in the table b-tree rooted at page n.
+func (db *sqliteDB) walk(n uint32, depth int, fn func(rowid int64, rec []interface{}) error) error {
+	if depth > 20 {
+		return errSQLiteCorrupt
+	}
+	pg, err := db.pageData(n)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if n == 1 {
+		hdr = 100
+	}
+	cells := int(binary.BigEndian.Uint16(pg[hdr+3:]))
+	switch pg[hdr] {
+	case 0x05: // interior
+		for i := 0; i < cells; i++ {
+			p := int(binary.BigEndian.Uint16(pg[hdr+12+2*i:]))
+			if err := db.walk(binary.BigEndian.Uint32(pg[p:]), depth+1, fn); err != nil {
+				return err
+			}
+		}
+		return db.walk(binary.BigEndian.Uint32(pg[hdr+8:]), depth+1, fn)
+	case 0x0d: // leaf
+		for i := 0; i < cells; i++ {
+			p := int(binary.BigEndian.Uint16(pg[hdr+8+2*i:]))
+			size, k := sqliteVarint(pg[p:])
+			p += k
+			rowid, k := sqliteVarint(pg[p:])
+			p += k
+			payload, err := db.payload(pg, p, int(size))
+			if err != nil {
+				return err
+			}
+			rec, err := sqliteRecord(payload)
+			if err != nil {
+				return err
+			}
+			if err := fn(rowid, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errSQLiteCorrupt
+}
+
+// payload assembles a cell's payload of size bytes starting at pg[p:],
+// following overflow pages when it doesn't fit.
+func (db *sqliteDB) payload(pg []byte, p, size int) ([]byte, error) {
+	u := db.usable
+	local := size
+	if max := u - 35; size > max {
+		min := (u-12)*32/255 - 23
+		local = min + (size-min)%(u-4)
+		if local > max {
+			local = min
+		}
+	}
+	if local == size {
+		return pg[p : p+size], nil
+	}
+	out := append([]byte{}, pg[p:p+local]...)
+	next := binary.BigEndian.Uint32(pg[p+local:])
+	for hops := 0; len(out) < size; hops++ {
+		if next == 0 || hops > len(db.data)/db.page+len(db.wal) {
+			return nil, errSQLiteCorrupt
+		}
+		ov, err := db.pageData(next)
+		if err != nil {
+			return nil, err
+		}
+		take := u - 4
+		if rest := size - len(out); rest < take {
+			take = rest
+		}
+		out = append(out, ov[4:4+take]...)
+		next = binary.BigEndian.Uint32(ov)
+	}
+	return out, nil
+}
+
+// sqliteRecord decodes a record into int64, float64, string, []byte or nil
+// values.
+func sqliteRecord(b []byte) ([]interface{}, error) {
+	hlen, k := sqliteVarint(b)
+	if k == 0 || int(hlen) > len(b) {
+		return nil, errSQLiteCorrupt
+	}
+	var types []int64
+	for p := k; p < int(hlen); {
+		t, n := sqliteVarint(b[p:int(hlen)])
+		if n == 0 {
+			return nil, errSQLiteCorrupt
+		}
+		types = append(types, t)
+		p += n
+	}
+	body := b[hlen:]
+	rec := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		var size int
+		switch {
+		case t == 0 || t == 8 || t == 9:
+			size = 0
+		case t >= 1 && t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		default:
+			return nil, errSQLiteCorrupt
+		}
+		if size > len(body) {
+			return nil, errSQLiteCorrupt
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case t == 0:
+			rec = append(rec, nil)
+		case t == 8 || t == 9:
+			rec = append(rec, t-8)
+		case t == 7:
+			rec = append(rec, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t <= 6:
+			var n int64
+			for i, c := range v {
+				if i == 0 {
+					n = int64(int8(c))
+				} else {
+					n = n<<8 | int64(c)
+				}
+			}
+			rec = append(rec, n)
+		case t%2 == 0:
+			rec = append(rec, append([]byte{}, v...))
+		default:
+			rec = append(rec, string(v))
+		}
+	}
+	return rec, nil
+}
+
+// sqliteVarint decodes a big-endian varint of up to 9 bytes, returning 0
+// bytes read if b is too short.
+func sqliteVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return 0, 0
+}
+
+// sqliteColumns takes the column names from a CREATE TABLE statement, and
+// which of them, if any, is an INTEGER PRIMARY KEY and so stored as the
+// rowid rather than in the record.
+func sqliteColumns(sql string) ([]string, int) {
+	open, end := strings.IndexByte(sql, '('), strings.LastIndexByte(sql, ')')
+	if open < 0 || end < open {
+		return nil, -1
+	}
+	var defs []string
+	depth, start := 0, open+1
+	for i := open + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[start:i])
+				start = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[start:end])
+
+	var cols []string
+	rowid := -1
+	for _, d := range defs {
+		f := strings.Fields(d)
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		if len(f) >= 4 && strings.EqualFold(f[1], "INTEGER") && strings.EqualFold(f[2], "PRIMARY") && strings.EqualFold(f[3], "KEY") {
+			rowid = len(cols)
+		}
+		cols = append(cols, strings.Trim(f[0], "\"`[]"))
+	}
+	return cols, rowid
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const stateFileName = "state.json"
+
+// State keys. State is what ddg has learned about the machine (as opposed to
+// config, which is what the user chose) and can always be thrown away.
+const (
+	stateClipboardBackend = "clipboard_backend"
+)
+
+func statePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, stateFileName), nil
+}
+
+func readState() map[string]string {
+	st := map[string]string{}
+	path, err := statePath()
+	if err != nil {
+		return st
+	}
+	span := storageSpan("ddg.state.read", path)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		_ = json.Unmarshal(b, &st)
+	}
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	span.End(err)
+	return st
+}
+
+func getState(key string) string {
+	return readState()[key]
+}
+
+func setState(key, val string) (err error) {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	st := readState()
+	span := storageSpan("ddg.state.write", path)
+	span.SetAttributes(ddg.Attr{Key: "ddg.key", Value: key})
+	defer func() { span.End(err) }()
+	if val == "" {
+		delete(st, key)
+	} else {
+		st[key] = val
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
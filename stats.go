@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow is how many recent requests the daemon's /stats keeps.
+const latencyWindow = 1000
+
+// latencyStats records request durations in a fixed-size ring so the
+// percentiles reflect recent behaviour.
+type latencyStats struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	count   int64
+	errors  int64
+}
+
+func (l *latencyStats) observe(d time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	if failed {
+		l.errors++
+	}
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencyWindow
+}
+
+// latencySummary is the JSON shape of one endpoint's stats.
+type latencySummary struct {
+	Count  int64   `json:"count"`
+	Errors int64   `json:"errors"`
+	P50ms  float64 `json:"p50_ms"`
+	P95ms  float64 `json:"p95_ms"`
+}
+
+func (l *latencyStats) summary() latencySummary {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	s := latencySummary{Count: l.count, Errors: l.errors}
+	l.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return s
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s.P50ms = percentile(sorted, 0.50)
+	s.P95ms = percentile(sorted, 0.95)
+	return s
+}
+
+func percentile(sorted []time.Duration, p float64) float64 {
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return float64(sorted[idx].Microseconds()) / 1000
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weeklySummaryKey turns on the daemon's weekly summary: "notify" for a
+// desktop notification, "email" to mail it to mail_to, "no" (the default)
+// for none.
+const weeklySummaryKey = "weekly_summary"
+
+const (
+	summaryNotify = "notify"
+	summaryEmail  = "email"
+	summaryPeriod = 7 * 24 * time.Hour
+)
+
+// stateSummarySent is when the daemon last sent the weekly summary.
+const stateSummarySent = "summary_sent"
+
+// aliasSummary is a week of alias hygiene: what was made, what was burned
+// and which review dates are coming up.
+type aliasSummary struct {
+	Since     time.Time      `json:"since"`
+	Generated int            `json:"generated"`
+	Burned    int            `json:"burned"`
+	Due       []historyEntry `json:"due"`
+}
+
+// summarize builds the summary for the week before now from the raw
+// history, so aliases generated and then deleted within it still count.
+func summarize(now time.Time) (aliasSummary, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return aliasSummary{}, err
+	}
+	s := aliasSummary{Since: now.Add(-summaryPeriod), Due: []historyEntry{}}
+	for _, e := range entries {
+		if e.Created.After(s.Since) {
+			s.Generated++
+		}
+		if e.Deleted != nil {
+			if e.Deleted.After(s.Since) {
+				s.Burned++
+			}
+			continue
+		}
+		if e.Review != nil && e.Review.Before(now.Add(summaryPeriod)) {
+			s.Due = append(s.Due, e)
+		}
+	}
+	return s, nil
+}
+
+func (s aliasSummary) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "This week: %d generated, %d burned.", s.Generated, s.Burned)
+	switch len(s.Due) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&b, " 1 alias is due for review: %s.", orDefault(s.Due[0].Label, s.Due[0].Address))
+	default:
+		fmt.Fprintf(&b, " %d aliases are due for review; see 'ddg remind list'.", len(s.Due))
+	}
+	return b.String()
+}
+
+// doSummary prints the summary the daemon would send now.
+func doSummary(args []string) {
+	for _, a := range args {
+		unknownArg(a)
+	}
+	s, err := summarize(time.Now())
+	if err != nil {
+		exitErr(err)
+	}
+	out.Result(s, s.text())
+}
+
+// weeklySummaries sends the summary once a week while the daemon runs,
+// if weekly_summary is set. The first one goes out a week after it is
+// turned on; restarts don't reset the clock.
+func weeklySummaries(stop <-chan struct{}) {
+	defer recoverCrash()
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		if cfg, _ := readConfig(); cfg.WeeklySummary == summaryNotify || cfg.WeeklySummary == summaryEmail {
+			last, err := time.Parse(time.RFC3339, getState(stateSummarySent))
+			if err != nil {
+				_ = setState(stateSummarySent, time.Now().Format(time.RFC3339))
+			} else if time.Since(last) >= summaryPeriod {
+				sendSummary(cfg)
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func sendSummary(cfg conf) {
+	const title = "Your week in Duck aliases"
+	s, err := summarize(time.Now())
+	if err == nil && cfg.WeeklySummary == summaryEmail {
+		err = sendMail(cfg, title, s.text())
+	} else if err == nil && !notify(title, s.text()) {
+		err = fmt.Errorf("no notification could be shown")
+	}
+	audit("summary.send", err, map[string]string{"generated": fmt.Sprint(s.Generated), "burned": fmt.Sprint(s.Burned)})
+	if err != nil {
+		logf(priWarning, "weekly summary: %v", err)
+		return
+	}
+	_ = setState(stateSummarySent, time.Now().Format(time.RFC3339))
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "fmt"
+
+func openSyslog() (daemonLogger, error) {
+	return nil, fmt.Errorf("syslog isn't available on this platform")
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import "log/syslog"
+
+type syslogLogger struct {
+	w *syslog.Writer
+}
+
+func openSyslog() (daemonLogger, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, "ddg")
+	if err != nil {
+		return nil, err
+	}
+	return syslogLogger{w: w}, nil
+}
+
+func (s syslogLogger) log(pri int, msg string) error {
+	switch {
+	case pri <= priErr:
+		return s.w.Err(msg)
+	case pri == priWarning:
+		return s.w.Warning(msg)
+	default:
+		return s.w.Info(msg)
+	}
+}
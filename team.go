@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	teamDirKey    = "team_dir"
+	teamMemberKey = "team_member"
+)
+
+// Team mode shares who generated which alias for which vendor through a
+// directory every member syncs (Dropbox, Syncthing, a network share...).
+// Each member appends only to their own <member>.jsonl there, so syncing
+// never conflicts and everyone else's history is read-only. Tokens and
+// notes are never written to the team directory.
+
+func doTeam(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg team join <dir> [--as <name>] [--publish] | leave | history [<search>] | status"))
+	}
+	switch args[0] {
+	case "join":
+		teamJoin(args[1:])
+	case "leave":
+		teamLeave()
+	case "history", "list":
+		teamHistory(args[1:])
+	case "status":
+		teamStatus()
+	default:
+		exitErr(fmt.Errorf("usage: unknown team command %s", args[0]))
+	}
+}
+
+func teamJoin(args []string) {
+	dir, member, publish := "", "", false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--as" && i+1 < len(args) {
+			member = args[i+1]
+			i++
+		} else if args[i] == "--publish" {
+			publish = true
+		} else if dir == "" && !strings.HasPrefix(args[i], "--") {
+			dir = args[i]
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if dir == "" {
+		exitErr(fmt.Errorf("usage: ddg team join <dir> [--as <name>] [--publish]"))
+	}
+	requireWritable("join a team")
+	cfg, err := loadSettings(false)
+	if err != nil {
+		exitErr(err)
+	}
+	dir, err = filepath.Abs(expandHome(dir))
+	if err != nil {
+		exitErr(err)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		exitErr(fmt.Errorf("%s is not a directory; create or sync it first", dir))
+	}
+	if member == "" {
+		member = defaultTeamMember()
+	}
+	if err := checkProfileName(strings.ToLower(member)); err != nil {
+		exitErr(fmt.Errorf("invalid member name %q (use lowercase letters, digits, - and _)", member))
+	}
+	cfg.TeamDir, cfg.TeamMember = dir, strings.ToLower(member)
+
+	published := 0
+	if publish {
+		entries, err := liveHistory()
+		if err != nil {
+			exitErr(err)
+		}
+		// Joining twice must not share everything twice.
+		shared, _ := readHistoryFile(filepath.Join(cfg.TeamDir, cfg.TeamMember+".jsonl"))
+		for _, e := range entries {
+			if _, ok := findHistory(shared, e.Address); ok {
+				continue
+			}
+			if err := publishToTeam(cfg, e); err != nil {
+				exitErr(err)
+			}
+			published++
+		}
+	}
+	err = writeConfig(cfg)
+	audit("team.join", err, map[string]string{"dir": dir, "member": cfg.TeamMember})
+	if err != nil {
+		exitErr(err)
+	}
+	msg := fmt.Sprintf("Joined team at %s as %s.", dir, cfg.TeamMember)
+	if published > 0 {
+		msg += fmt.Sprintf(" Published %d existing aliases.", published)
+	}
+	out.Done(msg, map[string]interface{}{"dir": dir, "member": cfg.TeamMember, "published": published})
+}
+
+func teamLeave() {
+	requireWritable("leave a team")
+	cfg, err := loadSettings(false)
+	if err != nil {
+		exitErr(err)
+	}
+	if cfg.TeamDir == "" {
+		exitErr(fmt.Errorf("not in a team"))
+	}
+	dir := cfg.TeamDir
+	cfg.TeamDir, cfg.TeamMember = "", ""
+	err = writeConfig(cfg)
+	audit("team.leave", err, map[string]string{"dir": dir})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done("Left the team. Aliases already shared stay in "+dir+".", map[string]string{"dir": dir})
+}
+
+// teamRow is one line of 'ddg team history'.
+type teamRow struct {
+	By      string   `json:"by"`
+	Address string   `json:"address"`
+	Label   string   `json:"label,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Created string   `json:"created"`
+}
+
+func teamHistory(args []string) {
+	search := strings.ToLower(strings.Join(args, " "))
+	cfg, err := readConfig()
+	if err != nil || cfg.TeamDir == "" {
+		exitErr(fmt.Errorf("not in a team; join one with 'ddg team join <dir>'"))
+	}
+	entries, err := readTeamHistory(cfg.TeamDir)
+	if err != nil {
+		exitErr(err)
+	}
+	var rows []teamRow
+	for _, e := range entries {
+		if search != "" && !strings.Contains(strings.ToLower(e.Address+" "+e.Label+" "+e.By+" "+strings.Join(e.Tags, " ")), search) {
+			continue
+		}
+		rows = append(rows, teamRow{By: e.By, Address: e.Address, Label: e.Label, Tags: e.Tags, Created: e.Created.Local().Format("2006-01-02")})
+	}
+	printList(rows, func(r teamRow) string {
+		return fmt.Sprintf("%s  %-12s %s\t%s", r.Created, r.By, cyan(r.Address), r.Label)
+	})
+}
+
+func teamStatus() {
+	cfg, err := readConfig()
+	if err != nil {
+		exitErr(err)
+	}
+	if cfg.TeamDir == "" {
+		out.Result(map[string]interface{}{"team": false}, "Not in a team.")
+		return
+	}
+	entries, err := readTeamHistory(cfg.TeamDir)
+	if err != nil {
+		exitErr(err)
+	}
+	counts := map[string]int{}
+	for _, e := range entries {
+		counts[e.By]++
+	}
+	members := topCounts(counts, len(counts))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Team directory: %s\nYou are: %s\nShared aliases: %d\n", cfg.TeamDir, cfg.TeamMember, len(entries))
+	for _, m := range members {
+		fmt.Fprintf(&b, "  %-12s %d\n", m.Name, m.Count)
+	}
+	out.Result(map[string]interface{}{"team": true, "dir": cfg.TeamDir, "member": cfg.TeamMember, "total": len(entries), "members": members},
+		strings.TrimRight(b.String(), "\n"))
+}
+
+// publishToTeam appends e to this member's file in the team directory,
+// without notes, which may be private.
+func publishToTeam(cfg conf, e historyEntry) error {
+	if cfg.TeamDir == "" {
+		return nil
+	}
+	e.Notes = ""
+	e.Fields = nil
+	e.By = cfg.TeamMember
+	return appendHistoryFile(filepath.Join(cfg.TeamDir, cfg.TeamMember+".jsonl"), e)
+}
+
+// readTeamHistory reads every member's file, oldest first. Each entry's By
+// is the file it came from, whatever the file claims.
+func readTeamHistory(dir string) ([]historyEntry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var all []historyEntry
+	for _, f := range files {
+		entries, err := readHistoryFile(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", f, err)
+			continue
+		}
+		member := strings.TrimSuffix(filepath.Base(f), ".jsonl")
+		for _, e := range entries {
+			e.By = member
+			all = append(all, e)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Created.Before(all[j].Created) })
+	return all, nil
+}
+
+func defaultTeamMember() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name := strings.ToLower(u.Username)
+		// DOMAIN\user on Windows.
+		if i := strings.LastIndexAny(name, `\/`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	return "me"
+}
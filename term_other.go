@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// enableVT reports whether the terminal understands ANSI escapes. Outside
+// Windows there is nothing to switch on.
+func enableVT() bool {
+	return true
+}
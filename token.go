@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func doToken(args []string) {
+	if len(args) == 0 {
+		exitErr(fmt.Errorf("usage: ddg token <show|set|validate|delete|migrate>"))
+	}
+	switch strings.ToLower(args[0]) {
+	case "show":
+		tokenShow(args[1:])
+	case "set":
+		tokenSet(args[1:])
+	case "validate":
+		tokenValidate()
+	case "delete":
+		tokenDelete()
+	case "migrate":
+		tokenMigrate(args[1:])
+	default:
+		exitErr(fmt.Errorf("usage: unknown token command %s", args[0]))
+	}
+}
+
+func tokenShow(args []string) {
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	reveal := len(args) > 0 && args[0] == "--reveal"
+	shown := maskToken(cfg.APIKey)
+	if reveal {
+		shown = cfg.APIKey
+	}
+	out.Result(map[string]string{"api_key": shown, "keystore": keystoreName(cfg)},
+		fmt.Sprintf("API key: %s (stored in %s)", emptyToDash(shown), keystoreName(cfg)))
+}
+
+func tokenSet(args []string) {
+	requireWritable("change the API key")
+	cfg, err := readConfig()
+	if err != nil && !os.IsNotExist(err) {
+		exitErr(err)
+	}
+	token := ""
+	if len(args) > 0 {
+		token = cleanSecret(args[0])
+	} else {
+		token = ask(prompt{
+			Label:  "Enter your API key",
+			Env:    "DDG_API_KEY",
+			Hint:   "pass it as 'ddg token set <key>'",
+			Secret: true,
+		})
+	}
+	if token == "" {
+		exitErr(fmt.Errorf("no API key provided"))
+	}
+	err = saveToken(&cfg, token)
+	audit("token.set", err, map[string]string{"keystore": keystoreName(cfg)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done(fmt.Sprintf("API key saved to %s.", keystoreName(cfg)), map[string]string{"keystore": keystoreName(cfg)})
+}
+
+func tokenValidate() {
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	if err := checkToken(cfg.APIKey); err != nil {
+		if respErr, ok := err.(*httpError); ok && respErr.StatusCode == 401 {
+			fmt.Fprintln(os.Stderr, red("Error! Invalid token"))
+			os.Exit(1)
+		}
+		exitErr(err)
+	}
+	out.Done("API key is valid.", map[string]bool{"valid": true})
+}
+
+func tokenDelete() {
+	requireWritable("delete the API key")
+	cfg, err := readConfig()
+	if err != nil {
+		exitErr(err)
+	}
+	if name := keystoreName(cfg); name != keystoreFile {
+		ks, err := openKeystore(name)
+		if err != nil {
+			exitErr(err)
+		}
+		if err := ks.Delete(); err != nil {
+			exitErr(fmt.Errorf("removing API key from %s: %w", name, err))
+		}
+	}
+	cfg.APIKey = ""
+	_ = setState(stateAccount, "")
+	_ = setState(stateToken, "")
+	err = writeConfig(cfg)
+	audit("token.delete", err, map[string]string{"keystore": keystoreName(cfg)})
+	if err != nil {
+		exitErr(err)
+	}
+	out.Done("API key deleted. Run 'ddg token set' to add a new one.", map[string]bool{"deleted": true})
+}
+
+func tokenMigrate(args []string) {
+	requireWritable("move the API key")
+	to := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--to" && i+1 < len(args) {
+			to = strings.ToLower(args[i+1])
+			i++
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	if to != keystoreFile && to != keystoreKeychain {
+		exitErr(fmt.Errorf("usage: ddg token migrate --to <file|keychain>"))
+	}
+
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		exitErr(err)
+	}
+	from := keystoreName(cfg)
+	if from == to {
+		out.Done(fmt.Sprintf("API key is already stored in %s.", to), map[string]string{"keystore": to})
+		return
+	}
+
+	// Write to the new keystore first so a failure never loses the key.
+	cfg.Keystore = to
+	err = saveToken(&cfg, cfg.APIKey)
+	audit("token.migrate", err, map[string]string{"from": from, "to": to})
+	if err != nil {
+		exitErr(err)
+	}
+	if from != keystoreFile {
+		if ks, err := openKeystore(from); err == nil {
+			if err := ks.Delete(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not remove old key from %s: %v\n", from, err)
+			}
+		}
+	}
+	out.Done(fmt.Sprintf("API key moved from %s to %s.", from, to), map[string]string{"from": from, "to": to})
+}
+
+// checkToken asks the dashboard endpoint whether apiKey is accepted, without
+// generating an address.
+func checkToken(apiKey string) error {
+	err := apiFor(apiKey).CheckToken(context.Background())
+	noteTokenStatus(err)
+	return err
+}
+
+func maskToken(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", len(s)-4)
+}
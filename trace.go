@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+// With OTEL_EXPORTER_OTLP_ENDPOINT set, ddg sends a span for every API call
+// and every read and write of its history, audit log and state to that
+// OpenTelemetry collector, over OTLP/HTTP with JSON, so the CLI and the
+// daemon show up in existing traces without ddg depending on OTel. The
+// collector's host is subject to egress like a notification sink.
+const (
+	otlpEndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	otelServiceNameEnv    = "OTEL_SERVICE_NAME"
+
+	otlpTimeout = 2 * time.Second
+)
+
+// tracer is nil unless configureTracing found a collector.
+var tracer ddg.Tracer
+
+// otlpClient is not apiClient, so fixtures don't answer for the collector,
+// but egress is checked all the same.
+var otlpClient = &http.Client{
+	Timeout: otlpTimeout,
+	Transport: &http.Transport{
+		Proxy:               egressProxy,
+		DialContext:         (&cachingDialer{}).DialContext,
+		TLSHandshakeTimeout: otlpTimeout,
+	},
+}
+
+func configureTracing() {
+	endpoint := os.Getenv(otlpTracesEndpointEnv)
+	if endpoint == "" {
+		if base := os.Getenv(otlpEndpointEnv); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+	tracer = &otlpTracer{endpoint: endpoint, service: orDefault(os.Getenv(otelServiceNameEnv), "ddg")}
+}
+
+// storageSpan starts a span for reading or writing one of ddg's files, named
+// by its base name only, so home directories stay out of traces. The file
+// functions take no context, so these spans start traces of their own.
+func storageSpan(name, path string) ddg.Span {
+	if tracer == nil {
+		return noSpan{}
+	}
+	_, span := tracer.Start(context.Background(), name, ddg.Attr{Key: "ddg.file", Value: filepath.Base(path)})
+	return span
+}
+
+type noSpan struct{}
+
+func (noSpan) SetAttributes(...ddg.Attr) {}
+func (noSpan) End(error)                 {}
+
+// otlpTracer sends each span as it ends: the CLI exits from all over the
+// place, and a batch waiting for a flush would be lost. After one failure
+// it warns and stops, rather than keep every file access waiting on a
+// collector that isn't there.
+type otlpTracer struct {
+	endpoint string
+	service  string
+	failed   atomic.Bool
+}
+
+type otlpSpanKey struct{}
+
+type otlpSpan struct {
+	t        *otlpTracer
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []ddg.Attr
+}
+
+func (t *otlpTracer) Start(ctx context.Context, name string, attrs ...ddg.Attr) (context.Context, ddg.Span) {
+	s := &otlpSpan{t: t, name: name, start: time.Now(), attrs: attrs}
+	s.spanID, _ = randomHex(8)
+	if parent, ok := ctx.Value(otlpSpanKey{}).(*otlpSpan); ok {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		s.traceID, _ = randomHex(16)
+	}
+	return context.WithValue(ctx, otlpSpanKey{}, s), s
+}
+
+func (s *otlpSpan) SetAttributes(attrs ...ddg.Attr) {
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+func (s *otlpSpan) End(err error) {
+	s.mu.Lock()
+	attrs := s.attrs
+	s.mu.Unlock()
+	if s.t.failed.Load() {
+		return
+	}
+	if err := s.t.export(s, attrs, time.Now(), err); err != nil && !s.t.failed.Swap(true) {
+		fmt.Fprintf(os.Stderr, "Warning: could not send traces to %s, so tracing is off: %v\n", s.t.endpoint, err)
+	}
+}
+
+// OTLP/HTTP JSON, as far as ddg needs it.
+type (
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"` // int64s are strings in OTLP JSON
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpanJSON struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		Name         string     `json:"name"`
+		Kind         int        `json:"kind"` // 1 internal, 3 client
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []otlpAttr `json:"attributes,omitempty"`
+		Status       otlpStatus `json:"status"`
+	}
+)
+
+func (t *otlpTracer) export(s *otlpSpan, attrs []ddg.Attr, end time.Time, spanErr error) error {
+	span := otlpSpanJSON{
+		TraceID: s.traceID, SpanID: s.spanID, ParentSpanID: s.parentID, Name: s.name, Kind: 1,
+		Start:  strconv.FormatInt(s.start.UnixNano(), 10),
+		End:    strconv.FormatInt(end.UnixNano(), 10),
+		Status: otlpStatus{Code: 1},
+	}
+	for _, a := range attrs {
+		if strings.HasPrefix(a.Key, "http.") {
+			span.Kind = 3 // the client's API calls
+		}
+		span.Attributes = append(span.Attributes, otlpAttribute(a))
+	}
+	if spanErr != nil {
+		span.Status = otlpStatus{Code: 2, Message: redactSecrets(spanErr.Error())}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttr{
+					otlpAttribute(ddg.Attr{Key: "service.name", Value: t.service}),
+					otlpAttribute(ddg.Attr{Key: "service.version", Value: version}),
+				},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": strings.TrimPrefix(repoURL, "https://"), "version": version},
+				"spans": []otlpSpanJSON{span},
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := otlpClient.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+func otlpAttribute(a ddg.Attr) otlpAttr {
+	var v otlpValue
+	switch x := a.Value.(type) {
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+	return otlpAttr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const dnsCacheTTL = 5 * time.Minute
+
+// apiClient is shared by every call to DuckDuckGo. Keeping one transport
+// around lets a long-running daemon reuse TLS connections, and the caching
+// dialer saves a DNS lookup whenever a new connection is needed.
+var apiClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy:               egressProxy,
+		DialContext:         (&cachingDialer{}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     5 * time.Minute,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// egressProxy is http.ProxyFromEnvironment that first checks the request's
+// own host: through a proxy, the dialer only sees the proxy.
+func egressProxy(r *http.Request) (*url.URL, error) {
+	if err := checkEgress(r.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return http.ProxyFromEnvironment(r)
+}
+
+// cachingDialer resolves each host once per dnsCacheTTL and dials the cached
+// addresses, re-resolving immediately if none of them answer.
+type cachingDialer struct {
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func (d *cachingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	// Checked here, under every request, rather than per caller.
+	if err := checkEgress(host); err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	if addrs, ok := d.lookupCached(host); ok {
+		if conn, err := dialFirst(ctx, dialer, network, addrs, port); err == nil {
+			return conn, nil
+		}
+		d.forget(host)
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialFirst(ctx, dialer, network, addrs, port)
+	if err == nil {
+		d.remember(host, addrs)
+	}
+	return conn, err
+}
+
+func dialFirst(ctx context.Context, dialer *net.Dialer, network string, addrs []string, port string) (net.Conn, error) {
+	var lastErr error
+	for _, a := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (d *cachingDialer) lookupCached(host string) ([]string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.cache[host]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.addrs, true
+}
+
+func (d *cachingDialer) remember(host string, addrs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cache == nil {
+		d.cache = map[string]dnsEntry{}
+	}
+	d.cache[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(dnsCacheTTL)}
+}
+
+func (d *cachingDialer) forget(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.cache, host)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	trashDirName      = "trash"
+	trashManifestName = "manifest.json"
+
+	// trashRetention is how long discarded data can be brought back.
+	trashRetention = 30 * 24 * time.Hour
+)
+
+// trashManifest records what a trash entry holds and where it came from.
+type trashManifest struct {
+	Created time.Time   `json:"created"`
+	Action  string      `json:"action"`
+	Files   []trashFile `json:"files"`
+}
+
+type trashFile struct {
+	Name string `json:"name"` // file name inside the trash entry
+	Path string `json:"path"` // where it was, and where restore puts it
+}
+
+func trashDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, trashDirName), nil
+}
+
+// saveToTrash copies paths into a new timestamped trash entry before action
+// overwrites or removes them, and prunes entries past their retention.
+// Missing files are skipped; an error means the caller must not go on.
+func saveToTrash(action string, paths ...string) (string, error) {
+	root, err := trashDir()
+	if err != nil {
+		return "", err
+	}
+	pruneTrash(root)
+
+	m := trashManifest{Created: time.Now(), Action: action}
+	id := m.Created.Format("20060102-150405.000")
+	dir := filepath.Join(root, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	for i, p := range paths {
+		b, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%d-%s", i, filepath.Base(p))
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0600); err != nil {
+			return "", err
+		}
+		m.Files = append(m.Files, trashFile{Name: name, Path: p})
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return id, os.WriteFile(filepath.Join(dir, trashManifestName), b, 0600)
+}
+
+// pruneTrash removes entries older than trashRetention. Failures are left
+// for the next run.
+func pruneTrash(root string) {
+	for _, t := range readTrash(root) {
+		if time.Since(t.Created) > trashRetention {
+			os.RemoveAll(filepath.Join(root, t.ID))
+		}
+	}
+}
+
+type trashEntry struct {
+	ID string `json:"id"`
+	trashManifest
+}
+
+// readTrash lists trash entries, newest first.
+func readTrash(root string) []trashEntry {
+	dirs, _ := os.ReadDir(root)
+	var entries []trashEntry
+	for _, d := range dirs {
+		b, err := os.ReadFile(filepath.Join(root, d.Name(), trashManifestName))
+		if err != nil {
+			continue
+		}
+		var m trashManifest
+		if json.Unmarshal(b, &m) == nil {
+			entries = append(entries, trashEntry{ID: d.Name(), trashManifest: m})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.After(entries[j].Created) })
+	return entries
+}
+
+// doRestoreTrash lists the trash, or puts an entry's files back. Whatever
+// the restore overwrites goes to the trash first, so it can be undone too.
+func doRestoreTrash(args []string) {
+	root, err := trashDir()
+	if err != nil {
+		exitErr(err)
+	}
+	entries := readTrash(root)
+	if len(args) == 0 {
+		printList(entries, func(t trashEntry) string {
+			left := trashRetention - time.Since(t.Created)
+			return fmt.Sprintf("%s  %-15s %d file(s), kept %d more day(s)", t.ID, t.Action, len(t.Files), int(left.Hours()/24))
+		})
+		if len(entries) == 0 && !out.machine() {
+			fmt.Println("The trash is empty.")
+		}
+		return
+	}
+	if len(args) != 1 {
+		exitErr(fmt.Errorf("usage: ddg restore-trash [<id>]"))
+	}
+	requireWritable("restore from the trash")
+	var t *trashEntry
+	for i := range entries {
+		if entries[i].ID == args[0] {
+			t = &entries[i]
+		}
+	}
+	if t == nil {
+		exitErr(fmt.Errorf("no trash entry %q; run 'ddg restore-trash' to list them", args[0]))
+	}
+
+	paths := make([]string, len(t.Files))
+	for i, f := range t.Files {
+		paths[i] = f.Path
+	}
+	undo, err := saveToTrash("restore-trash", paths...)
+	if err != nil {
+		exitErr(fmt.Errorf("saving current files to the trash: %w", err))
+	}
+	for _, f := range t.Files {
+		b, err := os.ReadFile(filepath.Join(root, t.ID, f.Name))
+		if err == nil {
+			err = os.WriteFile(f.Path, b, 0600)
+		}
+		if err != nil {
+			audit("trash.restore", err, map[string]string{"id": t.ID})
+			exitErr(err)
+		}
+	}
+	audit("trash.restore", nil, map[string]string{"id": t.ID, "files": strings.Join(paths, ",")})
+	out.Done(fmt.Sprintf("Restored %s (%s). The files it replaced are in the trash as %s.", t.ID, t.Action, undo),
+		map[string]string{"id": t.ID, "replaced": undo})
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+const (
+	urlScheme      = "ddg"
+	desktopFile    = "ddg-url-handler.desktop"
+	macHandlerApp  = "DuckDuckGone URL Handler.app"
+	windowsURLKey  = `HKCU\Software\Classes\ddg`
+	lsregisterPath = "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+)
+
+// doRegisterURLHandler makes the OS hand ddg:// links to 'ddg handle-url':
+// a .desktop file on Linux, a small AppleScript applet on macOS (which only
+// delivers URLs to app bundles), and a per-user registry key on Windows.
+func doRegisterURLHandler(args []string) {
+	remove := false
+	for _, a := range args {
+		if a == "--remove" {
+			remove = true
+		} else {
+			unknownArg(a)
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		exitErr(err)
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		exitErr(err)
+	}
+
+	var where string
+	switch runtime.GOOS {
+	case "darwin":
+		where, err = registerURLHandlerMac(self, remove)
+	case "windows":
+		where, err = registerURLHandlerWindows(self, remove)
+	default:
+		where, err = registerURLHandlerXDG(self, remove)
+	}
+	action := "url_handler.register"
+	if remove {
+		action = "url_handler.remove"
+	}
+	audit(action, err, map[string]string{"location": where})
+	if err != nil {
+		exitErr(err)
+	}
+	if remove {
+		out.Done("ddg:// handler removed ("+where+").", map[string]string{"location": where})
+		return
+	}
+	out.Done("ddg:// links now open ddg ("+where+"). Try: ddg://gen?label=example.com", map[string]string{"location": where})
+}
+
+func registerURLHandlerXDG(self string, remove bool) (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	dir = filepath.Join(dir, "applications")
+	path := filepath.Join(dir, desktopFile)
+	if remove {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return path, err
+		}
+		return path, nil
+	}
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=DuckDuckGone
+Comment=Generate Duck aliases from ddg:// links
+Exec=%s handle-url %%u
+NoDisplay=true
+Terminal=false
+MimeType=x-scheme-handler/%s;
+`, desktopQuote(self), urlScheme)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return path, err
+	}
+	if err := os.WriteFile(path, []byte(entry), 0644); err != nil {
+		return path, err
+	}
+	if !hasCommand("xdg-mime") {
+		return path, fmt.Errorf("wrote %s, but xdg-mime is missing to make it the ddg:// handler", path)
+	}
+	if msg, err := exec.Command("xdg-mime", "default", desktopFile, "x-scheme-handler/"+urlScheme).CombinedOutput(); err != nil {
+		return path, fmt.Errorf("xdg-mime: %v: %s", err, strings.TrimSpace(string(msg)))
+	}
+	if hasCommand("update-desktop-database") {
+		_ = exec.Command("update-desktop-database", dir).Run()
+	}
+	return path, nil
+}
+
+// desktopQuote quotes an Exec argument per the Desktop Entry spec.
+func desktopQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(s) + `"`
+}
+
+func registerURLHandlerMac(self string, remove bool) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	app := filepath.Join(home, "Applications", macHandlerApp)
+	if remove {
+		if _, err := os.Stat(app); err == nil {
+			_ = exec.Command(lsregisterPath, "-u", app).Run()
+		}
+		return app, os.RemoveAll(app)
+	}
+
+	script := fmt.Sprintf(`on open location theURL
+	do shell script quoted form of %s & " handle-url " & quoted form of theURL
+end open location
+`, appleScriptString(self))
+	src, err := os.CreateTemp("", "ddg-url-*.applescript")
+	if err != nil {
+		return app, err
+	}
+	defer os.Remove(src.Name())
+	if _, err := src.WriteString(script); err != nil {
+		src.Close()
+		return app, err
+	}
+	src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(app), 0755); err != nil {
+		return app, err
+	}
+	plist := filepath.Join(app, "Contents", "Info.plist")
+	steps := [][]string{
+		{"osacompile", "-o", app, src.Name()},
+		{"plutil", "-insert", "CFBundleURLTypes", "-json", `[{"CFBundleURLName":"DuckDuckGone","CFBundleURLSchemes":["` + urlScheme + `"]}]`, plist},
+		{"plutil", "-replace", "LSUIElement", "-bool", "true", plist},
+		{lsregisterPath, "-f", app},
+	}
+	for _, s := range steps {
+		if msg, err := exec.Command(s[0], s[1:]...).CombinedOutput(); err != nil {
+			return app, fmt.Errorf("%s: %v: %s", filepath.Base(s[0]), err, strings.TrimSpace(string(msg)))
+		}
+	}
+	return app, nil
+}
+
+func registerURLHandlerWindows(self string, remove bool) (string, error) {
+	var steps [][]string
+	if remove {
+		steps = [][]string{{"delete", windowsURLKey, "/f"}}
+	} else {
+		steps = [][]string{
+			{"add", windowsURLKey, "/ve", "/d", "URL:DuckDuckGone", "/f"},
+			{"add", windowsURLKey, "/v", "URL Protocol", "/d", "", "/f"},
+			{"add", windowsURLKey + `\shell\open\command`, "/ve", "/d", `"` + self + `" handle-url "%1"`, "/f"},
+		}
+	}
+	for _, s := range steps {
+		if msg, err := exec.Command("reg", s...).CombinedOutput(); err != nil {
+			return windowsURLKey, fmt.Errorf("reg %s: %v: %s", s[0], err, strings.TrimSpace(string(msg)))
+		}
+	}
+	return windowsURLKey, nil
+}
+
+// doHandleURL runs a ddg:// link. There is usually no terminal, so the
+// outcome is reported as a notification too.
+//
+//	ddg://gen?label=example.com&tags=shopping,uk
+func doHandleURL(args []string) {
+	if len(args) != 1 {
+		exitErr(fmt.Errorf("usage: ddg handle-url 'ddg://gen?label=...'"))
+	}
+	fail := func(err error) {
+		notify("ddg", "Error: "+err.Error())
+		exitErr(err)
+	}
+	u, err := url.Parse(args[0])
+	if err != nil {
+		fail(err)
+	}
+	if u.Scheme != urlScheme {
+		fail(fmt.Errorf("not a %s:// link: %s", urlScheme, args[0]))
+	}
+	action := strings.Trim(u.Host+u.Opaque+u.Path, "/")
+	if action != "gen" && action != "generate" {
+		fail(fmt.Errorf("unknown action %q (only gen is supported)", action))
+	}
+	if isReadOnly() {
+		fail(fmt.Errorf("read-only mode"))
+	}
+	q := u.Query()
+	e := historyEntry{Label: cleanURLField(q.Get("label")), Notes: "via ddg:// link"}
+	for _, t := range strings.Split(q.Get("tags"), ",") {
+		if t = cleanURLField(t); t != "" {
+			e.Tags = append(e.Tags, t)
+		}
+	}
+
+	cfg, err := ensureConfig(false)
+	if err != nil {
+		fail(err)
+	}
+	e, err = generateAlias(cfg, e)
+	if err != nil {
+		fail(err)
+	}
+	msg := e.Address
+	if _, err := copyToClipboard(cfg, e.Address); err == nil {
+		msg += " copied to the clipboard"
+	}
+	if e.Label != "" {
+		msg += " (" + e.Label + ")"
+	}
+	notify("New Duck alias", msg)
+	fmt.Println(e.Address)
+}
+
+// cleanURLField drops control characters and caps the length of values
+// that any web page could have put in a link.
+func cleanURLField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if r := []rune(s); len(r) > 100 {
+		s = string(r[:100])
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+//go:build js && wasm
+
+// Command wasm exposes package ddg to JavaScript, so a browser extension or
+// web companion makes exactly the same requests and gets exactly the same
+// errors as the CLI. Build it with 'make wasm'; it registers:
+//
+//	ddgGenerate(token)        Promise resolving to the new address
+//	ddgCheckToken(token)      Promise resolving to true, rejecting on 401
+//	ddgValidateAlias(s)       "" if s is a plausible alias, else the reason
+//	ddgNormalizeAlias(s)      s in canonical form
+//
+// Rejections carry {message, status, retryAfter} (retryAfter in seconds).
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall/js"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+func main() {
+	js.Global().Set("ddgGenerate", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		return promise(func() (interface{}, error) {
+			c := &ddg.Client{Token: arg(args, 0)}
+			return c.Generate(context.Background())
+		})
+	}))
+	js.Global().Set("ddgCheckToken", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		return promise(func() (interface{}, error) {
+			c := &ddg.Client{Token: arg(args, 0)}
+			return true, c.CheckToken(context.Background())
+		})
+	}))
+	js.Global().Set("ddgValidateAlias", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		if err := ddg.ValidateAlias(arg(args, 0)); err != nil {
+			return err.Error()
+		}
+		return ""
+	}))
+	js.Global().Set("ddgNormalizeAlias", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		return ddg.NormalizeAlias(arg(args, 0))
+	}))
+	select {}
+}
+
+func arg(args []js.Value, i int) string {
+	if i < len(args) && args[i].Type() == js.TypeString {
+		return args[i].String()
+	}
+	return ""
+}
+
+// promise runs fn off the JS event loop, since net/http blocks on fetch.
+func promise(fn func() (interface{}, error)) js.Value {
+	handler := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			v, err := fn()
+			if err != nil {
+				reject.Invoke(jsError(err))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	defer handler.Release()
+	return js.Global().Get("Promise").New(handler)
+}
+
+func jsError(err error) js.Value {
+	e := js.Global().Get("Error").New(err.Error())
+	var apiErr *ddg.APIError
+	if errors.As(err, &apiErr) {
+		e.Set("status", apiErr.StatusCode)
+		e.Set("retryAfter", apiErr.RetryAfter.Seconds())
+	}
+	return e
+}
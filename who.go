@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// doWho answers "what did I give this address to?".
+func doWho(args []string) {
+	if len(args) != 1 {
+		exitErr(fmt.Errorf("usage: ddg who <address>"))
+	}
+	entries, err := readHistory()
+	if err != nil {
+		exitErr(err)
+	}
+	e, ok := findHistory(entries, args[0])
+	if !ok {
+		exitErr(fmt.Errorf("%s is not in your history", args[0]))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", cyan(e.Address))
+	fmt.Fprintf(&b, "Label:   %s\n", emptyToDash(e.Label))
+	fmt.Fprintf(&b, "Created: %s", e.Created.Local().Format("2006-01-02 15:04"))
+	if len(e.Tags) > 0 {
+		fmt.Fprintf(&b, "\nTags:    %s", strings.Join(e.Tags, ", "))
+	}
+	if e.Notes != "" {
+		fmt.Fprintf(&b, "\nNotes:   %s", e.Notes)
+	}
+	for _, k := range sortedKeys(e.Fields) {
+		fmt.Fprintf(&b, "\n%s: %s", k, e.Fields[k])
+	}
+	if e.Deleted != nil {
+		fmt.Fprintf(&b, "\nDeleted: %s ('ddg undelete %s' restores it)", e.Deleted.Local().Format("2006-01-02 15:04"), e.Address)
+	}
+	if e.Review != nil {
+		fmt.Fprintf(&b, "\nReview:  %s", e.Review.Local().Format("2006-01-02"))
+	}
+	out.Result(e, b.String())
+}
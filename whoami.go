@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mikkmer/duckduckgone/ddg"
+)
+
+const (
+	// accountTTL is how long account details are used without asking again.
+	accountTTL = 10 * time.Minute
+	// accountStaleLimit is how long they're still shown, marked as such,
+	// when DuckDuckGo can't be reached.
+	accountStaleLimit = 24 * time.Hour
+)
+
+// stateAccount caches the account details in the state file. Changing the
+// API key clears it.
+const stateAccount = "account"
+
+// cachedAccount is account details and when they were fetched.
+type cachedAccount struct {
+	ddg.Account
+	Fetched time.Time `json:"fetched"`
+}
+
+func (c cachedAccount) fresh() bool { return time.Since(c.Fetched) < accountTTL }
+
+func (c cachedAccount) usable() bool { return time.Since(c.Fetched) < accountStaleLimit }
+
+// fetchAccount asks DuckDuckGo for the account details, falling back to
+// cached ones that are too old but not yet unusable if that fails. stale
+// reports whether the fallback was used.
+func fetchAccount(token string, cached cachedAccount) (acct cachedAccount, stale bool, err error) {
+	a, err := apiFor(token).Account(context.Background())
+	noteTokenStatus(err)
+	if err == nil {
+		return cachedAccount{Account: a, Fetched: time.Now()}, false, nil
+	}
+	if cached.usable() && !ddg.Unauthorized(err) {
+		return cached, true, nil
+	}
+	return cachedAccount{}, false, err
+}
+
+func doWhoami(args []string) {
+	refresh := false
+	for _, a := range args {
+		if a == "--refresh" {
+			refresh = true
+		} else {
+			unknownArg(a)
+		}
+	}
+	var cached cachedAccount
+	if s := getState(stateAccount); s != "" {
+		_ = json.Unmarshal([]byte(s), &cached)
+	}
+
+	acct, stale := cached, false
+	if refresh || !cached.fresh() {
+		// Only now is the key (and maybe the keychain) needed.
+		cfg, err := ensureConfig(false)
+		if err != nil {
+			exitErr(err)
+		}
+		if acct, stale, err = fetchAccount(cfg.APIKey, cached); err != nil {
+			exitErr(err)
+		}
+		if !stale {
+			if b, err := json.Marshal(acct); err == nil {
+				_ = setState(stateAccount, string(b))
+			}
+		}
+	}
+
+	human := fmt.Sprintf("%s@%s, forwarding to %s\n%d addresses generated",
+		cyan(acct.Username), ddg.Domain, acct.ForwardingEmail, acct.AddressesGenerated)
+	if age := time.Since(acct.Fetched).Round(time.Second); age >= time.Second {
+		human += fmt.Sprintf("\n(as of %s ago", age)
+		if stale {
+			human += "; DuckDuckGo can't be reached right now"
+		}
+		human += ")"
+	}
+	out.Result(map[string]interface{}{
+		"username":            acct.Username,
+		"forwarding_email":    acct.ForwardingEmail,
+		"addresses_generated": acct.AddressesGenerated,
+		"fetched":             acct.Fetched,
+		"stale":               stale,
+	}, human)
+}
+
+// accountCache is the daemon's in-memory counterpart of the state file
+// cache, one entry per user.
+type accountCache struct {
+	mu    sync.Mutex
+	users map[string]cachedAccount
+}
+
+// get returns user's account details, asking DuckDuckGo only when the
+// cached ones are older than accountTTL.
+func (c *accountCache) get(user, token string) (cachedAccount, bool, error) {
+	c.mu.Lock()
+	cached := c.users[user]
+	c.mu.Unlock()
+	if cached.fresh() {
+		return cached, false, nil
+	}
+	acct, stale, err := fetchAccount(token, cached)
+	if err == nil && !stale {
+		c.mu.Lock()
+		if c.users == nil {
+			c.users = map[string]cachedAccount{}
+		}
+		c.users[user] = acct
+		c.mu.Unlock()
+	}
+	return acct, stale, err
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !minimal
+
+package main
+
+import "fmt"
+
+var errNotWindows = fmt.Errorf("Windows services only exist on Windows; use your service manager (systemd, launchd) to run 'ddg serve'")
+
+func runWindowsService(run func(stop <-chan struct{})) error { return errNotWindows }
+
+func doInstallWindowsService(args []string) { exitErr(errNotWindows) }
+
+func init() {
+	kept := commandTree[:0]
+	for _, c := range commandTree {
+		if c.Name != "install-windows-service" {
+			kept = append(kept, c)
+		}
+	}
+	commandTree = kept
+}
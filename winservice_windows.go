@@ -0,0 +1,241 @@
+//go:build windows && !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+	procOpenSCManagerW               = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW               = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                 = advapi32.NewProc("OpenServiceW")
+	procChangeServiceConfig2W        = advapi32.NewProc("ChangeServiceConfig2W")
+	procStartServiceW                = advapi32.NewProc("StartServiceW")
+	procControlService               = advapi32.NewProc("ControlService")
+	procDeleteService                = advapi32.NewProc("DeleteService")
+	procCloseServiceHandle           = advapi32.NewProc("CloseServiceHandle")
+)
+
+// From winsvc.h.
+const (
+	scManagerAllAccess = 0xF003F
+	serviceAllAccess   = 0xF01FF
+
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+
+	serviceStopped        = 1
+	serviceStopPending    = 3
+	serviceRunning        = 4
+	serviceAcceptStop     = 1
+	serviceAcceptShutdown = 4
+
+	serviceControlStop     = 1
+	serviceControlShutdown = 5
+
+	serviceConfigDescription        = 1
+	serviceConfigFailureActions     = 2
+	serviceConfigDelayedAutoStart   = 3
+	serviceConfigFailureActionsFlag = 4
+	scActionRestart                 = 1
+
+	errServiceLogonFailed = syscall.Errno(1069)
+)
+
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+type scAction struct {
+	Type  uint32
+	Delay uint32 // milliseconds
+}
+
+type serviceFailureActions struct {
+	ResetPeriod uint32 // seconds
+	RebootMsg   *uint16
+	Command     *uint16
+	ActionCount uint32
+	Actions     *scAction
+}
+
+// windowsServiceName keeps profiles' daemons apart.
+func windowsServiceName() string {
+	if profileName != "" {
+		return "ddg-" + profileName
+	}
+	return "ddg"
+}
+
+// runWindowsService hands the process to the service control manager,
+// which calls back into run on its own thread; stop is closed when the
+// service is asked to stop or Windows shuts down.
+func runWindowsService(run func(stop <-chan struct{})) error {
+	name, err := syscall.UTF16PtrFromString(windowsServiceName())
+	if err != nil {
+		return err
+	}
+	var handle uintptr
+	setStatus := func(state, accepts uint32) {
+		st := serviceStatus{ServiceType: serviceWin32OwnProcess, CurrentState: state, ControlsAccepted: accepts}
+		procSetServiceStatus.Call(handle, uintptr(unsafe.Pointer(&st)))
+	}
+	stop := make(chan struct{})
+	var once sync.Once
+	handler := syscall.NewCallback(func(ctrl, eventType, eventData, context uintptr) uintptr {
+		if ctrl == serviceControlStop || ctrl == serviceControlShutdown {
+			setStatus(serviceStopPending, 0)
+			once.Do(func() { close(stop) })
+		}
+		return 0 // NO_ERROR, which also answers SERVICE_CONTROL_INTERROGATE
+	})
+	serviceMain := syscall.NewCallback(func(argc, argv uintptr) uintptr {
+		handle, _, _ = procRegisterServiceCtrlHandlerEx.Call(uintptr(unsafe.Pointer(name)), handler, 0)
+		setStatus(serviceRunning, serviceAcceptStop|serviceAcceptShutdown)
+		run(stop)
+		setStatus(serviceStopped, 0)
+		return 0
+	})
+	table := []serviceTableEntry{{name: name, proc: serviceMain}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return fmt.Errorf("--windows-service is for the service manager; use 'ddg install-windows-service' (%v)", err)
+	}
+	return nil
+}
+
+// doInstallWindowsService registers 'ddg serve' as a service that starts at
+// boot and is restarted if it dies. It runs as the current user, since the
+// daemon needs their config and token, so it asks for their password.
+func doInstallWindowsService(args []string) {
+	addr, remove := defaultServeAddr, false
+	account := os.Getenv("USERDOMAIN") + `\` + os.Getenv("USERNAME")
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--addr" && i+1 < len(args) {
+			addr = args[i+1]
+			i++
+		} else if args[i] == "--user" && i+1 < len(args) {
+			account = args[i+1]
+			i++
+		} else if args[i] == "--remove" {
+			remove = true
+		} else {
+			unknownArg(args[i])
+		}
+	}
+	scm, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if scm == 0 {
+		exitErr(fmt.Errorf("opening the service manager (run this from an elevated prompt): %w", err))
+	}
+	defer procCloseServiceHandle.Call(scm)
+	name := windowsServiceName()
+
+	if remove {
+		err := removeWindowsService(scm, name)
+		audit("windows-service.remove", err, map[string]string{"service": name})
+		if err != nil {
+			exitErr(err)
+		}
+		out.Done("Removed the "+name+" service.", map[string]string{"service": name})
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exitErr(err)
+	}
+	cmdline := []string{`"` + exe + `"`}
+	if profileName != "" {
+		cmdline = append(cmdline, "--profile", profileName)
+	}
+	cmdline = append(cmdline, "serve", "--windows-service", "--addr", addr)
+	password := ask(prompt{
+		Label:  fmt.Sprintf("Windows password for %s (the service runs as you, to read your ddg settings)", account),
+		Env:    "DDG_SERVICE_PASSWORD",
+		Hint:   "set DDG_SERVICE_PASSWORD",
+		Secret: true,
+	})
+
+	display := "DuckDuckGone daemon"
+	if profileName != "" {
+		display += " (" + profileName + ")"
+	}
+	svcName, displayName, binPath := utf16(name), utf16(display), utf16(strings.Join(cmdline, " "))
+	user, pass := utf16(account), utf16(password)
+	svc, _, err := procCreateServiceW.Call(scm,
+		uintptr(unsafe.Pointer(svcName)), uintptr(unsafe.Pointer(displayName)),
+		serviceAllAccess, serviceWin32OwnProcess, serviceAutoStart, serviceErrorNormal,
+		uintptr(unsafe.Pointer(binPath)), 0, 0, 0,
+		uintptr(unsafe.Pointer(user)), uintptr(unsafe.Pointer(pass)))
+	if svc == 0 {
+		audit("windows-service.install", err, map[string]string{"service": name})
+		exitErr(fmt.Errorf("creating the %s service: %w", name, err))
+	}
+	defer procCloseServiceHandle.Call(svc)
+
+	desc := struct{ Description *uint16 }{utf16("Hands out Duck Address aliases to local apps ('ddg serve').")}
+	procChangeServiceConfig2W.Call(svc, serviceConfigDescription, uintptr(unsafe.Pointer(&desc)))
+	delayed := struct{ Delayed int32 }{1} // don't slow down boot
+	procChangeServiceConfig2W.Call(svc, serviceConfigDelayedAutoStart, uintptr(unsafe.Pointer(&delayed)))
+	// Restart after 5s, 5s, then every minute; forget failures after a day.
+	actions := []scAction{{scActionRestart, 5000}, {scActionRestart, 5000}, {scActionRestart, 60000}}
+	failure := serviceFailureActions{ResetPeriod: 86400, ActionCount: uint32(len(actions)), Actions: &actions[0]}
+	procChangeServiceConfig2W.Call(svc, serviceConfigFailureActions, uintptr(unsafe.Pointer(&failure)))
+	// Also restart when ddg exits with an error, not only when it crashes.
+	onErrors := struct{ Enabled int32 }{1}
+	procChangeServiceConfig2W.Call(svc, serviceConfigFailureActionsFlag, uintptr(unsafe.Pointer(&onErrors)))
+
+	if r, _, err := procStartServiceW.Call(svc, 0, 0); r == 0 {
+		audit("windows-service.install", err, map[string]string{"service": name, "account": account})
+		if errors.Is(err, errServiceLogonFailed) {
+			exitErr(fmt.Errorf("installed %s, but it couldn't log on as %s: check the password and give the account \"Log on as a service\" (secpol.msc), then 'sc start %s'", name, account, name))
+		}
+		exitErr(fmt.Errorf("installed %s, but it didn't start: %w", name, err))
+	}
+	audit("windows-service.install", nil, map[string]string{"service": name, "account": account, "addr": addr})
+	out.Done(fmt.Sprintf("Installed and started the %s service on %s; it starts with Windows and restarts if it fails.\nRemove it with 'ddg install-windows-service --remove'.", name, addr),
+		map[string]string{"service": name, "account": account, "addr": addr})
+}
+
+func removeWindowsService(scm uintptr, name string) error {
+	svcName := utf16(name)
+	svc, _, err := procOpenServiceW.Call(scm, uintptr(unsafe.Pointer(svcName)), serviceAllAccess)
+	if svc == 0 {
+		return fmt.Errorf("opening the %s service: %w", name, err)
+	}
+	defer procCloseServiceHandle.Call(svc)
+	var st serviceStatus
+	procControlService.Call(svc, serviceControlStop, uintptr(unsafe.Pointer(&st))) // may already be stopped
+	if r, _, err := procDeleteService.Call(svc); r == 0 {
+		return fmt.Errorf("removing the %s service: %w", name, err)
+	}
+	return nil
+}
+
+// utf16 converts s for the API; the strings here never contain NULs.
+func utf16(s string) *uint16 {
+	p, _ := syscall.UTF16PtrFromString(s)
+	return p
+}